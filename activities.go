@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NathanBaulch/rainbow-roads/list"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	// activitiesOpts are the options to list activities
+	activitiesOpts = &list.Options{}
+	// activitiesCmd represents the "activities" command
+	activitiesCmd = &cobra.Command{
+		Use:     "activities",
+		Aliases: []string{"ls"},
+		Short:   "List the included activities",
+		// Pre-checks to ensure value are in bounds
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(list.Formats, activitiesOpts.Format) {
+				return flagError("format", activitiesOpts.Format, "supports "+strings.Join(list.Formats, ", "))
+			}
+			if !slices.Contains(list.SortKeys, activitiesOpts.Sort) {
+				return flagError("sort", activitiesOpts.Sort, "supports "+strings.Join(list.SortKeys, ", "))
+			}
+			return checkLocale(activitiesOpts.Locale, activitiesOpts.Units)
+		},
+		// Run the command
+		RunE: func(cmd *cobra.Command, args []string) error {
+			activitiesOpts.Input = args
+			return cancelled(cmd, list.Run(cmd.Context(), activitiesOpts))
+		},
+	}
+)
+
+func init() {
+	// Add the "activities" command to the root command
+	rootCmd.AddCommand(activitiesCmd)
+
+	// General flags (output location and format)
+	general := &pflag.FlagSet{}
+	general.StringVarP(&activitiesOpts.Output, "output", "o", "", "optional path of the generated file, defaults to standard output")
+	general.StringVarP(&activitiesOpts.Format, "format", "f", "table", "output format, supports "+strings.Join(list.Formats, ", "))
+	general.StringVar(&activitiesOpts.Sort, "sort", "start", "key to sort activities by, supports "+strings.Join(list.SortKeys, ", "))
+	general.BoolVar(&activitiesOpts.Reverse, "reverse", false, "reverse the sort order")
+	general.UintVar(&activitiesOpts.Limit, "limit", 0, "maximum number of activities to list, 0 for all")
+	general.AddFlagSet(localeFlagSet(&activitiesOpts.Locale, &activitiesOpts.Units))
+	general.AddFlagSet(configFlagSet())
+	general.VisitAll(func(f *pflag.Flag) { activitiesCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+
+	// Filtering flags
+	filters := filterFlagSet(&activitiesOpts.Selector)
+	filters.VisitAll(func(f *pflag.Flag) { activitiesCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+
+	// Prints the help command
+	activitiesCmd.SetUsageFunc(func(*cobra.Command) error {
+		fmt.Fprintln(activitiesCmd.OutOrStderr())
+		fmt.Fprintln(activitiesCmd.OutOrStderr(), "Usage:")
+		fmt.Fprintln(activitiesCmd.OutOrStderr(), " ", activitiesCmd.UseLine(), "[input]")
+		fmt.Fprintln(activitiesCmd.OutOrStderr())
+		fmt.Fprintln(activitiesCmd.OutOrStderr(), "General flags:")
+		fmt.Fprintln(activitiesCmd.OutOrStderr(), general.FlagUsages())
+		fmt.Fprintln(activitiesCmd.OutOrStderr(), "Filtering flags:")
+		fmt.Fprint(activitiesCmd.OutOrStderr(), filters.FlagUsages())
+		return nil
+	})
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// configPath is the path of the config file, or empty to search the default locations
+	configPath string
+	// profileName is the name of the config profile to apply, or empty for the default profile
+	profileName string
+)
+
+// envPrefix is prepended to the upper case flag name to get the environment variable of a flag.
+const envPrefix = "RAINBOW_ROADS_"
+
+// mutuallyExclusive is the annotation that cobra uses to record the mutually exclusive groups of a flag.
+const mutuallyExclusive = "cobra_annotation_mutually_exclusive"
+
+// defaultProfile is the name of the profile applied when no profile is specified.
+const defaultProfile = "default"
+
+// config is the structure of a config file.
+type config struct {
+	// Profiles maps profile names to flag values.
+	// A value may be a list to set a flag multiple times, or a map of flag values for the command of that name.
+	Profiles map[string]map[string]any `yaml:"profiles"`
+}
+
+func init() {
+	// Apply environment variables and the config profile before every command
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
+		return applyConfig(cmd)
+	}
+}
+
+// configFlagSet sets the config file flags from the command.
+func configFlagSet() *pflag.FlagSet {
+	fs := &pflag.FlagSet{}
+	fs.StringVar(&configPath, "config", "", "optional path of a YAML config file with named profiles, defaults to "+Title+".yaml in the working or user config directory")
+	fs.StringVar(&profileName, "profile", "", "name of the config profile to apply, defaults to \""+defaultProfile+"\" if present")
+	return fs
+}
+
+// applyConfig sets the unchanged flags of cmd from environment variables, then from the selected config profile.
+// Command line flags take precedence over environment variables, which take precedence over the profile.
+// Mutually exclusive flags are treated as a unit, so no flag is applied if another in its group was set with higher precedence.
+func applyConfig(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+
+	// Apply environment variables
+	var err error
+	set := settledFlags(flags)
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || set[f.Name] {
+			return
+		}
+		name := envPrefix + strings.ToUpper(f.Name)
+		if val, ok := os.LookupEnv(name); ok {
+			if e := flags.Set(f.Name, val); e != nil {
+				err = fmt.Errorf("invalid value %q for environment variable %s: %w", val, name, e)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Load the config file
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	} else if cfg == nil {
+		if profileName != "" {
+			return fmt.Errorf("profile %q not found, no config file", profileName)
+		}
+		return nil
+	}
+
+	// Select the profile
+	name := profileName
+	if name == "" {
+		name = defaultProfile
+	}
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		if profileName != "" {
+			return fmt.Errorf("profile %q not found", profileName)
+		}
+		return nil
+	}
+
+	vals, err := profileValues(cmd, profile)
+	if err != nil {
+		return fmt.Errorf("profile %q: %w", name, err)
+	}
+
+	// Apply the profile to all flags that have not already been set, in a stable order
+	names := make([]string, 0, len(vals))
+	for n := range vals {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	set = settledFlags(flags)
+	for _, n := range names {
+		if set[n] {
+			continue
+		}
+		for _, val := range vals[n] {
+			if err := flags.Set(n, val); err != nil {
+				return fmt.Errorf("profile %q: invalid value %q for flag %s: %w", name, val, n, err)
+			}
+		}
+	}
+	return nil
+}
+
+// settledFlags returns the names of the flags in fs that have been set, along with the flags mutually exclusive with them.
+func settledFlags(fs *pflag.FlagSet) map[string]bool {
+	names := make(map[string]bool)
+	fs.Visit(func(f *pflag.Flag) {
+		names[f.Name] = true
+		for _, group := range f.Annotations[mutuallyExclusive] {
+			for _, n := range strings.Split(group, " ") {
+				names[n] = true
+			}
+		}
+	})
+	return names
+}
+
+// loadConfig reads and decodes the config file at path.
+// If path is empty, the default locations are searched and nil is returned if no config file exists.
+func loadConfig(path string) (*config, error) {
+	var buf []byte
+	if path != "" {
+		var err error
+		if buf, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	} else {
+		paths := []string{Title + ".yaml"}
+		if dir, err := os.UserConfigDir(); err == nil {
+			paths = append(paths, filepath.Join(dir, Title, "config.yaml"))
+		}
+		for _, p := range paths {
+			var err error
+			if buf, err = os.ReadFile(p); err == nil {
+				path = p
+				break
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+		if path == "" {
+			return nil, nil
+		}
+	}
+
+	cfg := &config{}
+	if err := yaml.Unmarshal(buf, cfg); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// profileValues flattens profile into the string values to set on each flag of cmd.
+// Values in the section named after cmd override the common values, and sections of other commands are ignored.
+// Common flags unknown to cmd are skipped if another command recognizes them.
+func profileValues(cmd *cobra.Command, profile map[string]any) (map[string][]string, error) {
+	vals := make(map[string][]string)
+	add := func(key string, val any, strict bool) error {
+		if cmd.Flags().Lookup(key) == nil {
+			if strict || !isCommandFlag(key) {
+				return fmt.Errorf("flag %q not recognized", key)
+			}
+			return nil
+		}
+		strs, err := flagStrings(val)
+		if err != nil {
+			return fmt.Errorf("flag %q: %w", key, err)
+		}
+		vals[key] = strs
+		return nil
+	}
+
+	// Apply the common values first
+	for key, val := range profile {
+		if _, ok := val.(map[string]any); ok && isCommand(key) {
+			continue
+		}
+		if err := add(key, val, false); err != nil {
+			return nil, err
+		}
+	}
+
+	// Then override with the values of this command's section
+	if section, ok := profile[cmd.Name()].(map[string]any); ok {
+		for key, val := range section {
+			if err := add(key, val, true); err != nil {
+				return nil, fmt.Errorf("%s: %w", cmd.Name(), err)
+			}
+		}
+	}
+	return vals, nil
+}
+
+// flagStrings converts a decoded YAML value into the strings to pass to pflag.Value.Set.
+func flagStrings(val any) ([]string, error) {
+	switch v := val.(type) {
+	case []any:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := flagStrings(item)
+			if err != nil {
+				return nil, err
+			}
+			strs = append(strs, s...)
+		}
+		return strs, nil
+	case map[string]any:
+		return nil, errors.New("unexpected map value")
+	case nil:
+		return nil, errors.New("unexpected empty value")
+	case time.Time:
+		if v.Equal(v.Truncate(24 * time.Hour)) {
+			return []string{v.Format("2006-01-02")}, nil
+		}
+		return []string{v.Format(time.RFC3339)}, nil
+	default:
+		return []string{fmt.Sprint(v)}, nil
+	}
+}
+
+// isCommand returns true if name is the name of a sub-command.
+func isCommand(name string) bool {
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isCommandFlag returns true if name is a flag of any sub-command.
+func isCommandFlag(name string) bool {
+	for _, c := range rootCmd.Commands() {
+		if c.Flags().Lookup(name) != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestApplyConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(`
+profiles:
+  default:
+    sport: cycling
+  lockdown:
+    sport: [running, walking]
+    min_distance: 2km
+    after: 2020-03-01
+    activities:
+      min_distance: 5km
+  typo:
+    min_distanse: 2km
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		profile string
+		env     map[string]string
+		args    []string
+		expect  any
+	}{
+		{"", nil, nil, "cycling 0 0001-01-01"},
+		{"lockdown", nil, nil, "running,walking 5000 2020-03-01"},
+		{"lockdown", map[string]string{"RAINBOW_ROADS_MIN_DISTANCE": "3km"}, nil, "running,walking 3000 2020-03-01"},
+		{"lockdown", map[string]string{"RAINBOW_ROADS_MIN_DISTANCE": "3km"}, []string{"--min_distance", "4km"}, "running,walking 4000 2020-03-01"},
+		{"lockdown", nil, []string{"--sport", "swimming"}, "swimming 5000 2020-03-01"},
+		{"typo", nil, nil, fmt.Errorf(`profile "typo": flag "min_distanse" not recognized`)},
+		{"missing", nil, nil, fmt.Errorf(`profile "missing" not found`)},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			for k, v := range testCase.env {
+				t.Setenv(k, v)
+			}
+
+			sel := &parse.Selector{}
+			cmd := &cobra.Command{Use: "activities"}
+			filterFlagSet(sel).VisitAll(func(f *pflag.Flag) { cmd.Flags().Var(f.Value, f.Name, f.Usage) })
+			if err := cmd.Flags().Parse(testCase.args); err != nil {
+				t.Fatal(err)
+			}
+
+			configPath, profileName = path, testCase.profile
+			defer func() { configPath, profileName = "", "" }()
+			err := applyConfig(cmd)
+			if expectErr, ok := testCase.expect.(error); ok {
+				if err == nil || err.Error() != expectErr.Error() {
+					t.Fatal(err, "!=", expectErr)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			actual := fmt.Sprintf("%s %g %s", strings.Join(sel.Sports, ","), sel.MinDistance, sel.After.Format("2006-01-02"))
+			if actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+func TestApplyConfigExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(`
+profiles:
+  default:
+    region: -37.8,144.9,5km
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		env    map[string]string
+		args   []string
+		expect string
+	}{
+		{nil, nil, "-37.8,144.9,5km|"},
+		{nil, []string{"--region_box", "-38,144,-37,145"}, "|-38,144,-37,145"},
+		{map[string]string{"RAINBOW_ROADS_REGION_BOX": "-38,144,-37,145"}, nil, "|-38,144,-37,145"},
+		{map[string]string{"RAINBOW_ROADS_REGION": "-37.7,145,1km"}, []string{"--region_box", "-38,144,-37,145"}, "|-38,144,-37,145"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			for k, v := range testCase.env {
+				t.Setenv(k, v)
+			}
+
+			var region, regionBox string
+			cmd := &cobra.Command{Use: "paint"}
+			cmd.Flags().StringVar(&region, "region", "", "")
+			cmd.Flags().StringVar(&regionBox, "region_box", "", "")
+			cmd.MarkFlagsMutuallyExclusive("region", "region_box")
+			if err := cmd.Flags().Parse(testCase.args); err != nil {
+				t.Fatal(err)
+			}
+
+			configPath, profileName = path, ""
+			defer func() { configPath, profileName = "", "" }()
+			if err := applyConfig(cmd); err != nil {
+				t.Fatal(err)
+			}
+			if err := cmd.ValidateFlagGroups(); err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := region + "|" + regionBox; actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package conv
+
+import (
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// translations maps English labels and format strings to their translations in each supported language.
+// Anything missing from a language is printed in English.
+var translations = map[language.Tag]map[string]string{
+	language.German: {
+		"files":                          "Dateien",
+		"activities":                     "Aktivitäten",
+		"records":                        "Datenpunkte",
+		"sports":                         "Sportarten",
+		"period":                         "Zeitraum",
+		"duration":                       "Dauer",
+		"distance":                       "Distanz",
+		"pace":                           "Pace",
+		"bounds":                         "Grenzen",
+		"starts within":                  "Start in",
+		"ends within":                    "Ende in",
+		"progress":                       "Fortschritt",
+		"longest distance":               "Längste Distanz",
+		"longest duration":               "Längste Dauer",
+		"biggest climb":                  "Größter Anstieg",
+		"longest streak":                 "Längste Serie",
+		"eddington":                      "Eddington",
+		"daily streak":                   "Tägliche Serie",
+		"weekly streak":                  "Wöchentliche Serie",
+		"years":                          "Jahre",
+		"months":                         "Monate",
+		"weeks":                          "Wochen",
+		"days":                           "Tage",
+		"hours":                          "Stunden",
+		"minutes":                        "Minuten",
+		"seconds":                        "Sekunden",
+		"%s to %s":                       "%s bis %s",
+		"%s to %s, average %s":           "%s bis %s, Durchschnitt %s",
+		"%s to %s, average %s, total %s": "%s bis %s, Durchschnitt %s, gesamt %s",
+		"%.1f %s (%s to %s)":             "%.1f %s (%s bis %s)",
+		"%d %s (%s to %s)":               "%d %s (%s bis %s)",
+		"%d more days for %d":            "%d weitere Tage für %d",
+		"current %s, longest %s":         "aktuell %s, längste %s",
+		"now":                            "jetzt",
+		"earlier":                        "früher",
+		"%s ago":                         "vor %s",
+		"%d days ago":                    "vor %d Tagen",
+		"traveled":                       "befahren",
+		"not traveled":                   "nicht befahren",
+		"+%d more":                       "+%d weitere",
+		"other sports":                   "andere Sportarten",
+		"SOURCE":                         "QUELLE",
+		"SPORT":                          "SPORTART",
+		"START":                          "START",
+		"DURATION":                       "DAUER",
+		"DISTANCE":                       "DISTANZ",
+		"PACE":                           "PACE",
+		"RECORDS":                        "DATENPUNKTE",
+		"BOUNDED BY":                     "BEGRENZT DURCH",
+		"ACTIVITIES":                     "AKTIVITÄTEN",
+		"ASCENT":                         "ANSTIEG",
+		"YEAR":                           "JAHR",
+		"MONTH":                          "MONAT",
+		"WEEK":                           "WOCHE",
+		"BEST EFFORT":                    "BESTLEISTUNG",
+		"TIME":                           "ZEIT",
+		"DATE":                           "DATUM",
+	},
+	language.French: {
+		"files":                          "fichiers",
+		"activities":                     "activités",
+		"records":                        "points",
+		"sports":                         "sports",
+		"period":                         "période",
+		"duration":                       "durée",
+		"distance":                       "distance",
+		"pace":                           "allure",
+		"bounds":                         "limites",
+		"starts within":                  "départs dans",
+		"ends within":                    "arrivées dans",
+		"progress":                       "progression",
+		"longest distance":               "plus longue distance",
+		"longest duration":               "plus longue durée",
+		"biggest climb":                  "plus grand dénivelé",
+		"longest streak":                 "plus longue série",
+		"eddington":                      "eddington",
+		"daily streak":                   "série quotidienne",
+		"weekly streak":                  "série hebdomadaire",
+		"years":                          "ans",
+		"months":                         "mois",
+		"weeks":                          "semaines",
+		"days":                           "jours",
+		"hours":                          "heures",
+		"minutes":                        "minutes",
+		"seconds":                        "secondes",
+		"%s to %s":                       "%s à %s",
+		"%s to %s, average %s":           "%s à %s, moyenne %s",
+		"%s to %s, average %s, total %s": "%s à %s, moyenne %s, total %s",
+		"%.1f %s (%s to %s)":             "%.1f %s (%s à %s)",
+		"%d %s (%s to %s)":               "%d %s (%s à %s)",
+		"%d more days for %d":            "%d jours de plus pour %d",
+		"current %s, longest %s":         "actuelle %s, plus longue %s",
+		"now":                            "maintenant",
+		"earlier":                        "plus tôt",
+		"%s ago":                         "il y a %s",
+		"%d days ago":                    "il y a %d jours",
+		"traveled":                       "parcourues",
+		"not traveled":                   "non parcourues",
+		"+%d more":                       "+%d autres",
+		"other sports":                   "autres sports",
+		"SOURCE":                         "SOURCE",
+		"SPORT":                          "SPORT",
+		"START":                          "DÉBUT",
+		"DURATION":                       "DURÉE",
+		"DISTANCE":                       "DISTANCE",
+		"PACE":                           "ALLURE",
+		"RECORDS":                        "POINTS",
+		"BOUNDED BY":                     "LIMITÉ PAR",
+		"ACTIVITIES":                     "ACTIVITÉS",
+		"ASCENT":                         "DÉNIVELÉ",
+		"YEAR":                           "ANNÉE",
+		"MONTH":                          "MOIS",
+		"WEEK":                           "SEMAINE",
+		"BEST EFFORT":                    "MEILLEUR EFFORT",
+		"TIME":                           "TEMPS",
+		"DATE":                           "DATE",
+	},
+}
+
+// init registers the translations with the default message catalog.
+func init() {
+	for tag, msgs := range translations {
+		for key, msg := range msgs {
+			if err := message.SetString(tag, key, msg); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
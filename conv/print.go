@@ -0,0 +1,86 @@
+package conv
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Printer is a message.Printer that also knows which Units to print quantities in.
+type Printer struct {
+	*message.Printer
+	Units Units // Units is the system of measurement used by SprintDistance, SprintPace and SprintElevation.
+}
+
+// NewPrinter returns a Printer for the given BCP 47 locale and units, see ParseUnits.
+func NewPrinter(locale, units string) (*Printer, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return nil, fmt.Errorf("locale %q not recognized", locale)
+	}
+	u, err := ParseUnits(units, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &Printer{Printer: message.NewPrinter(tag), Units: u}, nil
+}
+
+// SprintDuration formats the duration into a string using the given printer.
+// The duration is truncated to whole seconds.
+func SprintDuration(p *Printer, dur time.Duration) string {
+	return p.Sprintf("%s", dur.Truncate(time.Second))
+}
+
+// SprintDistance formats the distance in meters into a string using the given printer.
+// The distance is in kilometers or miles.
+func SprintDistance(p *Printer, dist float64) string {
+	length, symbol := p.Units.Length()
+	return p.Sprintf("%.1f%s", dist/length, symbol)
+}
+
+// SprintPace formats the pace per meter into a string using the given printer.
+// The pace is formatted as time per kilometer or mile.
+func SprintPace(p *Printer, pace time.Duration) string {
+	length, symbol := p.Units.Length()
+	return p.Sprintf("%s/%s", time.Duration(float64(pace)*length).Truncate(time.Second), symbol)
+}
+
+// SprintSpeed formats the speed in meters per second into a string using the given printer.
+// The speed is in kilometers or miles per hour.
+func SprintSpeed(p *Printer, speed float64) string {
+	length, symbol := p.Units.Length()
+	return p.Sprintf("%.1f%s/h", speed*3600/length, symbol)
+}
+
+// SprintElevation formats the elevation in meters into a string using the given printer.
+// The elevation is in meters or feet.
+func SprintElevation(p *Printer, ele float64) string {
+	height, symbol := p.Units.Height()
+	return p.Sprintf("%.0f%s", ele/height, symbol)
+}
+
+// labelWidth is the minimum width of the labels printed by FprintField, so consecutive calls line up.
+const labelWidth = len("starts within:")
+
+// FprintField prints the translated label followed by a colon, padding, the value and a newline to w.
+func FprintField(w io.Writer, p *Printer, label string, value any) {
+	label = p.Sprintf(label) + ":"
+	if n := utf8.RuneCountInString(label); n < labelWidth {
+		label += strings.Repeat(" ", labelWidth-n)
+	}
+	p.Fprintf(w, "%s %v\n", label, value)
+}
+
+// SprintHeader returns the translated column headers as a tab separated table row.
+func SprintHeader(p *Printer, cols ...string) string {
+	s := make([]string, len(cols))
+	for i, col := range cols {
+		s[i] = p.Sprintf(col)
+	}
+	return strings.Join(s, "\t") + "\n"
+}
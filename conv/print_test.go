@@ -0,0 +1,57 @@
+package conv
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSprint(t *testing.T) {
+	metric, _ := NewPrinter("en", "")
+	imperial, _ := NewPrinter("en-US", "")
+	german, _ := NewPrinter("de", "")
+
+	testCases := []struct {
+		actual string
+		expect string
+	}{
+		{SprintDistance(metric, 12345), "12.3km"},
+		{SprintDistance(imperial, 16093.44), "10.0mi"},
+		{SprintDistance(german, 12345), "12,3km"},
+		{SprintPace(metric, 300*time.Millisecond), "5m0s/km"},
+		{SprintPace(imperial, 300*time.Millisecond), "8m2s/mi"},
+		{SprintSpeed(metric, 5), "18.0km/h"},
+		{SprintSpeed(imperial, 5), "11.2mi/h"},
+		{SprintElevation(metric, 100), "100m"},
+		{SprintElevation(imperial, 100), "328ft"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			if tc.actual != tc.expect {
+				t.Fatal(tc.actual, "!=", tc.expect)
+			}
+		})
+	}
+}
+
+func TestFprintField(t *testing.T) {
+	p, _ := NewPrinter("de", "")
+	b := &bytes.Buffer{}
+	FprintField(b, p, "activities", 3)
+	FprintField(b, p, "longest distance", 4)
+	expect := "Aktivitäten:   3\nLängste Distanz: 4\n"
+	if b.String() != expect {
+		t.Fatal(b.String(), "!=", expect)
+	}
+}
+
+func TestNewPrinter(t *testing.T) {
+	if _, err := NewPrinter("not a locale", ""); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := NewPrinter("en", "furlongs"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,53 @@
+package conv
+
+import (
+	"fmt"
+
+	"golang.org/x/text/language"
+)
+
+// Units is a system of measurement used to print distances, paces and elevations.
+type Units string
+
+const (
+	Metric   Units = "metric"   // Metric prints kilometers, minutes per kilometer and meters.
+	Imperial Units = "imperial" // Imperial prints miles, minutes per mile and feet.
+)
+
+// UnitNames lists the supported systems of measurement.
+var UnitNames = []string{string(Metric), string(Imperial)}
+
+// ParseUnits returns the Units with the given name.
+// An empty name results in the customary units of the region of locale tag, if it explicitly has one.
+func ParseUnits(name string, tag language.Tag) (Units, error) {
+	switch Units(name) {
+	case Metric, Imperial:
+		return Units(name), nil
+	case "":
+		if region, conf := tag.Region(); conf == language.Exact {
+			switch region.String() {
+			case "US", "LR", "MM":
+				return Imperial, nil
+			}
+		}
+		return Metric, nil
+	default:
+		return "", fmt.Errorf("units %q not recognized", name)
+	}
+}
+
+// Length returns the number of meters in the distance unit and its symbol.
+func (u Units) Length() (float64, string) {
+	if u == Imperial {
+		return 1609.344, "mi"
+	}
+	return 1000, "km"
+}
+
+// Height returns the number of meters in the elevation unit and its symbol.
+func (u Units) Height() (float64, string) {
+	if u == Imperial {
+		return 0.3048, "ft"
+	}
+	return 1, "m"
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"errors"
 	"fmt"
+	"image/color"
+	"math"
 	"regexp"
 	"sort"
 	"strconv"
@@ -13,9 +15,13 @@ import (
 	"github.com/NathanBaulch/rainbow-roads/geo"
 	"github.com/NathanBaulch/rainbow-roads/img"
 	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/stats"
+	"github.com/NathanBaulch/rainbow-roads/worms"
 	"github.com/araddon/dateparse"
 	"github.com/bcicen/go-units"
 	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
+	"golang.org/x/text/language"
 )
 
 // filterFlagSet sets the filter flags from the command.
@@ -30,16 +36,74 @@ func filterFlagSet(selector *parse.Selector) *pflag.FlagSet {
 	fs.Var((*DistanceFlag)(&selector.MaxDistance), "max_distance", "greatest distance of included activities, eg 10mi")
 	fs.Var((*PaceFlag)(&selector.MinPace), "min_pace", "slowest pace of included activities, eg 8km/h")
 	fs.Var((*PaceFlag)(&selector.MaxPace), "max_pace", "fastest pace of included activities, eg 10min/mi")
-	fs.Var((*CircleFlag)(&selector.BoundedBy), "bounded_by", "region that activities must be fully contained within, eg -37.8,144.9,10km")
+	fs.Var((*CircleFlag)(&selector.BoundedBy), "bounded_by", "region that activities must be fully contained within, eg -37.8,144.9,10km or 37°48'S 144°54'E,10km")
+	fs.Var((*BoxFlag)(&selector.BoundedByBox), "bounded_by_box", "rectangular region that activities must be fully contained within, eg -37.9,144.8,-37.7,145 or -37.8,144.9,10kmx5km")
 	fs.Var((*CircleFlag)(&selector.StartsNear), "starts_near", "region that activities must start from, eg 51.53,-0.21,1km")
 	fs.Var((*CircleFlag)(&selector.EndsNear), "ends_near", "region that activities must end in, eg 30.06,31.22,1km")
 	fs.Var((*CircleFlag)(&selector.PassesThrough), "passes_through", "region that activities must pass through, eg 40.69,-74.12,10mi")
 	return fs
 }
 
+// statsFlagSet sets the stats output flags from the command.
+func statsFlagSet(format, file *string) *pflag.FlagSet {
+	fs := &pflag.FlagSet{}
+	fs.StringVar(format, "stats_format", "text", "format of the printed stats, supports "+strings.Join(stats.Formats, ", "))
+	fs.StringVar(file, "stats_file", "", "optional path of a file to write the stats to instead of standard output")
+	return fs
+}
+
+// checkStatsFormat returns a flag error if format is not a supported stats format.
+func checkStatsFormat(format string) error {
+	if !slices.Contains(stats.Formats, format) {
+		return flagError("stats_format", format, "supports "+strings.Join(stats.Formats, ", "))
+	}
+	return nil
+}
+
+// localeFlagSet sets the locale and units flags from the command.
+func localeFlagSet(locale, units *string) *pflag.FlagSet {
+	fs := &pflag.FlagSet{}
+	fs.StringVar(locale, "locale", "en", "BCP 47 language tag used to format numbers and labels, eg de or en-US")
+	fs.StringVar(units, "units", "", "system of measurement, supports "+strings.Join(conv.UnitNames, ", ")+", defaults to the customary units of the locale")
+	return fs
+}
+
+// checkLocale returns a flag error if locale is not a valid language tag or units is not supported.
+func checkLocale(locale, units string) error {
+	if _, err := language.Parse(locale); err != nil {
+		return flagError("locale", locale, "must be a valid BCP 47 language tag")
+	}
+	if units != "" && !slices.Contains(conv.UnitNames, units) {
+		return flagError("units", units, "supports "+strings.Join(conv.UnitNames, ", "))
+	}
+	return nil
+}
+
+// overlayFlagSet sets the overlay and font flags from the command.
+func overlayFlagSet(overlays *[]img.Overlay, text *img.TextStyle) *pflag.FlagSet {
+	fs := &pflag.FlagSet{}
+	fs.Var((*OverlaysFlag)(overlays), "overlay", "overlays to draw, can be specified multiple times, supports "+strings.Join(img.OverlayKinds, ", ")+", eg title=Lockdown;date@top_right;legend")
+	fs.StringVar(&text.Font, "font", "", "optional path of a TTF or OTF font file for the overlays, defaults to Go Regular")
+	fs.Float64Var(&text.Size, "font_size", 16, "size of the overlay text in pixels")
+	text.Color = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	fs.Var((*ColorFlag)(&text.Color), "font_color", "color of the overlay text, eg #ff0 or yellow")
+	return fs
+}
+
+// checkText returns a flag error if the overlay font cannot be loaded at its size.
+func checkText(text *img.TextStyle) error {
+	if text.Size <= 0 {
+		return flagError("font_size", text.Size, "must be positive")
+	}
+	if _, err := text.NewFace(); err != nil {
+		return flagError("font", text.Font, err.Error())
+	}
+	return nil
+}
+
 // flagError generates the error message to show when there is a flag error.
 func flagError(name string, value any, reason string) error {
-	return fmt.Errorf("invalid value %q for flag --%s: %s\n", value, name, reason)
+	return fmt.Errorf("invalid value %q for flag --%s: %s\n", fmt.Sprint(value), name, reason)
 }
 
 // ColorsFlag is the flag type for color gradients.
@@ -63,6 +127,220 @@ func (c *ColorsFlag) String() string {
 	return (*img.ColorGradient)(c).String()
 }
 
+// SportColorsFlag is the flag type for a color gradient per sport.
+type SportColorsFlag map[string]img.ColorGradient
+
+// Type returns the type string of the SportColorsFlag.
+func (s *SportColorsFlag) Type() string {
+	return "sport_colors"
+}
+
+// Set parses the semicolon separated list of sport=gradient pairs and adds them to SportColorsFlag.
+func (s *SportColorsFlag) Set(str string) error {
+	if str == "" {
+		return errors.New("unexpected empty value")
+	}
+	if *s == nil {
+		*s = make(SportColorsFlag)
+	}
+	for _, part := range strings.Split(str, ";") {
+		sport, colors, ok := strings.Cut(part, "=")
+		if !ok || sport == "" {
+			return fmt.Errorf("sport colors %q not recognized, expected sport=colors", part)
+		}
+		var g img.ColorGradient
+		if err := g.Parse(colors); err != nil {
+			return err
+		}
+		(*s)[strings.ToLower(sport)] = g
+	}
+	return nil
+}
+
+// String returns the string representation of the SportColorsFlag, in sport order.
+func (s *SportColorsFlag) String() string {
+	if s == nil {
+		return ""
+	}
+	sports := make([]string, 0, len(*s))
+	for sport := range *s {
+		sports = append(sports, sport)
+	}
+	sort.Strings(sports)
+	for i, sport := range sports {
+		g := (*s)[sport]
+		sports[i] += "=" + g.String()
+	}
+	return strings.Join(sports, ";")
+}
+
+// CameraFlag is the flag type for the keyframes of a camera track.
+type CameraFlag worms.Camera
+
+// Type returns the type string of the CameraFlag.
+func (c *CameraFlag) Type() string {
+	return "camera"
+}
+
+// Set parses the semicolon separated list of keyframes and sets the value of CameraFlag.
+// Each keyframe is a viewport as supported by BoxFlag, or a center and zoom, eg "-37.8,144.9,4x",
+// optionally followed by its position in the animation, eg "@0.5" or "@50%".
+// Like the colors of a gradient, the first and last keyframes default to the start and end of the animation,
+// and keyframes without positions are evenly spaced between their neighbors.
+func (c *CameraFlag) Set(str string) error {
+	if str == "" {
+		return errors.New("unexpected empty value")
+	}
+
+	parts := strings.Split(str, ";")
+	keys := make(CameraFlag, len(parts))
+	missingAt := 0
+	for i, part := range parts {
+		k := &keys[i]
+
+		// Parse the position, or default it
+		if viewport, at, ok := strings.Cut(part, "@"); ok {
+			var err error
+			if strings.HasSuffix(at, "%") {
+				if k.At, err = strconv.ParseFloat(at[:len(at)-1], 64); err == nil {
+					k.At /= 100
+				}
+			} else {
+				k.At, err = strconv.ParseFloat(at, 64)
+			}
+			if err != nil {
+				return fmt.Errorf("position %q not recognized", at)
+			}
+			if k.At < 0 || k.At > 1 {
+				return fmt.Errorf("position %q not within range", at)
+			}
+			part = viewport
+		} else if i == 0 {
+			k.At = 0
+		} else if i == len(parts)-1 {
+			k.At = 1
+		} else {
+			k.At = math.NaN()
+			if missingAt == 0 {
+				missingAt = i
+			}
+		}
+		if !math.IsNaN(k.At) && missingAt > 0 {
+			at := keys[missingAt-1].At
+			step := (k.At - at) / float64(i+1-missingAt)
+			for j := missingAt; j < i; j++ {
+				at += step
+				keys[j].At = at
+			}
+			missingAt = 0
+		}
+		if i > 0 && k.At < keys[i-1].At {
+			return fmt.Errorf("keyframe %q out of order", parts[i])
+		}
+
+		// Parse the viewport, either a center and zoom or a box
+		if j := strings.LastIndex(part, ","); j >= 0 && strings.HasSuffix(part, "x") {
+			center, err := geo.ParsePoint(part[:j])
+			if err != nil {
+				return err
+			}
+			zoom, err := strconv.ParseFloat(strings.TrimSpace(part[j+1:len(part)-1]), 64)
+			if err != nil {
+				return fmt.Errorf("zoom %q not recognized", part[j+1:])
+			}
+			if zoom <= 0 {
+				return errors.New("zoom must be positive")
+			}
+			k.Center, k.Zoom = center, zoom
+		} else {
+			var b BoxFlag
+			if err := b.Set(part); err != nil {
+				return err
+			}
+			k.Box = geo.Box(b)
+		}
+	}
+
+	*c = keys
+	return nil
+}
+
+// String returns the string representation of the CameraFlag.
+func (c *CameraFlag) String() string {
+	if c == nil {
+		return ""
+	}
+	parts := make([]string, len(*c))
+	for i, k := range *c {
+		if k.Box.IsZero() {
+			parts[i] = fmt.Sprintf("%s,%sx", k.Center, conv.FormatFloat(k.Zoom))
+		} else {
+			parts[i] = k.Box.String()
+		}
+		parts[i] += "@" + conv.FormatFloat(k.At)
+	}
+	return strings.Join(parts, ";")
+}
+
+// ColorFlag is the flag type for a single color.
+type ColorFlag color.RGBA
+
+// Type returns the type string of the ColorFlag.
+func (c *ColorFlag) Type() string {
+	return "color"
+}
+
+// Set parses the color string and sets the value of ColorFlag.
+func (c *ColorFlag) Set(str string) error {
+	if str == "" {
+		return errors.New("unexpected empty value")
+	}
+	col, err := img.ParseColor(str)
+	if err != nil {
+		return err
+	}
+	*c = ColorFlag(col)
+	return nil
+}
+
+// String returns the string representation of the ColorFlag.
+func (c *ColorFlag) String() string {
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// OverlaysFlag is the flag type for a list of overlays.
+type OverlaysFlag []img.Overlay
+
+// Type returns the type string of the OverlaysFlag.
+func (o *OverlaysFlag) Type() string {
+	return "overlays"
+}
+
+// Set parses the semicolon separated string of overlays and appends them to OverlaysFlag.
+func (o *OverlaysFlag) Set(str string) error {
+	overlays, err := img.ParseOverlays(str)
+	if err != nil {
+		return err
+	}
+	*o = append(*o, overlays...)
+	return nil
+}
+
+// String returns the string representation of the OverlaysFlag.
+func (o *OverlaysFlag) String() string {
+	if o == nil {
+		return ""
+	}
+	parts := make([]string, len(*o))
+	for i, ov := range *o {
+		parts[i] = ov.String()
+	}
+	return strings.Join(parts, ";")
+}
+
 // SportsFlag is the flag type for a list of sports.
 type SportsFlag []string
 
@@ -134,7 +412,14 @@ func (d *DurationFlag) Set(str string) error {
 	var val time.Duration
 	var err error
 	if val, err = time.ParseDuration(str); err != nil {
-		if i, err := strconv.ParseInt(str, 10, 64); err != nil {
+		if strings.HasSuffix(str, "d") {
+			// Allow whole or fractional days, eg 30d
+			if f, err := strconv.ParseFloat(strings.TrimSuffix(str, "d"), 64); err != nil {
+				return errors.New("duration not recognized")
+			} else {
+				val = time.Duration(f * float64(24*time.Hour))
+			}
+		} else if i, err := strconv.ParseInt(str, 10, 64); err != nil {
 			return errors.New("duration not recognized")
 		} else {
 			val = time.Duration(i) * time.Second
@@ -232,34 +517,46 @@ func (c *CircleFlag) Type() string {
 }
 
 // Set parses the circle string and sets the value of CircleFlag.
+// The origin can be in any format supported by geo.ParsePoint, optionally followed by a comma and radius.
+// The radius of a geo URI can also be given by its uncertainty parameter, eg geo:-37.8,144.9;u=500.
 func (c *CircleFlag) Set(str string) error {
 	if str == "" {
 		return errors.New("unexpected empty value")
 	}
-	if parts := strings.Split(str, ","); len(parts) < 2 || len(parts) > 3 {
-		return errors.New("invalid number of parts")
-	} else if lat, err := strconv.ParseFloat(parts[0], 64); err != nil {
-		return fmt.Errorf("latitude %q not recognized", parts[0])
-	} else if lon, err := strconv.ParseFloat(parts[1], 64); err != nil {
-		return fmt.Errorf("longitude %q not recognized", parts[1])
-	} else if lat < -85 || lat > 85 {
-		return fmt.Errorf("latitude %q not within range", conv.FormatFloat(lat))
-	} else if lon < -180 || lon > 180 {
-		return fmt.Errorf("longitude %q not within range", conv.FormatFloat(lon))
-	} else {
-		*c = CircleFlag{
-			Origin: geo.NewPointFromDegrees(lat, lon),
-			Radius: 100,
+	radius := ""
+	origin, err := geo.ParsePoint(str)
+	if err != nil {
+		// Try again with the radius split off the end
+		i := strings.LastIndex(str, ",")
+		if i < 0 {
+			return err
+		} else if pt, err2 := geo.ParsePoint(str[:i]); err2 != nil {
+			return err
+		} else {
+			origin, radius = pt, str[i+1:]
 		}
-		if len(parts) == 3 {
-			if c.Radius, err = parseDistance(parts[2]); err != nil {
-				return errors.New("radius " + err.Error())
-			}
+	} else if m := geoUncertaintyRE.FindStringSubmatch(str); m != nil {
+		radius = m[1]
+	}
+
+	// Keep the origin within the latitudes of the default Mercator projection
+	if lat := geo.RadiansToDegrees(origin.Lat); lat < -85 || lat > 85 {
+		return fmt.Errorf("latitude %q not within range", conv.FormatFloat(lat))
+	}
+
+	*c = CircleFlag{Origin: origin, Radius: 100}
+	if radius != "" {
+		if c.Radius, err = parseDistance(radius); err != nil {
+			*c = CircleFlag{}
+			return errors.New("radius " + err.Error())
 		}
-		return nil
 	}
+	return nil
 }
 
+// geoUncertaintyRE is the regular expression that extracts the uncertainty parameter of a geo URI.
+var geoUncertaintyRE = regexp.MustCompile(`(?i)^geo:[^;]*;(?:.*;)?u=([^;]+)`)
+
 // String returns the string representation of the CircleFlag.
 func (c *CircleFlag) String() string {
 	if c == nil || geo.Circle(*c).IsZero() {
@@ -268,6 +565,139 @@ func (c *CircleFlag) String() string {
 	return geo.Circle(*c).String()
 }
 
+// BoxFlag is the flag type for representing rectangular regions.
+type BoxFlag geo.Box
+
+// Type returns the type string of the BoxFlag.
+func (b *BoxFlag) Type() string {
+	return "box"
+}
+
+// boxSizeRE is the regular expression that a box size string must follow.
+var boxSizeRE = regexp.MustCompile(`^\s*([^x×]+?)\s*(?:[x×]\s*(.+?))?\s*$`)
+
+// Set parses the box string and sets the value of BoxFlag.
+// The box is either given by its edges in decimal degrees, eg "south,west,north,east",
+// or by a center in any format supported by geo.ParsePoint and a size, eg "-37.8,144.9,10kmx5km".
+// A single size results in a square box.
+func (b *BoxFlag) Set(str string) error {
+	if str == "" {
+		return errors.New("unexpected empty value")
+	}
+
+	if edges, ok := parseEdges(str); ok {
+		if edges[0] < -90 || edges[0] > 90 {
+			return fmt.Errorf("south %q not within range", conv.FormatFloat(edges[0]))
+		} else if edges[2] < -90 || edges[2] > 90 {
+			return fmt.Errorf("north %q not within range", conv.FormatFloat(edges[2]))
+		} else if edges[1] < -180 || edges[1] > 180 {
+			return fmt.Errorf("west %q not within range", conv.FormatFloat(edges[1]))
+		} else if edges[3] < -180 || edges[3] > 180 {
+			return fmt.Errorf("east %q not within range", conv.FormatFloat(edges[3]))
+		} else if edges[0] >= edges[2] {
+			return errors.New("south must be less than north")
+		} else if math.Mod(edges[3]-edges[1], 360) == 0 {
+			return errors.New("west must differ from east")
+		}
+		*b = BoxFlag{
+			Min: geo.NewPointFromDegrees(edges[0], edges[1]),
+			Max: geo.NewPointFromDegrees(edges[2], edges[3]),
+		}
+		return nil
+	}
+
+	i := strings.LastIndex(str, ",")
+	if i < 0 {
+		return errors.New("invalid number of parts")
+	}
+	center, err := geo.ParsePoint(str[:i])
+	if err != nil {
+		return err
+	}
+
+	// Keep the center within the latitudes of the default Mercator projection
+	if lat := geo.RadiansToDegrees(center.Lat); lat < -85 || lat > 85 {
+		return fmt.Errorf("latitude %q not within range", conv.FormatFloat(lat))
+	}
+
+	m := boxSizeRE.FindStringSubmatch(str[i+1:])
+	if m == nil {
+		return errors.New("size format not recognized")
+	}
+	width, err := parseDistance(m[1])
+	if err != nil {
+		return errors.New("width " + err.Error())
+	}
+	height := width
+	if m[2] != "" {
+		if height, err = parseDistance(m[2]); err != nil {
+			return errors.New("height " + err.Error())
+		}
+	}
+	if width == 0 || height == 0 {
+		return errors.New("size must be positive")
+	}
+	box := geo.NewBoxAround(center, width, height)
+	if box.LonSpan() == 0 {
+		return errors.New("width must be less than the circumference at its latitude")
+	}
+	*b = BoxFlag(box)
+	return nil
+}
+
+// parseEdges parses four comma separated decimal numbers, returning false if str is not in that format.
+func parseEdges(str string) ([4]float64, bool) {
+	var edges [4]float64
+	parts := strings.Split(str, ",")
+	if len(parts) != len(edges) {
+		return edges, false
+	}
+	for i, part := range parts {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(part), 64); err != nil {
+			return edges, false
+		} else {
+			edges[i] = f
+		}
+	}
+	return edges, true
+}
+
+// String returns the string representation of the BoxFlag.
+func (b *BoxFlag) String() string {
+	if b == nil || geo.Box(*b).IsZero() {
+		return ""
+	}
+	return geo.Box(*b).String()
+}
+
+// ProjectionFlag is the flag type for map projection names.
+type ProjectionFlag string
+
+// Type returns the type string of the ProjectionFlag.
+func (p *ProjectionFlag) Type() string {
+	return "projection"
+}
+
+// Set validates the projection name and sets the value of ProjectionFlag.
+func (p *ProjectionFlag) Set(str string) error {
+	if str == "" {
+		return errors.New("unexpected empty value")
+	}
+	if _, err := geo.NewProjection(str, geo.Point{}); err != nil {
+		return errors.New("projection not recognized, supports " + strings.Join(geo.ProjectionNames(), ", "))
+	}
+	*p = ProjectionFlag(str)
+	return nil
+}
+
+// String returns the string representation of the ProjectionFlag.
+func (p *ProjectionFlag) String() string {
+	if p == nil {
+		return ""
+	}
+	return string(*p)
+}
+
 // distanceRE is the regular expression that a distance string must follow.
 var distanceRE = regexp.MustCompile(`^(.*\d)\s?(\w+)?$`)
 
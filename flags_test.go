@@ -88,6 +88,9 @@ func TestDurationSet(t *testing.T) {
 		{"1h2m3s", "1h2m3s"},
 		{"3600s", "1h0m0s"},
 		{"3600", "1h0m0s"},
+		{"30d", "720h0m0s"},
+		{"1.5d", "36h0m0s"},
+		{"xd", errors.New("duration not recognized")},
 		{"", errors.New("unexpected empty value")},
 		{"foo", errors.New("duration not recognized")},
 		{"-1h", errors.New("must be positive")},
@@ -212,8 +215,14 @@ func TestRegionSet(t *testing.T) {
 		{"1,2,3000x", errors.New(`radius unit "x" not recognized`)},
 		{"1,2,3000g", errors.New(`radius unit "g" not a distance`)},
 		{"100,0", errors.New(`latitude "100" not within range`)},
+		{"86,0", errors.New(`latitude "86" not within range`)},
 		{"0,200", errors.New(`longitude "200" not within range`)},
 		{"1,2,-3", errors.New(`radius must be positive`)},
+		{"+51.5,-0.1,2km", "51.5,-0.1,2000"},
+		{"37°48'S 144°54'E,5km", "-37.8,144.9,5000"},
+		{"geo:-37.8,144.9;u=500", "-37.8,144.9,500"},
+		{"8FVC0000+,1km", "47.5,8.5,1000"},
+		{"ezs42", "42.60498,-5.60303,100"},
 	}
 
 	for i, testCase := range testCases {
@@ -252,3 +261,128 @@ func TestRegionContains(t *testing.T) {
 		t.Fatal("expected not contains")
 	}
 }
+
+func TestBoxSet(t *testing.T) {
+	testCases := []struct {
+		set    string
+		expect any
+	}{
+		{"-37.9,144.8,-37.7,145", "-37.9,144.8,-37.7,145"},
+		{"-17,179,-16,-179", "-17,179,-16,-179"},
+		{"0,0,2222km", "-9.99146,-9.99146,9.99146,9.99146"},
+		{"0,0,2222kmx1111km", "-4.99573,-9.99146,4.99573,9.99146"},
+		{"37°48'S 144°54'E,1km", "-37.8045,144.89431,-37.7955,144.90569"},
+		{"", errors.New("unexpected empty value")},
+		{"1", errors.New("invalid number of parts")},
+		{"1,2,3,4,5", errors.New("invalid number of parts")},
+		{"-100,0,1,1", errors.New(`south "-100" not within range`)},
+		{"0,0,1,200", errors.New(`east "200" not within range`)},
+		{"1,0,0,1", errors.New("south must be less than north")},
+		{"0,-180,1,180", errors.New("west must differ from east")},
+		{"0,10,1,10", errors.New("west must differ from east")},
+		{"89,0,1km", errors.New(`latitude "89" not within range`)},
+		{"0,0,50000km", errors.New("width must be less than the circumference at its latitude")},
+		{"60,0,25000kmx1km", errors.New("width must be less than the circumference at its latitude")},
+		{"0,0,40000kmx1km", "-0.0045,-179.86432,0.0045,179.86432"},
+		{"0,0,foo", errors.New("width format not recognized")},
+		{"0,0,1kmxfoo", errors.New("height format not recognized")},
+		{"0,0,0", errors.New("size must be positive")},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			b := &geo.Box{}
+			if err := (*BoxFlag)(b).Set(testCase.set); err != nil {
+				if expectErr, ok := testCase.expect.(error); !ok {
+					t.Fatal(err)
+				} else if !strings.Contains(err.Error(), expectErr.Error()) {
+					t.Fatal(err, "!=", testCase.expect)
+				} else if !b.IsZero() {
+					t.Fatal("expected zero")
+				} else {
+					return
+				}
+			}
+			actual := b.String()
+			if actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+func TestSportColorsSet(t *testing.T) {
+	testCases := []struct {
+		set    string
+		expect any
+	}{
+		{"running=#f80,#fff", "running=#f80,#fff"},
+		{"running=#f80,#fff;Cycling=#08f,#fff", "cycling=#08f,#fff;running=#f80,#fff"},
+		{"running=red", "running=#f00"},
+		{"", errors.New("unexpected empty value")},
+		{"running", errors.New(`sport colors "running" not recognized`)},
+		{"=#fff", errors.New(`sport colors "=#fff" not recognized`)},
+		{"running=foo", errors.New(`color "foo" not recognized`)},
+		{"running=#fff;", errors.New(`sport colors "" not recognized`)},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			s := &SportColorsFlag{}
+			if err := s.Set(testCase.set); err != nil {
+				if expectErr, ok := testCase.expect.(error); !ok {
+					t.Fatal(err)
+				} else if !strings.Contains(err.Error(), expectErr.Error()) {
+					t.Fatal(err, "!=", testCase.expect)
+				} else {
+					return
+				}
+			}
+			actual := s.String()
+			if actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+func TestCameraSet(t *testing.T) {
+	testCases := []struct {
+		set    string
+		expect any
+	}{
+		{"-37.8,144.9,2x", "-37.8,144.9,2x@0"},
+		{"-37.8,144.9,1x;-37.9,144.8,-37.7,145", "-37.8,144.9,1x@0;-37.9,144.8,-37.7,145@1"},
+		{"-37.8,144.9,1x;-37.8,144.9,2x;-37.8,144.9,4x@60%;-37.8,144.9,8x@0.8", "-37.8,144.9,1x@0;-37.8,144.9,2x@0.3;-37.8,144.9,4x@0.6;-37.8,144.9,8x@0.8"},
+		{"-37.8,144.9,2kmx1km@0.5", "-37.8045,144.88862,-37.7955,144.91138@0.5"},
+		{"", errors.New("unexpected empty value")},
+		{"-37.8,144.9,0x", errors.New("zoom must be positive")},
+		{"-37.8,144.9,fastx", errors.New(`zoom "fastx" not recognized`)},
+		{"-37.8,144.9,2x@2", errors.New(`position "2" not within range`)},
+		{"-37.8,144.9,2x@soon", errors.New(`position "soon" not recognized`)},
+		{"-37.8,144.9,2x@0.5;-37.8,144.9,1x@0.2", errors.New(`keyframe "-37.8,144.9,1x@0.2" out of order`)},
+		{"-37.8,144.9,2x;", errors.New("unexpected empty value")},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			c := &CameraFlag{}
+			if err := c.Set(testCase.set); err != nil {
+				if expectErr, ok := testCase.expect.(error); !ok {
+					t.Fatal(err)
+				} else if !strings.Contains(err.Error(), expectErr.Error()) {
+					t.Fatal(err, "!=", testCase.expect)
+				} else {
+					return
+				}
+			}
+			actual := c.String()
+			if actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+			if err := c.Set(actual); err != nil || c.String() != actual {
+				t.Fatal(c.String(), err, "!=", actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package geo
+
+import (
+	"errors"
+	"math"
+)
+
+// Fit maps the projected coordinates of a region onto the pixels of an image.
+type Fit struct {
+	Projection Projection // The projection centered on the region
+	Lon        float64    // The longitude that points are unwrapped around before they are projected
+	MinX, MaxY float64    // The projected coordinates of the top-left corner of the image
+	Scale      float64    // The number of pixels per projected unit
+	Height     int        // The height of the image in pixels
+}
+
+// NewFit fits a region onto an image width pixels wide with the named projection.
+// The region is the viewport if it is not zero; otherwise, it is the points reported to enclose by points,
+// centered on their extent and surrounded by margins of 5% of the image.
+// A region without any width or height is stretched to a square, and a region without either can't be fitted.
+func NewFit(projection string, viewport, extent Box, width uint, points func(enclose func(pt Point))) (Fit, error) {
+	// Create the projection centered on the region
+	if !viewport.IsZero() {
+		extent = viewport
+	}
+	center := extent.Center()
+	proj, err := NewProjection(projection, center)
+	if err != nil {
+		return Fit{}, err
+	}
+
+	// Calculate the projected region, either around the viewport edges or all points
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	enclose := func(pt Point) {
+		x, y := proj.Project(pt.Unwrap(center.Lon))
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	if !viewport.IsZero() {
+		for _, pt := range viewport.Boundary(16) {
+			enclose(pt)
+		}
+	} else {
+		points(enclose)
+	}
+
+	// Stretch a region without any width or height, such as a single north-south line, to a square
+	dX, dY := maxX-minX, maxY-minY
+	if dX == 0 {
+		minX -= dY / 2
+		dX = dY
+	} else if dY == 0 {
+		maxY += dX / 2
+		dY = dX
+	}
+	if !(dX > 0) {
+		return Fit{}, errors.New("region has no size to fit")
+	}
+
+	// Calculate scaling factors
+	f := Fit{Projection: proj, Lon: center.Lon, MinX: minX, MaxY: maxY, Scale: float64(width) / dX}
+	f.Height = int(dY * f.Scale)
+	// Add margins when fitting to the points
+	if viewport.IsZero() {
+		f.Scale *= 0.9
+		f.MinX -= 0.05 * dX
+		f.MaxY += 0.05 * dY
+	}
+	return f, nil
+}
+
+// Pixel returns the position in the image of Point pt.
+func (f Fit) Pixel(pt Point) (float64, float64) {
+	x, y := f.Projection.Project(pt.Unwrap(f.Lon))
+	return (x - f.MinX) * f.Scale, (f.MaxY - y) * f.Scale
+}
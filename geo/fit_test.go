@@ -0,0 +1,54 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestNewFit(t *testing.T) {
+	testCases := []struct {
+		viewport     Box
+		points       []Point
+		expectHeight int
+		expectErr    bool
+	}{
+		{Box{}, []Point{NewPointFromDegrees(-37.8, 144.9), NewPointFromDegrees(-37.9, 145.0)}, 126, false},
+		{NewBoxAround(NewPointFromDegrees(-37.8, 144.9), 2000, 1000), nil, 50, false},
+		{Box{}, []Point{NewPointFromDegrees(-37.8, 144.9), NewPointFromDegrees(-37.9, 144.9)}, 100, false},
+		{Box{}, []Point{NewPointFromDegrees(-37.8, 144.9), NewPointFromDegrees(-37.8, 145.0)}, 100, false},
+		{Box{}, []Point{NewPointFromDegrees(-37.8, 144.9)}, 0, true},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			var extent Box
+			for _, pt := range testCase.points {
+				extent = extent.Enclose(pt)
+			}
+			f, err := NewFit("mercator", testCase.viewport, extent, 100, func(enclose func(pt Point)) {
+				for _, pt := range testCase.points {
+					enclose(pt)
+				}
+			})
+			if (err != nil) != testCase.expectErr {
+				t.Fatal(err)
+			} else if err != nil {
+				return
+			}
+			if f.Height != testCase.expectHeight {
+				t.Fatal(f.Height, "!=", testCase.expectHeight)
+			}
+			if math.IsInf(f.Scale, 0) || math.IsNaN(f.Scale) {
+				t.Fatal(f.Scale)
+			}
+
+			// Every point falls inside the image
+			for _, pt := range testCase.points {
+				if x, y := f.Pixel(pt); x < 0 || x > 100 || y < 0 || y > float64(f.Height) {
+					t.Fatal(x, y)
+				}
+			}
+		})
+	}
+}
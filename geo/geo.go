@@ -29,6 +29,15 @@ func SemicirclesToRadians(s int32) float64 {
 	return float64(s) * math.Pi / math.MaxInt32
 }
 
+// NormalizeLon wraps longitude lon (in radians) into the range (-π, π].
+func NormalizeLon(lon float64) float64 {
+	lon = math.Mod(lon+math.Pi, 2*math.Pi)
+	if lon <= 0 {
+		lon += 2 * math.Pi
+	}
+	return lon - math.Pi
+}
+
 // NewPointFromDegrees returns a new Point from lat and lon, where lat and lon are in degrees.
 func NewPointFromDegrees(lat, lon float64) Point {
 	return Point{Lat: DegreesToRadians(lat), Lon: DegreesToRadians(lon)}
@@ -54,6 +63,13 @@ func (p Point) IsZero() bool {
 	return p.Lat == 0 && p.Lon == 0
 }
 
+// Unwrap returns p with its Lon shifted by whole turns so that it lies within π of longitude lon.
+// This keeps paths that cross the antimeridian continuous when projected.
+func (p Point) Unwrap(lon float64) Point {
+	p.Lon = lon + NormalizeLon(p.Lon-lon)
+	return p
+}
+
 // DistanceTo calculates the haversine distance (in meters) between Point p and Point pt on Earth's surface.
 func (p Point) DistanceTo(pt Point) float64 {
 	sinLat := math.Sin((pt.Lat - p.Lat) / 2)
@@ -102,33 +118,124 @@ func (c Circle) Grow(factor float64) Circle {
 	return c
 }
 
-// Box is a grid alligned rectangle represented by 2 Points.
-// Min is the corner with the smallest Lat and Lon and
-// Max is the corner with the largest Lat and Lon.
+// Box is a grid aligned rectangle represented by 2 Points.
+// Min is the south-west corner and Max is the north-east corner.
+// A Box that crosses the antimeridian has a Min.Lon greater than its Max.Lon.
 type Box struct {
 	Min, Max Point
 }
 
+// NewBoxAround returns a Box centered on Point center that is width meters wide and height meters high.
+func NewBoxAround(center Point, width, height float64) Box {
+	dLat := height / 2 / haversineRadius
+	dLon := math.Min(width/2/(haversineRadius*math.Cos(center.Lat)), math.Pi)
+	return Box{
+		Min: Point{Lat: math.Max(center.Lat-dLat, -math.Pi/2), Lon: NormalizeLon(center.Lon - dLon)},
+		Max: Point{Lat: math.Min(center.Lat+dLat, math.Pi/2), Lon: NormalizeLon(center.Lon + dLon)},
+	}
+}
+
+// String returns Box b as a string of format "south,west,north,east", where each edge is in degrees.
+func (b Box) String() string {
+	return fmt.Sprintf("%s,%s,%s,%s",
+		conv.FormatFloat(RadiansToDegrees(b.Min.Lat)),
+		conv.FormatFloat(RadiansToDegrees(b.Min.Lon)),
+		conv.FormatFloat(RadiansToDegrees(b.Max.Lat)),
+		conv.FormatFloat(RadiansToDegrees(b.Max.Lon)),
+	)
+}
+
 // IsZero returns true is both b.Min and b.Max are zero.
 func (b Box) IsZero() bool {
 	return b.Min.IsZero() && b.Max.IsZero()
 }
 
+// LonSpan returns the eastward angular width (in radians) of the Box from b.Min.Lon to b.Max.Lon.
+func (b Box) LonSpan() float64 {
+	if span := NormalizeLon(b.Max.Lon - b.Min.Lon); span >= 0 {
+		return span
+	} else {
+		return span + 2*math.Pi
+	}
+}
+
+// Size returns the width and height of the Box in meters, with the width measured along its central parallel.
+func (b Box) Size() (width, height float64) {
+	return b.LonSpan() * haversineRadius * math.Cos(b.Center().Lat), (b.Max.Lat - b.Min.Lat) * haversineRadius
+}
+
 // Center returns a Point of the center of the Box.
 func (b Box) Center() Point {
-	return Point{Lat: (b.Max.Lat + b.Min.Lat) / 2, Lon: (b.Max.Lon + b.Min.Lon) / 2}
+	return Point{Lat: (b.Max.Lat + b.Min.Lat) / 2, Lon: NormalizeLon(b.Min.Lon + b.LonSpan()/2)}
+}
+
+// Grow returns a Box with the same center that is factor times larger than b in both directions.
+func (b Box) Grow(factor float64) Box {
+	c := b.Center()
+	dLat := (b.Max.Lat - b.Min.Lat) / 2 * factor
+	dLon := math.Min(b.LonSpan()/2*factor, math.Pi)
+	b.Min = Point{Lat: math.Max(c.Lat-dLat, -math.Pi/2), Lon: NormalizeLon(c.Lon - dLon)}
+	b.Max = Point{Lat: math.Min(c.Lat+dLat, math.Pi/2), Lon: NormalizeLon(c.Lon + dLon)}
+	return b
+}
+
+// Boundary returns n Points evenly spaced along each edge of the Box, starting from the south-west corner.
+func (b Box) Boundary(n int) []Point {
+	pts := make([]Point, 0, 4*n)
+	span := b.LonSpan()
+	for i := 0; i < n; i++ {
+		f := float64(i) / float64(n)
+		lat := b.Min.Lat + f*(b.Max.Lat-b.Min.Lat)
+		lon := b.Min.Lon + f*span
+		pts = append(pts,
+			Point{Lat: b.Min.Lat, Lon: NormalizeLon(lon)},
+			Point{Lat: lat, Lon: NormalizeLon(b.Min.Lon + span)},
+			Point{Lat: b.Max.Lat, Lon: NormalizeLon(b.Min.Lon + span - f*span)},
+			Point{Lat: b.Max.Lat - f*(b.Max.Lat-b.Min.Lat), Lon: b.Min.Lon},
+		)
+	}
+	return pts
+}
+
+// Contains returns true if Point pt is within the Box.
+func (b Box) Contains(pt Point) bool {
+	return pt.Lat >= b.Min.Lat && pt.Lat <= b.Max.Lat && b.containsLon(pt.Lon)
+}
+
+// containsLon returns true if longitude lon falls between b.Min.Lon and b.Max.Lon travelling east.
+func (b Box) containsLon(lon float64) bool {
+	d := NormalizeLon(lon - b.Min.Lon)
+	if d < 0 {
+		d += 2 * math.Pi
+	}
+	return d <= b.LonSpan()
 }
 
 // Enclose returns the smallest Box >= b such that Point pt is within the Box.
+// The Box is grown east or west, whichever results in the narrower Box,
+// so that extents crossing the antimeridian remain compact.
 func (b Box) Enclose(pt Point) Box {
 	if b.IsZero() {
 		b.Min = pt
 		b.Max = pt
-	} else {
-		b.Min.Lat = math.Min(b.Min.Lat, pt.Lat)
-		b.Max.Lat = math.Max(b.Max.Lat, pt.Lat)
-		b.Min.Lon = math.Min(b.Min.Lon, pt.Lon)
-		b.Max.Lon = math.Max(b.Max.Lon, pt.Lon)
+		return b
+	}
+	b.Min.Lat = math.Min(b.Min.Lat, pt.Lat)
+	b.Max.Lat = math.Max(b.Max.Lat, pt.Lat)
+	if !b.containsLon(pt.Lon) {
+		east := NormalizeLon(pt.Lon - b.Max.Lon)
+		if east < 0 {
+			east += 2 * math.Pi
+		}
+		west := NormalizeLon(b.Min.Lon - pt.Lon)
+		if west < 0 {
+			west += 2 * math.Pi
+		}
+		if east <= west {
+			b.Max.Lon = pt.Lon
+		} else {
+			b.Min.Lon = pt.Lon
+		}
 	}
 	return b
 }
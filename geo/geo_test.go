@@ -31,3 +31,71 @@ func TestMercatorProjection(t *testing.T) {
 		})
 	}
 }
+
+func TestBoxEncloseAntimeridian(t *testing.T) {
+	b := Box{}.
+		Enclose(NewPointFromDegrees(-17, 179.5)).
+		Enclose(NewPointFromDegrees(-18, -179.5)).
+		Enclose(NewPointFromDegrees(-16, 179))
+	if span := RadiansToDegrees(b.LonSpan()); math.Abs(span-1.5) > 1e-9 {
+		t.Fatal("span", span, "!=", 1.5)
+	}
+	if c := b.Center(); math.Abs(RadiansToDegrees(c.Lon)-179.75) > 1e-9 || math.Abs(RadiansToDegrees(c.Lat)+17) > 1e-9 {
+		t.Fatal("unexpected center", c)
+	}
+	if !b.Contains(NewPointFromDegrees(-17, 180)) {
+		t.Fatal("expected contains")
+	}
+	if b.Contains(NewPointFromDegrees(-17, 0)) {
+		t.Fatal("expected not contains")
+	}
+}
+
+func TestBoxEncloseNormal(t *testing.T) {
+	b := Box{}.
+		Enclose(NewPointFromDegrees(-37, 144)).
+		Enclose(NewPointFromDegrees(-38, 145))
+	if c := b.Center(); c.String() != "-37.5,144.5" {
+		t.Fatal("unexpected center", c)
+	}
+}
+
+func TestPointUnwrap(t *testing.T) {
+	pt := NewPointFromDegrees(0, -179).Unwrap(DegreesToRadians(179))
+	if lon := RadiansToDegrees(pt.Lon); math.Abs(lon-181) > 1e-9 {
+		t.Fatal(lon, "!=", 181)
+	}
+}
+
+func TestNewBoxAround(t *testing.T) {
+	c := NewPointFromDegrees(-37.8, 144.9)
+	b := NewBoxAround(c, 2000, 1000)
+	if w := (Point{Lat: c.Lat, Lon: b.Min.Lon}).DistanceTo(Point{Lat: c.Lat, Lon: b.Max.Lon}); math.Abs(w-2000) > 1 {
+		t.Fatal("width", w, "!=", 2000)
+	}
+	if h := b.Min.DistanceTo(Point{Lat: b.Max.Lat, Lon: b.Min.Lon}); math.Abs(h-1000) > 1 {
+		t.Fatal("height", h, "!=", 1000)
+	}
+	if w, h := b.Size(); math.Abs(w-2000) > 1e-6 || math.Abs(h-1000) > 1e-6 {
+		t.Fatal("size", w, h, "!=", 2000, 1000)
+	}
+	if b.Center().DistanceTo(c) > 1e-6 {
+		t.Fatal("unexpected center", b.Center())
+	}
+	if g := b.Grow(2); math.Abs(g.LonSpan()-2*b.LonSpan()) > 1e-12 {
+		t.Fatal("unexpected grow", g)
+	}
+}
+
+func TestBoxGeoJSON(t *testing.T) {
+	b := Box{Min: NewPointFromDegrees(-18, 179), Max: NewPointFromDegrees(-16, -179)}
+	if g := b.GeoJSON().Geometry; g.Type != "MultiPolygon" {
+		t.Fatal(g.Type, "!=", "MultiPolygon")
+	}
+	b = Box{Min: NewPointFromDegrees(-38, 144), Max: NewPointFromDegrees(-37, 145)}
+	if g := b.GeoJSON().Geometry; g.Type != "Polygon" {
+		t.Fatal(g.Type, "!=", "Polygon")
+	} else if sw := g.Coordinates.([][][]float64)[0][0]; sw[0] != 144 || sw[1] != -38 {
+		t.Fatal("unexpected south-west corner", sw)
+	}
+}
@@ -0,0 +1,62 @@
+package geo
+
+import "math"
+
+// Feature is a GeoJSON feature as defined by RFC 7946.
+type Feature struct {
+	Type       string         `json:"type" yaml:"type"`
+	Geometry   Geometry       `json:"geometry" yaml:"geometry"`
+	Properties map[string]any `json:"properties" yaml:"properties"`
+}
+
+// Geometry is a GeoJSON geometry as defined by RFC 7946.
+type Geometry struct {
+	Type        string `json:"type" yaml:"type"`
+	Coordinates any    `json:"coordinates" yaml:"coordinates"`
+}
+
+// position returns the GeoJSON position of p as longitude then latitude in degrees.
+func (p Point) position() []float64 {
+	return []float64{roundDegrees(RadiansToDegrees(p.Lon)), roundDegrees(RadiansToDegrees(p.Lat))}
+}
+
+// roundDegrees rounds d to 7 decimal places, roughly centimeter precision.
+func roundDegrees(d float64) float64 {
+	return math.Round(d*1e7) / 1e7
+}
+
+// GeoJSON returns p as a GeoJSON Point geometry.
+func (p Point) GeoJSON() Geometry {
+	return Geometry{Type: "Point", Coordinates: p.position()}
+}
+
+// GeoJSON returns c as a GeoJSON Point feature with its radius in meters as the "radius" property.
+func (c Circle) GeoJSON() Feature {
+	return Feature{
+		Type:       "Feature",
+		Geometry:   c.Origin.GeoJSON(),
+		Properties: map[string]any{"radius": c.Radius},
+	}
+}
+
+// GeoJSON returns b as a GeoJSON Polygon feature.
+// A Box that crosses the antimeridian is split into a MultiPolygon as recommended by RFC 7946.
+func (b Box) GeoJSON() Feature {
+	ring := func(west, east float64) [][]float64 {
+		sw := Point{Lat: b.Min.Lat, Lon: west}.position()
+		se := Point{Lat: b.Min.Lat, Lon: east}.position()
+		ne := Point{Lat: b.Max.Lat, Lon: east}.position()
+		nw := Point{Lat: b.Max.Lat, Lon: west}.position()
+		return [][]float64{sw, se, ne, nw, sw}
+	}
+	f := Feature{Type: "Feature", Properties: map[string]any{}}
+	if b.Min.Lon <= b.Max.Lon {
+		f.Geometry = Geometry{Type: "Polygon", Coordinates: [][][]float64{ring(b.Min.Lon, b.Max.Lon)}}
+	} else {
+		f.Geometry = Geometry{Type: "MultiPolygon", Coordinates: [][][][]float64{
+			{ring(b.Min.Lon, math.Pi)},
+			{ring(-math.Pi, b.Max.Lon)},
+		}}
+	}
+	return f
+}
@@ -0,0 +1,298 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+)
+
+// ParsePoint parses a Point from str in any of the following formats:
+//   - decimal degrees, eg "-37.8,144.9" or "-37.8 144.9"
+//   - degrees, minutes and seconds, eg "37°48'S 144°57'E" or "S37d48m, E144d57m"
+//   - full Open Location Codes (plus codes), eg "4RJ65WQ2+XX"
+//   - geohashes, eg "r1r0fsn"
+//   - geo URIs, eg "geo:-37.8,144.9;u=35"
+//
+// All formats are decoded offline. Plus codes and geohashes resolve to the center of their cell.
+func ParsePoint(str string) (Point, error) {
+	str = strings.TrimSpace(str)
+	switch {
+	case str == "":
+		return Point{}, errors.New("unexpected empty value")
+	case strings.HasPrefix(strings.ToLower(str), "geo:"):
+		return parseGeoURI(str)
+	case isPlusCode(str):
+		return parsePlusCode(str)
+	case isGeohash(str):
+		return parseGeohash(str)
+	case dmsRE.MatchString(str):
+		return parseDMS(str)
+	default:
+		return parseDecimal(str)
+	}
+}
+
+// newCheckedPoint returns a new Point from lat and lon in degrees, ensuring both are within range.
+func newCheckedPoint(lat, lon float64) (Point, error) {
+	if lat < -90 || lat > 90 {
+		return Point{}, fmt.Errorf("latitude %q not within range", conv.FormatFloat(lat))
+	} else if lon < -180 || lon > 180 {
+		return Point{}, fmt.Errorf("longitude %q not within range", conv.FormatFloat(lon))
+	}
+	return NewPointFromDegrees(lat, lon), nil
+}
+
+// parseDecimal parses a Point from comma or whitespace separated decimal degrees.
+func parseDecimal(str string) (Point, error) {
+	parts := strings.Split(str, ",")
+	if len(parts) == 1 {
+		parts = strings.Fields(str)
+	}
+	if len(parts) != 2 {
+		return Point{}, errors.New("invalid number of parts")
+	}
+	parts[0], parts[1] = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	if lat, err := strconv.ParseFloat(parts[0], 64); err != nil {
+		return Point{}, fmt.Errorf("latitude %q not recognized", parts[0])
+	} else if lon, err := strconv.ParseFloat(parts[1], 64); err != nil {
+		return Point{}, fmt.Errorf("longitude %q not recognized", parts[1])
+	} else {
+		return newCheckedPoint(lat, lon)
+	}
+}
+
+// parseGeoURI parses a Point from an RFC 5870 geo URI, ignoring any altitude and parameters.
+func parseGeoURI(str string) (Point, error) {
+	str = str[4:]
+	if i := strings.Index(str, ";"); i >= 0 {
+		str = str[:i]
+	}
+	parts := strings.Split(str, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Point{}, errors.New("invalid number of parts")
+	}
+	return parseDecimal(parts[0] + "," + parts[1])
+}
+
+var (
+	// dmsRE is the regular expression that identifies a degrees, minutes and seconds string.
+	dmsRE = regexp.MustCompile(`[°º′″'"]|(?i)\d\s*[NSEW]\b|^\s*[NSEW]\s*\d`)
+	// dmsPartRE is the regular expression that a single degrees, minutes and seconds coordinate must follow.
+	dmsPartRE = regexp.MustCompile(`(?i)^([NSEW])?\s*(-?\d+(?:\.\d+)?)\s*(?:[°º]|d)?\s*(?:(\d+(?:\.\d+)?)\s*(?:['′]|m)?\s*)?(?:(\d+(?:\.\d+)?)\s*(?:"|″|'')?\s*)?([NSEW])?$`)
+)
+
+// dmsPart is a single parsed degrees, minutes and seconds coordinate.
+type dmsPart struct {
+	val  float64 // val is the signed coordinate in degrees
+	hemi string  // hemi is the upper case hemisphere letter, if any
+}
+
+// parseDMSPart parses a single degrees, minutes and seconds coordinate.
+func parseDMSPart(str string) (dmsPart, error) {
+	str = strings.TrimSpace(str)
+	m := dmsPartRE.FindStringSubmatch(str)
+	if m == nil || (m[1] != "" && m[5] != "") {
+		return dmsPart{}, fmt.Errorf("coordinate %q not recognized", str)
+	}
+	deg, _ := strconv.ParseFloat(m[2], 64)
+	neg := strings.HasPrefix(m[2], "-")
+	if neg {
+		deg = -deg
+	}
+	if m[3] != "" {
+		mins, _ := strconv.ParseFloat(m[3], 64)
+		if mins >= 60 {
+			return dmsPart{}, fmt.Errorf("minutes %q not within range", m[3])
+		}
+		deg += mins / 60
+	}
+	if m[4] != "" {
+		secs, _ := strconv.ParseFloat(m[4], 64)
+		if secs >= 60 {
+			return dmsPart{}, fmt.Errorf("seconds %q not within range", m[4])
+		}
+		deg += secs / 3600
+	}
+	p := dmsPart{val: deg, hemi: strings.ToUpper(m[1] + m[5])}
+	if p.hemi == "S" || p.hemi == "W" {
+		if neg {
+			return dmsPart{}, fmt.Errorf("coordinate %q has conflicting signs", str)
+		}
+		neg = true
+	}
+	if neg {
+		p.val = -p.val
+	}
+	return p, nil
+}
+
+// parseDMS parses a Point from a pair of degrees, minutes and seconds coordinates.
+// Coordinates without a hemisphere letter are assumed to be latitude then longitude.
+func parseDMS(str string) (Point, error) {
+	var parts [2]dmsPart
+	if pair := strings.Split(str, ","); len(pair) == 2 {
+		var err error
+		if parts[0], err = parseDMSPart(pair[0]); err != nil {
+			return Point{}, err
+		}
+		if parts[1], err = parseDMSPart(pair[1]); err != nil {
+			return Point{}, err
+		}
+	} else if len(pair) > 2 {
+		return Point{}, errors.New("invalid number of parts")
+	} else {
+		// Without a comma, try every whitespace split and prefer the one with the most hemisphere letters
+		fields := strings.Fields(str)
+		best := -1
+		err := errors.New("invalid number of parts")
+		for i := 1; i < len(fields); i++ {
+			p0, err0 := parseDMSPart(strings.Join(fields[:i], " "))
+			p1, err1 := parseDMSPart(strings.Join(fields[i:], " "))
+			if err0 != nil {
+				err = err0
+				continue
+			} else if err1 != nil {
+				err = err1
+				continue
+			}
+			score := 0
+			if p0.hemi != "" {
+				score++
+			}
+			if p1.hemi != "" {
+				score++
+			}
+			if score >= best {
+				best = score
+				parts = [2]dmsPart{p0, p1}
+			}
+		}
+		if best < 0 {
+			return Point{}, err
+		}
+	}
+
+	latFirst := parts[0].hemi == "N" || parts[0].hemi == "S" || parts[1].hemi == "E" || parts[1].hemi == "W"
+	lonFirst := parts[0].hemi == "E" || parts[0].hemi == "W" || parts[1].hemi == "N" || parts[1].hemi == "S"
+	switch {
+	case latFirst && lonFirst:
+		return Point{}, errors.New("conflicting hemispheres")
+	case lonFirst:
+		return newCheckedPoint(parts[1].val, parts[0].val)
+	default:
+		return newCheckedPoint(parts[0].val, parts[1].val)
+	}
+}
+
+// geohashAlphabet is the base32 alphabet used by geohashes.
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// isGeohash returns true if str looks like a geohash.
+// Purely numeric strings are not considered geohashes to avoid ambiguity with numbers.
+func isGeohash(str string) bool {
+	if len(str) > 12 {
+		return false
+	}
+	letter := false
+	for _, c := range strings.ToLower(str) {
+		if !strings.ContainsRune(geohashAlphabet, c) {
+			return false
+		}
+		if c > '9' {
+			letter = true
+		}
+	}
+	return letter
+}
+
+// parseGeohash parses a Point from the center of the geohash cell str.
+func parseGeohash(str string) (Point, error) {
+	lat := [2]float64{-90, 90}
+	lon := [2]float64{-180, 180}
+	even := true
+	for _, c := range strings.ToLower(str) {
+		v := strings.IndexRune(geohashAlphabet, c)
+		if v < 0 {
+			return Point{}, fmt.Errorf("geohash character %q not recognized", c)
+		}
+		for bit := 4; bit >= 0; bit-- {
+			rng := &lat
+			if even {
+				rng = &lon
+			}
+			mid := (rng[0] + rng[1]) / 2
+			if v&(1<<bit) != 0 {
+				rng[0] = mid
+			} else {
+				rng[1] = mid
+			}
+			even = !even
+		}
+	}
+	return newCheckedPoint((lat[0]+lat[1])/2, (lon[0]+lon[1])/2)
+}
+
+// plusCodeAlphabet is the base20 alphabet used by Open Location Codes.
+const plusCodeAlphabet = "23456789CFGHJMPQRVWX"
+
+// plusCodeRE is the regular expression that identifies an Open Location Code by its alphabet, padding and separator position.
+var plusCodeRE = regexp.MustCompile(`(?i)^[23456789CFGHJMPQRVWX0]{2,8}\+[23456789CFGHJMPQRVWX]*$`)
+
+// isPlusCode returns true if str looks like an Open Location Code, full or short.
+// Signed decimal degrees such as "+51.5,-0.1" are not considered plus codes since they contain characters outside the alphabet.
+func isPlusCode(str string) bool {
+	return plusCodeRE.MatchString(str)
+}
+
+// parsePlusCode parses a Point from the center of the area of a full Open Location Code str.
+// Short codes are rejected since they need a reference location to be recovered.
+func parsePlusCode(str string) (Point, error) {
+	code := strings.ToUpper(str)
+	sep := strings.Index(code, "+")
+	if sep != strings.LastIndex(code, "+") || sep%2 != 0 || sep > 8 {
+		return Point{}, fmt.Errorf("plus code %q not recognized", str)
+	}
+	if sep < 8 {
+		return Point{}, fmt.Errorf("short plus code %q not supported, use a full code", str)
+	}
+	if pad := strings.Index(code, "0"); pad >= 0 {
+		if pad == 0 || pad%2 != 0 || len(code) > sep+1 || strings.Trim(code[pad:sep], "0") != "" {
+			return Point{}, fmt.Errorf("plus code %q not recognized", str)
+		}
+		code = code[:pad]
+	} else if len(code) == sep+2 {
+		return Point{}, fmt.Errorf("plus code %q not recognized", str)
+	}
+	code = strings.Replace(code, "+", "", 1)
+	if len(code) > 15 {
+		code = code[:15]
+	}
+
+	lat, lon := -90.0, -180.0
+	latRes, lonRes := 400.0, 400.0
+	for i, c := range code {
+		v := strings.IndexRune(plusCodeAlphabet, c)
+		if v < 0 {
+			return Point{}, fmt.Errorf("plus code character %q not recognized", c)
+		}
+		if i < 10 {
+			if i%2 == 0 {
+				latRes /= 20
+				lat += float64(v) * latRes
+			} else {
+				lonRes /= 20
+				lon += float64(v) * lonRes
+			}
+		} else {
+			latRes /= 5
+			lonRes /= 4
+			lat += float64(v/4) * latRes
+			lon += float64(v%4) * lonRes
+		}
+	}
+	return newCheckedPoint(lat+latRes/2, lon+lonRes/2)
+}
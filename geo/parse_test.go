@@ -0,0 +1,85 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParsePoint(t *testing.T) {
+	testCases := []struct {
+		set    string
+		expect any
+	}{
+		{"-37.8,144.9", "-37.8,144.9"},
+		{"-37.8 144.9", "-37.8,144.9"},
+		{"37°48'S 144°57'E", "-37.8,144.95"},
+		{"37°48′S, 144°57′E", "-37.8,144.95"},
+		{"144°57'E 37°48'S", "-37.8,144.95"},
+		{"S37d48m, E144d57m", "-37.8,144.95"},
+		{"37 48 S 144 57 E", "-37.8,144.95"},
+		{`51°30'26"N 0°7'39"W`, "51.50722,-0.1275"},
+		{"-37.8°, 144.9°", "-37.8,144.9"},
+		{"geo:-37.8,144.9", "-37.8,144.9"},
+		{"geo:-37.8,144.9,12;u=35", "-37.8,144.9"},
+		{"8FVC0000+", "47.5,8.5"},
+		{"+51.5,-0.1", "51.5,-0.1"},
+		{"+51.5 +0.1", "51.5,0.1"},
+		{"", errors.New("unexpected empty value")},
+		{"1", errors.New("invalid number of parts")},
+		{"foo,1", errors.New(`latitude "foo" not recognized`)},
+		{"100,0", errors.New(`latitude "100" not within range`)},
+		{"0,200", errors.New(`longitude "200" not within range`)},
+		{"37°78'S 144°57'E", errors.New(`minutes "78" not within range`)},
+		{"37°48'S 37°48'N", errors.New("conflicting hemispheres")},
+		{"geo:1", errors.New("invalid number of parts")},
+		{"5WQ2+XX", errors.New("short plus code")},
+		{"8FVC9G8F+6", errors.New("not recognized")},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			pt, err := ParsePoint(testCase.set)
+			if err != nil {
+				if expectErr, ok := testCase.expect.(error); !ok {
+					t.Fatal(err)
+				} else if !strings.Contains(err.Error(), expectErr.Error()) {
+					t.Fatal(err, "!=", testCase.expect)
+				} else {
+					return
+				}
+			}
+			actual := pt.String()
+			if actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+func TestParsePointCells(t *testing.T) {
+	testCases := []struct {
+		set      string
+		lat, lon float64
+		radius   float64
+	}{
+		{"u4pruydqqvj", 57.64911, 10.40744, 1},
+		{"ezs42", 42.6, -5.6, 3000},
+		{"8FVC9G8F+6X", 47.365562, 8.524813, 10},
+		{"8fvc9g8f+6xq", 47.365562, 8.524813, 20},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			pt, err := ParsePoint(testCase.set)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect := NewPointFromDegrees(testCase.lat, testCase.lon)
+			if d := pt.DistanceTo(expect); d > testCase.radius {
+				t.Fatal(pt, "!=", expect, "distance", d)
+			}
+		})
+	}
+}
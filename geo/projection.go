@@ -0,0 +1,145 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Projection maps Points on Earth's surface onto a flat plane.
+type Projection interface {
+	// Project returns the planar coordinates (in meters) of Point pt.
+	Project(pt Point) (x, y float64)
+	// Scale returns the factor by which distances along the parallel through Point pt are stretched.
+	Scale(pt Point) float64
+}
+
+// projections maps projection names to constructors that center the projection on a given origin.
+var projections = map[string]func(origin Point) Projection{
+	"mercator":            func(Point) Projection { return WebMercator{} },
+	"equirectangular":     func(origin Point) Projection { return Equirectangular{Origin: origin} },
+	"transverse_mercator": func(origin Point) Projection { return NewTransverseMercator(origin) },
+	"utm":                 func(origin Point) Projection { return NewUTM(origin) },
+	"lambert":             func(origin Point) Projection { return LambertAzimuthal{Origin: origin} },
+}
+
+// NewProjection returns the Projection with the given name centered on origin.
+// An empty name results in the default WebMercator projection.
+func NewProjection(name string, origin Point) (Projection, error) {
+	if name == "" {
+		return WebMercator{}, nil
+	}
+	if fn, ok := projections[name]; ok {
+		return fn(origin), nil
+	}
+	return nil, fmt.Errorf("projection %q not recognized", name)
+}
+
+// ProjectionNames returns the sorted names of all supported projections.
+func ProjectionNames() []string {
+	names := make([]string, 0, len(projections))
+	for name := range projections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WebMercator is the spherical Mercator projection used by most web maps.
+// It is conformal but badly distorts areas at high latitudes and is undefined at the poles.
+type WebMercator struct{}
+
+// Project returns the Mercator coordinates of Point pt.
+func (WebMercator) Project(pt Point) (float64, float64) {
+	return pt.MercatorProjection()
+}
+
+// Scale returns the Mercator scale factor at the latitude of Point pt.
+func (WebMercator) Scale(pt Point) float64 {
+	return 1 / math.Cos(pt.Lat)
+}
+
+// Equirectangular is the plate carrée projection with its standard parallel through Origin.
+type Equirectangular struct {
+	Origin Point
+}
+
+// Project returns the equirectangular coordinates of Point pt.
+func (p Equirectangular) Project(pt Point) (float64, float64) {
+	x := haversineRadius * NormalizeLon(pt.Lon-p.Origin.Lon) * math.Cos(p.Origin.Lat)
+	y := haversineRadius * pt.Lat
+	return x, y
+}
+
+// Scale returns the equirectangular scale factor along the parallel at the latitude of Point pt.
+func (p Equirectangular) Scale(pt Point) float64 {
+	return math.Cos(p.Origin.Lat) / math.Cos(pt.Lat)
+}
+
+// TransverseMercator is the spherical transverse Mercator projection about CentralMeridian.
+type TransverseMercator struct {
+	CentralMeridian float64 // CentralMeridian is the longitude (in radians) of true scale.
+	ScaleFactor     float64 // ScaleFactor is the scale along the central meridian.
+	FalseEasting    float64 // FalseEasting is added to all x coordinates.
+	FalseNorthing   float64 // FalseNorthing is added to all y coordinates.
+}
+
+// NewTransverseMercator returns a local TransverseMercator with its central meridian through origin.
+func NewTransverseMercator(origin Point) TransverseMercator {
+	return TransverseMercator{CentralMeridian: origin.Lon, ScaleFactor: 1}
+}
+
+// NewUTM returns the TransverseMercator of the Universal Transverse Mercator zone containing origin.
+func NewUTM(origin Point) TransverseMercator {
+	zone := math.Floor((RadiansToDegrees(origin.Lon)+180)/6) + 1
+	tm := TransverseMercator{
+		CentralMeridian: DegreesToRadians(zone*6 - 183),
+		ScaleFactor:     0.9996,
+		FalseEasting:    500_000,
+	}
+	if origin.Lat < 0 {
+		tm.FalseNorthing = 10_000_000
+	}
+	return tm
+}
+
+// Project returns the transverse Mercator coordinates of Point pt.
+func (p TransverseMercator) Project(pt Point) (float64, float64) {
+	dLon := pt.Lon - p.CentralMeridian
+	b := math.Cos(pt.Lat) * math.Sin(dLon)
+	x := p.ScaleFactor*haversineRadius*math.Atanh(b) + p.FalseEasting
+	y := p.ScaleFactor*haversineRadius*math.Atan2(math.Tan(pt.Lat), math.Cos(dLon)) + p.FalseNorthing
+	return x, y
+}
+
+// Scale returns the transverse Mercator scale factor at Point pt.
+func (p TransverseMercator) Scale(pt Point) float64 {
+	b := math.Cos(pt.Lat) * math.Sin(pt.Lon-p.CentralMeridian)
+	return p.ScaleFactor / math.Sqrt(1-b*b)
+}
+
+// LambertAzimuthal is the Lambert azimuthal equal-area projection centered on Origin.
+// It preserves areas and is well-defined everywhere except the antipode of Origin.
+type LambertAzimuthal struct {
+	Origin Point
+}
+
+// k returns the Lambert azimuthal radial scale term at Point pt.
+func (p LambertAzimuthal) k(pt Point) float64 {
+	dLon := pt.Lon - p.Origin.Lon
+	return math.Sqrt(2 / (1 + math.Sin(p.Origin.Lat)*math.Sin(pt.Lat) + math.Cos(p.Origin.Lat)*math.Cos(pt.Lat)*math.Cos(dLon)))
+}
+
+// Project returns the Lambert azimuthal equal-area coordinates of Point pt.
+func (p LambertAzimuthal) Project(pt Point) (float64, float64) {
+	dLon := pt.Lon - p.Origin.Lon
+	k := p.k(pt)
+	x := haversineRadius * k * math.Cos(pt.Lat) * math.Sin(dLon)
+	y := haversineRadius * k * (math.Cos(p.Origin.Lat)*math.Sin(pt.Lat) - math.Sin(p.Origin.Lat)*math.Cos(pt.Lat)*math.Cos(dLon))
+	return x, y
+}
+
+// Scale returns the Lambert azimuthal scale factor perpendicular to the radius through Point pt.
+func (p LambertAzimuthal) Scale(pt Point) float64 {
+	return p.k(pt)
+}
@@ -0,0 +1,55 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestProjectionLocalDistance(t *testing.T) {
+	origins := []Point{
+		NewPointFromDegrees(-37.8, 144.9),
+		NewPointFromDegrees(69.65, 18.96),
+		NewPointFromDegrees(-77.85, 166.67),
+	}
+
+	for _, name := range ProjectionNames() {
+		for i, origin := range origins {
+			t.Run(fmt.Sprintf("%s %d", name, i), func(t *testing.T) {
+				proj, err := NewProjection(name, origin)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pt := NewPointFromDegrees(RadiansToDegrees(origin.Lat), RadiansToDegrees(origin.Lon)+0.01)
+				x0, y0 := proj.Project(origin)
+				x1, y1 := proj.Project(pt)
+				actual := math.Hypot(x1-x0, y1-y0) / proj.Scale(origin)
+				expect := origin.DistanceTo(pt)
+				if math.Abs(actual-expect)/expect > 0.01 {
+					t.Fatal(actual, "!=", expect)
+				}
+			})
+		}
+	}
+}
+
+func TestProjectionPoles(t *testing.T) {
+	for _, name := range []string{"equirectangular", "lambert"} {
+		t.Run(name, func(t *testing.T) {
+			proj, err := NewProjection(name, NewPointFromDegrees(89, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			x, y := proj.Project(NewPointFromDegrees(90, 0))
+			if math.IsNaN(x) || math.IsInf(x, 0) || math.IsNaN(y) || math.IsInf(y, 0) {
+				t.Fatal("expected finite coordinates")
+			}
+		})
+	}
+}
+
+func TestProjectionUnknown(t *testing.T) {
+	if _, err := NewProjection("foo", Point{}); err == nil {
+		t.Fatal("expected error")
+	}
+}
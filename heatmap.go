@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/heatmap"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	// heatmapOpts are the options to make the heatmap image
+	heatmapOpts = &heatmap.Options{
+		Title:      Title,
+		Version:    Version,
+		Projection: "mercator",
+	}
+	// heatmapCmd represents the "heatmap" command
+	heatmapCmd = &cobra.Command{
+		Use:   "heatmap",
+		Short: "Map the density of exercise activities",
+		// Pre-checks to ensure value are in bounds
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return checkHeatmap(heatmapOpts)
+		},
+		// Run the command
+		RunE: func(cmd *cobra.Command, args []string) error {
+			heatmapOpts.Input = args
+			heatmapOpts.Manifest = newManifest(cmd, args)
+			return cancelled(cmd, heatmap.Run(cmd.Context(), heatmapOpts))
+		},
+	}
+)
+
+func init() {
+	// Add the "heatmap" command to the root command
+	rootCmd.AddCommand(heatmapCmd)
+
+	// General flags (output location)
+	general, rendering := heatmapFlagSets(heatmapOpts)
+	general.AddFlagSet(configFlagSet())
+	general.VisitAll(func(f *pflag.Flag) { heatmapCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+
+	// Rendering flags (width, colors, blur, etc)
+	rendering.VisitAll(func(f *pflag.Flag) { heatmapCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+
+	// Filtering flags
+	filters := filterFlagSet(&heatmapOpts.Selector)
+	filters.VisitAll(func(f *pflag.Flag) { heatmapCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+
+	// Prints the help command
+	heatmapCmd.SetUsageFunc(func(*cobra.Command) error {
+		fmt.Fprintln(heatmapCmd.OutOrStderr())
+		fmt.Fprintln(heatmapCmd.OutOrStderr(), "Usage:")
+		fmt.Fprintln(heatmapCmd.OutOrStderr(), " ", heatmapCmd.UseLine(), "[input]")
+		fmt.Fprintln(heatmapCmd.OutOrStderr())
+		fmt.Fprintln(heatmapCmd.OutOrStderr(), "General flags:")
+		fmt.Fprintln(heatmapCmd.OutOrStderr(), general.FlagUsages())
+		fmt.Fprintln(heatmapCmd.OutOrStderr(), "Filtering flags:")
+		fmt.Fprintln(heatmapCmd.OutOrStderr(), filters.FlagUsages())
+		fmt.Fprintln(heatmapCmd.OutOrStderr(), "Rendering flags:")
+		fmt.Fprint(heatmapCmd.OutOrStderr(), rendering.FlagUsages())
+		return nil
+	})
+}
+
+// heatmapFlagSets creates the general and rendering flags of the "heatmap" command bound to opts, excluding the config flags.
+func heatmapFlagSets(opts *heatmap.Options) (general, rendering *pflag.FlagSet) {
+	// General flags (output location)
+	general = &pflag.FlagSet{}
+	general.StringVarP(&opts.Output, "output", "o", "out", "optional path of the generated file")
+	general.AddFlagSet(statsFlagSet(&opts.StatsFormat, &opts.StatsFile))
+	general.AddFlagSet(localeFlagSet(&opts.Locale, &opts.Units))
+
+	// Rendering flags (width, colors, blur, etc)
+	rendering = &pflag.FlagSet{}
+	rendering.UintVarP(&opts.Width, "width", "w", 1000, "width of the generated image in pixels")
+	_ = opts.Colors.Parse(heatmap.DefaultColors)
+	rendering.Var((*ColorsFlag)(&opts.Colors), "colors", "CSS linear-colors inspired color scheme string, from empty to densest, eg black,red,yellow,white")
+	rendering.StringVar(&opts.Normalize, "normalize", "log", "how densities are mapped onto the colors, supports "+strings.Join(heatmap.Normalizations, ", "))
+	rendering.Float64Var(&opts.Blur, "blur", 0, "standard deviation of the Gaussian blur in pixels, or 0 for no blur")
+	rendering.UintVar(&opts.Tile, "tile", 0, "size in pixels that the width and height are rounded up to a multiple of, eg 256, or 0 for no rounding")
+	rendering.Var((*BoxFlag)(&opts.Viewport), "viewport", "explicit region to render instead of fitting all activities, eg -37.9,144.8,-37.7,145 or -37.8,144.9,10kmx5km")
+	rendering.Var((*ProjectionFlag)(&opts.Projection), "projection", "map projection, supports "+strings.Join(geo.ProjectionNames(), ", "))
+	rendering.BoolVar(&opts.NoWatermark, "no_watermark", false, "suppress the embedded project name and version string")
+	return general, rendering
+}
+
+// checkHeatmap checks that the options of the "heatmap" command are in bounds.
+func checkHeatmap(opts *heatmap.Options) error {
+	if opts.Width == 0 {
+		return flagError("width", opts.Width, "must be positive")
+	}
+	if opts.Normalize != "" && !slices.Contains(heatmap.Normalizations, opts.Normalize) {
+		return flagError("normalize", opts.Normalize, "supports "+strings.Join(heatmap.Normalizations, ", "))
+	}
+	if math.IsNaN(opts.Blur) || math.IsInf(opts.Blur, 0) {
+		return flagError("blur", opts.Blur, "must be finite")
+	}
+	if opts.Blur < 0 {
+		return flagError("blur", opts.Blur, "must not be negative")
+	}
+	if err := checkLocale(opts.Locale, opts.Units); err != nil {
+		return err
+	}
+	return checkStatsFormat(opts.StatsFormat)
+}
@@ -0,0 +1,127 @@
+package heatmap
+
+import (
+	"image/color"
+	"math"
+	"sort"
+)
+
+// Normalizations lists the supported ways of mapping densities onto the color gradient.
+var Normalizations = []string{"log", "percentile"}
+
+// density is an accumulation buffer counting the activities that pass through each pixel.
+type density struct {
+	width, height int       // The size of the buffer in pixels
+	values        []float64 // The density of each pixel, row by row
+	stamps        []int     // The number of the last activity counted in each pixel, so activities are counted once
+	stamp         int       // The number of the activity being drawn, starting from 1
+}
+
+// newDensity creates an empty density buffer of the given size.
+func newDensity(width, height int) *density {
+	return &density{width: width, height: height, values: make([]float64, width*height), stamps: make([]int, width*height)}
+}
+
+// next starts counting the pixels of the next activity.
+func (d *density) next() {
+	d.stamp++
+}
+
+// Set counts the current activity in the pixel at (x, y), unless out of bounds or already counted.
+// The color is ignored, so the density can be drawn on by a bresenham.Plotter.
+func (d *density) Set(x, y int, _ color.Color) {
+	if x < 0 || y < 0 || x >= d.width || y >= d.height {
+		return
+	}
+	if i := y*d.width + x; d.stamps[i] != d.stamp {
+		d.stamps[i] = d.stamp
+		d.values[i]++
+	}
+}
+
+// blur convolves the densities with a Gaussian kernel of standard deviation sigma pixels,
+// treating pixels beyond the edges as empty.
+func (d *density) blur(sigma float64) {
+	if sigma <= 0 {
+		return
+	}
+
+	// Build the normalized kernel, extending three standard deviations either side
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range kernel {
+		x := float64(i - radius)
+		kernel[i] = math.Exp(-x * x / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	// Apply the kernel horizontally then vertically, since a Gaussian is separable
+	tmp := make([]float64, len(d.values))
+	for y := 0; y < d.height; y++ {
+		row := d.values[y*d.width : (y+1)*d.width]
+		for x := range row {
+			v := 0.0
+			for k, w := range kernel {
+				if xx := x + k - radius; xx >= 0 && xx < d.width {
+					v += w * row[xx]
+				}
+			}
+			tmp[y*d.width+x] = v
+		}
+	}
+	for x := 0; x < d.width; x++ {
+		for y := 0; y < d.height; y++ {
+			v := 0.0
+			for k, w := range kernel {
+				if yy := y + k - radius; yy >= 0 && yy < d.height {
+					v += w * tmp[yy*d.width+x]
+				}
+			}
+			d.values[y*d.width+x] = v
+		}
+	}
+}
+
+// normalize returns the position of every pixel in the color gradient, from 0 for empty pixels to 1 for the densest.
+// The log normalization compresses the densities logarithmically, so lightly used paths remain visible next to busy ones,
+// while the percentile normalization ranks every pixel among the pixels that are not empty, spreading the colors evenly.
+func (d *density) normalize(method string) []float64 {
+	positions := make([]float64, len(d.values))
+	switch method {
+	case "percentile":
+		// Sort the densities of the pixels that are not empty
+		var sorted []float64
+		for _, v := range d.values {
+			if v > 0 {
+				sorted = append(sorted, v)
+			}
+		}
+		sort.Float64s(sorted)
+
+		// Position each pixel by the share of pixels less dense than it, counting half of those just as dense,
+		// so pixels with equal densities share the middle of their range
+		for i, v := range d.values {
+			if v > 0 {
+				lo := sort.SearchFloat64s(sorted, v)
+				hi := sort.Search(len(sorted), func(j int) bool { return sorted[j] > v })
+				positions[i] = float64(lo+hi) / float64(2*len(sorted))
+			}
+		}
+	default:
+		// Scale the logarithms of the densities by that of the densest pixel
+		max := 0.0
+		for _, v := range d.values {
+			max = math.Max(max, v)
+		}
+		if max > 0 {
+			for i, v := range d.values {
+				positions[i] = math.Log1p(v) / math.Log1p(max)
+			}
+		}
+	}
+	return positions
+}
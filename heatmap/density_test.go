@@ -0,0 +1,93 @@
+package heatmap
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestDensitySet(t *testing.T) {
+	d := newDensity(3, 1)
+	for i := 0; i < 2; i++ {
+		d.next()
+		d.Set(0, 0, nil)
+		d.Set(0, 0, nil)
+		d.Set(1, 0, nil)
+		d.Set(3, 0, nil)
+		d.Set(0, -1, nil)
+	}
+
+	expect := []float64{2, 2, 0}
+	for i, v := range d.values {
+		if v != expect[i] {
+			t.Fatal(d.values, "!=", expect)
+		}
+	}
+}
+
+func TestDensityBlur(t *testing.T) {
+	d := newDensity(21, 21)
+	d.values[10*21+10] = 1
+	d.blur(2)
+
+	sum := 0.0
+	for _, v := range d.values {
+		sum += v
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Fatal(sum, "!=", 1)
+	}
+	if center, side := d.values[10*21+10], d.values[10*21+12]; center <= side || side <= 0 {
+		t.Fatal(center, side)
+	}
+	if d.values[10*21+11] != d.values[11*21+10] {
+		t.Fatal(d.values[10*21+11], "!=", d.values[11*21+10])
+	}
+}
+
+func TestDensityNormalize(t *testing.T) {
+	testCases := []struct {
+		method string
+		values []float64
+		expect []float64
+	}{
+		{"log", []float64{0, 1, 3}, []float64{0, 0.5, 1}},
+		{"log", []float64{0, 0}, []float64{0, 0}},
+		{"percentile", []float64{0, 1, 5, 100}, []float64{0, 1.0 / 6, 0.5, 5.0 / 6}},
+		{"percentile", []float64{2, 2, 0, 9}, []float64{1.0 / 3, 1.0 / 3, 0, 5.0 / 6}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			d := &density{values: testCase.values}
+			actual := d.normalize(testCase.method)
+			for j := range actual {
+				if math.Abs(actual[j]-testCase.expect[j]) > 1e-9 {
+					t.Fatal(actual, "!=", testCase.expect)
+				}
+			}
+		})
+	}
+}
+
+func TestTileAlign(t *testing.T) {
+	testCases := []struct {
+		n      int
+		tile   int
+		expect int
+	}{
+		{0, 256, 256},
+		{100, 256, 256},
+		{256, 256, 256},
+		{257, 256, 512},
+		{600, 256, 768},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			if actual := tileAlign(testCase.n, testCase.tile); actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package heatmap
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/manifest"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/StephaneBunel/bresenham"
+)
+
+// DefaultColors is the default color gradient string.
+const DefaultColors = "#000,#306,#a04,#f60,#fd4,#fff"
+
+// Renderer renders the density heatmap of a set of activities.
+// All the state of a render is kept in the Renderer, so multiple renders can run concurrently.
+type Renderer struct {
+	o          Options           // The options to use when rendering the image
+	fullTitle  string            // The text for the watermark in the bottom-right corner
+	files      []*scan.File      // All the input files
+	activities []*parse.Activity // The filtered input activities
+	extent     geo.Box           // A box enclosing all included activities
+	im         *image.RGBA       // The generated image
+}
+
+// New creates a Renderer with a copy of the options.
+func New(opts *Options) *Renderer {
+	r := &Renderer{o: *opts}
+
+	// Construct the full title
+	r.fullTitle = "NathanBaulch/" + r.o.Title
+	if r.o.Version != "" {
+		r.fullTitle += " " + r.o.Version
+	}
+
+	// If no input was provided, the current directory is the input
+	if len(r.o.Input) == 0 {
+		r.o.Input = []string{"."}
+	}
+
+	// If no progress reporter was specified, ignore progress
+	if r.o.Progress == nil {
+		r.o.Progress = progress.Nop
+	}
+
+	// If no normalization was specified, compress the densities logarithmically
+	if r.o.Normalize == "" {
+		r.o.Normalize = "log"
+	}
+
+	// If no colors were specified, use the default gradient
+	if len(r.o.Colors) == 0 {
+		_ = r.o.Colors.Parse(DefaultColors)
+	}
+
+	return r
+}
+
+// Render scans and parses the input activities and renders their density into an image.
+// Rendering stops early with the context error once ctx is done.
+func (r *Renderer) Render(ctx context.Context) (image.Image, error) {
+	// Run each step of the rendering pipeline sequentially
+	for _, step := range []func(context.Context) error{r.scanStep, r.parseStep, r.renderStep} {
+		if err := step(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return r.im, nil
+}
+
+// scanStep scans the input paths for activity files.
+func (r *Renderer) scanStep(ctx context.Context) error {
+	if f, err := scan.Scan(ctx, r.o.Input); err != nil {
+		return err
+	} else {
+		r.files = f
+		if r.o.OnFiles != nil {
+			r.o.OnFiles(len(r.files))
+		}
+		return nil
+	}
+}
+
+// parseStep parses the files with the selector filters and keeps the filtered activities.
+func (r *Renderer) parseStep(ctx context.Context) error {
+	if a, st, err := r.o.Cache.Parse(ctx, r.files, &r.o.Selector, r.o.Progress); err != nil {
+		return err
+	} else {
+		r.activities = a
+		r.extent = st.Extent
+		if r.o.Manifest != nil {
+			if err := r.o.Manifest.Resolve(r.files, &r.o.Selector, st); err != nil {
+				return err
+			}
+		}
+		if r.o.OnStats != nil {
+			return r.o.OnStats(st)
+		}
+		return nil
+	}
+}
+
+// renderStep accumulates every activity into a density buffer with the same projection and extent as the worms animation,
+// then blurs and normalizes the densities and maps them through the color gradient.
+func (r *Renderer) renderStep(ctx context.Context) error {
+	o, activities := &r.o, r.activities
+
+	// Fit the explicit viewport if provided, otherwise all the records
+	fit, err := geo.NewFit(o.Projection, o.Viewport, r.extent, o.Width, func(enclose func(pt geo.Point)) {
+		for _, act := range activities {
+			for _, rec := range act.Records {
+				enclose(rec.Position)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	width, height := int(o.Width), fit.Height
+
+	// Round the size up to whole tiles, centering the map within the extra space
+	offX, offY := 0.0, 0.0
+	if o.Tile > 0 {
+		tiledWidth, tiledHeight := tileAlign(width, int(o.Tile)), tileAlign(height, int(o.Tile))
+		offX, offY = float64(tiledWidth-width)/2, float64(tiledHeight-height)/2
+		width, height = tiledWidth, tiledHeight
+	}
+
+	// Accumulate every activity into the density buffer, counting each pixel once per activity
+	o.Progress.Start("rendering", int64(len(activities)))
+	defer o.Progress.Finish()
+	d := newDensity(width, height)
+	for _, act := range activities {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		d.next()
+		var x0, y0 int
+		for j, rec := range act.Records {
+			x, y := fit.Pixel(rec.Position)
+			x1, y1 := int(offX+x), int(offY+y)
+			if j == 0 {
+				d.Set(x1, y1, nil)
+			} else {
+				bresenham.DrawLine(d, x0, y0, x1, y1, nil)
+			}
+			x0, y0 = x1, y1
+		}
+		o.Progress.Add(1)
+	}
+
+	// Blur and normalize the densities, then color each pixel by its position in the gradient
+	d.blur(o.Blur)
+	positions := d.normalize(o.Normalize)
+	im := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, p := range positions {
+		c := color.RGBAModel.Convert(o.Colors.GetColorAt(p)).(color.RGBA)
+		copy(im.Pix[4*i:], []uint8{c.R, c.G, c.B, 0xff})
+	}
+
+	// Draw watermark if not disabled
+	if !o.NoWatermark {
+		img.DrawWatermark(im, r.fullTitle, o.Colors.GetColorAt(0.5))
+	}
+
+	r.im = im // Set the rendered image
+	return nil
+}
+
+// tileAlign rounds n up to a whole number of tiles of the given size, with at least one tile.
+func tileAlign(n, tile int) int {
+	if n <= tile {
+		return tile
+	}
+	return (n + tile - 1) / tile * tile
+}
+
+// Save encodes the heatmap image to w as a png.
+// Encoding stops early with the context error once ctx is done.
+func (r *Renderer) Save(ctx context.Context, w io.Writer) error {
+	if r.im == nil {
+		return errors.New("nothing rendered")
+	}
+
+	// Report the encoded bytes and check for cancellation on every write
+	r.o.Progress.Start("encoding", 0)
+	defer r.o.Progress.Finish()
+	w = progress.NewWriter(ctx, w, r.o.Progress)
+
+	// Embed the title and compressed manifest as text
+	texts := []img.PNGText{{Keyword: "Software", Text: r.fullTitle}}
+	if r.o.Manifest != nil {
+		if m, err := r.o.Manifest.Marshal(); err != nil {
+			return err
+		} else {
+			texts = append(texts, img.PNGText{Keyword: manifest.Keyword, Text: m, Compressed: true})
+		}
+	}
+
+	// Save the image
+	return png.Encode(&img.PNGWriter{Writer: w, Texts: texts}, r.im)
+}
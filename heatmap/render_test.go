@@ -0,0 +1,99 @@
+package heatmap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeActivities writes two short GPX activities sharing their first segment to a new temporary directory and returns the directory.
+func writeActivities(t *testing.T) string {
+	dir := t.TempDir()
+	for i, last := range []string{`lat="-37.83" lon="144.92"`, `lat="-37.81" lon="144.93"`} {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.gpx", i)), []byte(fmt.Sprintf(`
+			<gpx>
+			  <trk>
+			    <type>running</type>
+			    <trkseg>
+			      <trkpt lat="-37.80" lon="144.90"><time>2022-02-1%[1]dT00:00:00Z</time></trkpt>
+			      <trkpt lat="-37.81" lon="144.91"><time>2022-02-1%[1]dT00:01:00Z</time></trkpt>
+			      <trkpt %[2]s><time>2022-02-1%[1]dT00:02:00Z</time></trkpt>
+			    </trkseg>
+			  </trk>
+			</gpx>`, i, last)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRenderer(t *testing.T) {
+	dir := writeActivities(t)
+
+	testCases := []struct {
+		tile         uint
+		blur         float64
+		normalize    string
+		expectWidth  int
+		expectHeight int
+	}{
+		{0, 0, "log", 90, 113},
+		{64, 0, "log", 128, 128},
+		{0, 1.5, "percentile", 90, 113},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			opts := &Options{Input: []string{dir}, Width: 90, Tile: testCase.tile, Blur: testCase.blur, Normalize: testCase.normalize, Projection: "mercator", NoWatermark: true}
+			r := New(opts)
+			im, err := r.Render(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := im.Bounds(); b.Dx() != testCase.expectWidth || b.Dy() != testCase.expectHeight {
+				t.Fatal(b, "!=", testCase.expectWidth, testCase.expectHeight)
+			}
+
+			// The shared segment is denser than the segments of a single activity, which are denser than the background
+			dark, lit, bright := 0, 0, 0
+			for y := 0; y < im.Bounds().Dy(); y++ {
+				for x := 0; x < im.Bounds().Dx(); x++ {
+					switch c := color.RGBAModel.Convert(im.At(x, y)).(color.RGBA); {
+					case c == color.RGBA{A: 0xff}:
+						dark++
+					case c == color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}:
+						bright++
+					default:
+						lit++
+					}
+				}
+			}
+			if dark == 0 || lit == 0 || bright == 0 {
+				t.Fatal(dark, lit, bright)
+			}
+
+			b := &bytes.Buffer{}
+			if err := r.Save(context.Background(), b); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := png.Decode(b); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRendererCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts := &Options{Input: []string{writeActivities(t)}, Width: 50, Projection: "mercator"}
+	if _, err := New(opts).Render(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatal(err, "!=", context.Canceled)
+	}
+}
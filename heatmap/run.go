@@ -0,0 +1,97 @@
+package heatmap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/manifest"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/stats"
+)
+
+// Options are the options of the heatmap image.
+type Options struct {
+	Title       string                      // The title of this program
+	Version     string                      // The version of this program
+	Input       []string                    // The paths of the input files
+	Output      string                      // The path of the output file
+	Width       uint                        // The width of the output image in pixels, before rounding up to whole tiles
+	Colors      img.ColorGradient           // The color gradient, from empty to the densest pixels
+	Normalize   string                      // How densities are mapped onto the gradient, see Normalizations, defaults to log
+	Blur        float64                     // The standard deviation of the Gaussian blur in pixels, or 0 for no blur
+	Tile        uint                        // The size in pixels that the width and height are rounded up to a multiple of, or 0 for no rounding
+	Projection  string                      // The name of the map projection, see geo.ProjectionNames
+	Viewport    geo.Box                     // The explicit region to render; otherwise, the extent of all activities
+	NoWatermark bool                        // Whether the watermark is drawn
+	Selector    parse.Selector              // The filters specifying which activities to use
+	StatsFormat string                      // The format of the printed stats, supports text, json, yaml
+	StatsFile   string                      // The path of the file to write stats to, or empty for standard output
+	Locale      string                      // The BCP 47 language tag used to format text output
+	Units       string                      // The system of measurement used in text output, or empty for the locale default
+	Manifest    *manifest.Manifest          // The render manifest to embed in the output, or nil for none
+	Cache       *parse.Cache                // The cache of previously parsed files, or nil to parse every file
+	OnFiles     func(n int)                 // Called with the number of input files found, if not nil
+	OnStats     func(st *parse.Stats) error // Called with the stats of the included activities, if not nil
+	Progress    progress.Reporter           // Receives the progress of each stage, or nil to ignore progress
+}
+
+// Run renders the heatmap image and saves it to the output file, printing progress messages and stats.
+// Once ctx is done the render stops and any partially written output file is removed.
+func Run(ctx context.Context, opts *Options) error {
+	o := *opts
+
+	// Create the printer for the locale and units
+	printer, err := conv.NewPrinter(o.Locale, o.Units)
+	if err != nil {
+		return err
+	}
+
+	// Keep standard output clean when it is used for machine-readable stats
+	var msgs io.Writer = os.Stdout
+	if o.StatsFile == "" && o.StatsFormat != "" && o.StatsFormat != "text" {
+		msgs = os.Stderr
+	}
+
+	// Check if the output is valid
+	if fi, err := os.Stat(o.Output); err != nil {
+		// If invalid path, return an error
+		var perr *fs.PathError
+		if !errors.As(err, &perr) {
+			return err
+		}
+	} else if fi.IsDir() {
+		// If output is a directory, save image to file named "out"
+		o.Output = filepath.Join(o.Output, "out")
+	}
+
+	// If the output has no file extension, add ".png" to the output
+	if filepath.Ext(o.Output) == "" {
+		o.Output += ".png"
+	}
+
+	// Report progress and stats on the command line
+	o.OnFiles = func(n int) {
+		conv.FprintField(msgs, printer, "files", printer.Sprintf("%d", n))
+	}
+	o.OnStats = func(st *parse.Stats) error {
+		return stats.Save(o.StatsFile, st, o.StatsFormat, printer)
+	}
+
+	if progress.IsTerminal(os.Stderr) {
+		o.Progress = progress.NewBar(os.Stderr)
+	}
+
+	r := New(&o)
+	if _, err := r.Render(ctx); err != nil {
+		return err
+	}
+	return img.SaveFile(o.Output, func(w io.Writer) error { return r.Save(ctx, w) })
+}
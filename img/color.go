@@ -72,12 +72,8 @@ func (c *ColorGradient) Parse(str string) error {
 			missingAt = 0
 		}
 
-		if e.Color, err = colorful.Hex(part); err != nil {
-			if col, ok := colornames.Map[strings.ToLower(part)]; !ok {
-				return fmt.Errorf("color %q not recognized", part)
-			} else {
-				e.Color, _ = colorful.MakeColor(col)
-			}
+		if e.Color, err = parseColor(part); err != nil {
+			return err
 		}
 		i++
 	}
@@ -114,3 +110,25 @@ func (c *ColorGradient) GetColorAt(p float64) color.Color {
 	}
 	return (*c)[last].Color
 }
+
+// ParseColor parses a hex color string or CSS color name, eg #f80 or orange.
+func ParseColor(str string) (color.RGBA, error) {
+	if c, err := parseColor(str); err != nil {
+		return color.RGBA{}, err
+	} else {
+		r, g, b := c.RGB255()
+		return color.RGBA{R: r, G: g, B: b, A: 0xff}, nil
+	}
+}
+
+// parseColor parses a hex color string or CSS color name into a colorful.Color.
+func parseColor(str string) (colorful.Color, error) {
+	if c, err := colorful.Hex(str); err == nil {
+		return c, nil
+	}
+	if col, ok := colornames.Map[strings.ToLower(str)]; ok {
+		c, _ := colorful.MakeColor(col)
+		return c, nil
+	}
+	return colorful.Color{}, fmt.Errorf("color %q not recognized", str)
+}
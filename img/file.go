@@ -0,0 +1,36 @@
+package img
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SaveFile creates the file at path, including its directory, and calls write with it.
+// The file is removed again if write fails, so no partial output is left behind.
+func SaveFile(path string, write func(w io.Writer) error) (err error) {
+	// Create the save directory if it doesn't exist
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	// Create an empty file
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	// At the very end, ensure the file is closed and remove it if incomplete
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(path)
+		}
+	}()
+
+	return write(out)
+}
@@ -0,0 +1,38 @@
+package img
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveFile(t *testing.T) {
+	testCases := []struct {
+		err    error
+		exists bool
+	}{
+		{nil, true},
+		{errors.New("failed"), false},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sub", "out.gif")
+			err := SaveFile(path, func(w io.Writer) error {
+				if _, err := w.Write([]byte("partial")); err != nil {
+					return err
+				}
+				return testCase.err
+			})
+			if err != testCase.err {
+				t.Fatal(err, "!=", testCase.err)
+			}
+			if _, err := os.Stat(path); (err == nil) != testCase.exists {
+				t.Fatal(err == nil, "!=", testCase.exists)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package img
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// GIFWriter is a custom writer for writing GIF files with additional comments.
+type GIFWriter struct {
+	*bufio.Writer          // Underlying writer
+	Comments      []string // Comments to be added to the GIF file
+	done          bool     // Flag indicating whether the writing process is complete
+}
+
+// Write writes the contents of the byte slice to the writer.
+// It intercepts the application extension to insert the comments before writing.
+func (w *GIFWriter) Write(p []byte) (nn int, err error) {
+	n := 0
+	if !w.done {
+		// Intercept application extension and insert comments
+		if len(p) == 3 && p[0] == 0x21 && p[1] == 0xff && p[2] == 0x0b {
+			// Write a comment extension for each comment
+			for _, c := range w.Comments {
+				if n, err = w.writeExtension([]byte(c), 0xfe); err != nil {
+					return
+				} else {
+					nn += n
+				}
+			}
+			w.done = true
+		}
+	}
+	// Write the content of the byte slice
+	if n, err = w.Writer.Write(p); err != nil {
+		return
+	} else {
+		nn += n
+	}
+	return
+}
+
+// writeExtension writes an extension with the given label into the GIF file.
+// The data is split into sub-blocks of at most 255 bytes.
+func (w *GIFWriter) writeExtension(b []byte, e byte) (nn int, err error) {
+	n := 0
+	// Write the extension introducer and label
+	if n, err = w.Writer.Write([]byte{0x21, e}); err != nil {
+		return
+	} else {
+		nn += n
+	}
+	// Write the data as a sequence of length prefixed sub-blocks
+	for len(b) > 0 {
+		size := len(b)
+		if size > 0xff {
+			size = 0xff
+		}
+		if err = w.Writer.WriteByte(byte(size)); err != nil {
+			return
+		} else {
+			nn++
+		}
+		if n, err = w.Writer.Write(b[:size]); err != nil {
+			return
+		} else {
+			nn += n
+		}
+		b = b[size:]
+	}
+	// Write the extension terminator
+	if err = w.Writer.WriteByte(0); err != nil {
+		return
+	} else {
+		nn++
+	}
+	return
+}
+
+// ReadGIFComments reads the comments that precede the first image of the GIF file read from r.
+func ReadGIFComments(r io.Reader) ([]string, error) {
+	br := bufio.NewReader(r)
+
+	// Read the header and logical screen descriptor
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, err
+	} else if string(header[:3]) != "GIF" {
+		return nil, errors.New("not a GIF file")
+	}
+	// Skip the global color table
+	if header[10]&0x80 != 0 {
+		if _, err := br.Discard(3 << (header[10]&0x07 + 1)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read extensions until the first image descriptor or trailer
+	var comments []string
+	for {
+		if b, err := br.ReadByte(); err != nil {
+			return nil, err
+		} else if b != 0x21 {
+			return comments, nil
+		}
+		label, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		data, err := readSubBlocks(br)
+		if err != nil {
+			return nil, err
+		}
+		if label == 0xfe {
+			comments = append(comments, string(data))
+		}
+	}
+}
+
+// readSubBlocks reads and concatenates a sequence of GIF data sub-blocks up to and including the block terminator.
+func readSubBlocks(br *bufio.Reader) ([]byte, error) {
+	var data []byte
+	for {
+		size, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		} else if size == 0 {
+			return data, nil
+		}
+		block := make([]byte, size)
+		if _, err := io.ReadFull(br, block); err != nil {
+			return nil, err
+		}
+		data = append(data, block...)
+	}
+}
+
+// PNGText is a textual chunk of a PNG file.
+type PNGText struct {
+	Keyword    string // Keyword identifies the text, between 1 and 79 Latin-1 characters
+	Text       string // Text is the content of the chunk
+	Compressed bool   // Compressed stores the text in a compressed zTXt chunk instead of a tEXt chunk
+}
+
+// PNGWriter is a custom writer for writing PNG files with additional text metadata.
+type PNGWriter struct {
+	io.Writer           // Underlying writer
+	Texts     []PNGText // Text metadata to be added to the PNG file
+	done      bool      // Flag indicating whether the writing process is complete
+}
+
+// Write writes the contents of the byte slice to the writer.
+func (w *PNGWriter) Write(p []byte) (nn int, err error) {
+	n := 0
+	if !w.done {
+		// Intercept the first data chunk and insert text metadata
+		if len(p) >= 8 && string(p[4:8]) == "IDAT" {
+			// Write a text metadata chunk for each text
+			for _, t := range w.Texts {
+				name, b := "tEXt", append([]byte(t.Keyword), 0)
+				if t.Compressed {
+					// Append the compression method followed by the compressed text
+					name = "zTXt"
+					b = append(b, 0)
+					buf := bytes.NewBuffer(b)
+					zw := zlib.NewWriter(buf)
+					if _, err = zw.Write([]byte(t.Text)); err != nil {
+						return
+					} else if err = zw.Close(); err != nil {
+						return
+					}
+					b = buf.Bytes()
+				} else {
+					b = append(b, t.Text...)
+				}
+				if n, err = w.writeChunk(b, name); err != nil {
+					return
+				} else {
+					nn += n
+				}
+			}
+			w.done = true
+		}
+	}
+	// Write the content of the byte slice
+	if n, err = w.Writer.Write(p); err != nil {
+		return
+	} else {
+		nn += n
+	}
+	return
+}
+
+// writeChunk writes the metadata chunk into the PNG file.
+func (w *PNGWriter) writeChunk(b []byte, name string) (nn int, err error) {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(b)))
+	copy(header[4:], name)
+	// Calculate CRC checksum for the chunk
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(header[4:8])
+	_, _ = crc.Write(b)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	// Write the chunk header
+	n := 0
+	if n, err = w.Writer.Write(header); err != nil {
+		return
+	} else {
+		nn += n
+	}
+	// Write the chunk metadata
+	if n, err = w.Writer.Write(b); err != nil {
+		return
+	} else {
+		nn += n
+	}
+	// Write the chunk footer
+	if n, err = w.Writer.Write(footer); err != nil {
+		return
+	} else {
+		nn += n
+	}
+	return
+}
+
+// ReadPNGTexts reads the tEXt and zTXt chunks that precede the image data of the PNG file read from r.
+func ReadPNGTexts(r io.Reader) ([]PNGText, error) {
+	br := bufio.NewReader(r)
+
+	// Read the signature
+	sig := make([]byte, 8)
+	if _, err := io.ReadFull(br, sig); err != nil {
+		return nil, err
+	} else if string(sig) != "\x89PNG\r\n\x1a\n" {
+		return nil, errors.New("not a PNG file")
+	}
+
+	// Read chunks until the first data chunk
+	var texts []PNGText
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			return nil, err
+		}
+		size, name := binary.BigEndian.Uint32(header), string(header[4:8])
+		if name == "IDAT" || name == "IEND" {
+			return texts, nil
+		} else if name != "tEXt" && name != "zTXt" {
+			// Skip the chunk data and CRC
+			if _, err := br.Discard(int(size) + 4); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		b := make([]byte, size+4)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return nil, err
+		}
+		b = b[:size]
+		keyword, text, ok := bytes.Cut(b, []byte{0})
+		if !ok {
+			return nil, fmt.Errorf("%s chunk missing keyword", name)
+		}
+		t := PNGText{Keyword: string(keyword), Compressed: name == "zTXt"}
+		if t.Compressed {
+			// Skip the compression method and decompress the text
+			if len(text) == 0 {
+				return nil, errors.New("zTXt chunk missing compression method")
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(text[1:]))
+			if err != nil {
+				return nil, err
+			}
+			if text, err = io.ReadAll(zr); err != nil {
+				return nil, err
+			}
+		}
+		t.Text = string(text)
+		texts = append(texts, t)
+	}
+}
@@ -0,0 +1,98 @@
+package img
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGIFWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := &GIFWriter{Writer: bufio.NewWriter(b), Comments: []string{"foo"}}
+	if n, err := w.Write([]byte{0x21, 0xff, 0x0b}); err != nil {
+		t.Fatal(err)
+	} else if n != 10 {
+		t.Fatal("number of bytes written:", n, "!=", 10)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b.Bytes(), []byte("foo")) {
+		t.Fatal("metadata text not found")
+	}
+}
+
+func TestPNGWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := &PNGWriter{Writer: b, Texts: []PNGText{{Keyword: "Software", Text: "foo"}}}
+	if n, err := w.Write([]byte("    IDAT")); err != nil {
+		t.Fatal(err)
+	} else if n != 32 {
+		t.Fatal("number of bytes written:", n, "!=", 32)
+	}
+	if !bytes.Contains(b.Bytes(), []byte("Software\x00foo")) {
+		t.Fatal("metadata text not found")
+	}
+}
+
+func TestReadGIFComments(t *testing.T) {
+	testCases := [][]string{
+		nil,
+		{"foo"},
+		{"foo", strings.Repeat("bar", 200)},
+	}
+	for i, comments := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			b := &bytes.Buffer{}
+			w := &GIFWriter{Writer: bufio.NewWriter(b), Comments: comments}
+			pal := color.Palette{color.Black, color.White}
+			im := image.NewPaletted(image.Rect(0, 0, 2, 2), pal)
+			g := &gif.GIF{Image: []*image.Paletted{im, im}, Delay: []int{0, 0}}
+			if err := gif.EncodeAll(w, g); err != nil {
+				t.Fatal(err)
+			} else if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := gif.DecodeAll(bytes.NewReader(b.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			if actual, err := ReadGIFComments(b); err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(actual, comments) {
+				t.Fatal(actual, "!=", comments)
+			}
+		})
+	}
+}
+
+func TestReadPNGTexts(t *testing.T) {
+	testCases := [][]PNGText{
+		nil,
+		{{Keyword: "Software", Text: "foo"}},
+		{{Keyword: "Software", Text: "foo"}, {Keyword: "Comment", Text: strings.Repeat("bar", 200), Compressed: true}},
+	}
+	for i, texts := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			b := &bytes.Buffer{}
+			w := &PNGWriter{Writer: b, Texts: texts}
+			if err := png.Encode(w, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := png.Decode(bytes.NewReader(b.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			if actual, err := ReadPNGTexts(b); err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(actual, texts) {
+				t.Fatal(actual, "!=", texts)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package img
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// OverlayKinds lists the supported kinds of overlay.
+var OverlayKinds = []string{"title", "date", "totals", "legend"}
+
+// Positions lists the supported positions of an overlay on an image.
+var Positions = []string{"top_left", "top", "top_right", "left", "center", "right", "bottom_left", "bottom", "bottom_right"}
+
+// defaultPositions maps each kind of overlay to its position when none is given.
+var defaultPositions = map[string]string{"title": "top", "date": "top_left", "totals": "bottom_left", "legend": "right"}
+
+// Overlay is a piece of information drawn over an image.
+type Overlay struct {
+	Kind     string // The kind of overlay, see OverlayKinds
+	Position string // The position on the image, see Positions, or empty for the default of the kind
+	Text     string // The text of a title overlay
+}
+
+// ParseOverlays parses a semicolon separated list of overlays of the form kind[@position][=text], eg title@top=Lockdown.
+func ParseOverlays(str string) ([]Overlay, error) {
+	if str == "" {
+		return nil, errors.New("unexpected empty value")
+	}
+
+	var overlays []Overlay
+	for _, part := range strings.Split(str, ";") {
+		var o Overlay
+		o.Kind, o.Text, _ = strings.Cut(part, "=")
+		o.Kind, o.Position, _ = strings.Cut(o.Kind, "@")
+		if !slices.Contains(OverlayKinds, o.Kind) {
+			return nil, fmt.Errorf("overlay %q not recognized, supports %s", o.Kind, strings.Join(OverlayKinds, ", "))
+		}
+		if o.Position != "" && !slices.Contains(Positions, o.Position) {
+			return nil, fmt.Errorf("position %q not recognized, supports %s", o.Position, strings.Join(Positions, ", "))
+		}
+		if (o.Kind == "title") != (o.Text != "") {
+			return nil, fmt.Errorf("overlay %q requires text if and only if it is a title", part)
+		}
+		overlays = append(overlays, o)
+	}
+	return overlays, nil
+}
+
+// String returns the string representation of the overlay, as parsed by ParseOverlays.
+func (o Overlay) String() string {
+	str := o.Kind
+	if o.Position != "" {
+		str += "@" + o.Position
+	}
+	if o.Text != "" {
+		str += "=" + o.Text
+	}
+	return str
+}
+
+// Placement returns the position of the overlay, or the default position of its kind if none was given.
+func (o Overlay) Placement() string {
+	if o.Position != "" {
+		return o.Position
+	}
+	return defaultPositions[o.Kind]
+}
+
+// TextStyle is the font, size and color of overlay text.
+type TextStyle struct {
+	Font  string     // The path of a TTF or OTF font file, or empty for the Go Regular font
+	Size  float64    // The size of the text in pixels
+	Color color.RGBA // The color of the text
+}
+
+// NewFace loads the font of the style at its size.
+// The face is not safe for concurrent use.
+func (s *TextStyle) NewFace() (font.Face, error) {
+	data := goregular.TTF
+	if s.Font != "" {
+		var err error
+		if data, err = os.ReadFile(s.Font); err != nil {
+			return nil, err
+		}
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("font %q: %w", s.Font, err)
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: s.Size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// TextBlock is the lines of text of an overlay, each optionally preceded by a color swatch.
+type TextBlock struct {
+	Position string        // The position on the image, see Positions
+	Lines    []string      // The lines of text
+	Swatches []color.Color // The color of the swatch before each line, or nil for no swatches
+}
+
+// DrawTextBlocks draws the blocks onto im with face in color c.
+// Blocks at the same position are stacked in order.
+func DrawTextBlocks(im draw.Image, face font.Face, c color.RGBA, blocks []TextBlock) {
+	// Merge the blocks at each position
+	merged := make(map[string]*TextBlock)
+	var order []string
+	for _, b := range blocks {
+		m, ok := merged[b.Position]
+		if !ok {
+			m = &TextBlock{Position: b.Position}
+			merged[b.Position] = m
+			order = append(order, b.Position)
+		}
+		for i, line := range b.Lines {
+			var swatch color.Color
+			if b.Swatches != nil {
+				swatch = b.Swatches[i]
+			}
+			m.Lines = append(m.Lines, line)
+			m.Swatches = append(m.Swatches, swatch)
+		}
+	}
+
+	for _, pos := range order {
+		drawTextBlock(im, face, c, merged[pos])
+	}
+}
+
+// drawTextBlock draws the lines of a block at its position on im, with a square swatch before each line that has one.
+func drawTextBlock(im draw.Image, face font.Face, c color.RGBA, b *TextBlock) {
+	d := &font.Drawer{Dst: im, Src: image.NewUniform(c), Face: face}
+	metrics := face.Metrics()
+	lineHeight, ascent := metrics.Height.Ceil(), metrics.Ascent.Ceil()
+	margin := int(math.Max(5, float64(lineHeight)/2))
+
+	// Measure the block, leaving room for swatches if there are any
+	side, indent := ascent*3/4, 0
+	for _, s := range b.Swatches {
+		if s != nil {
+			indent = side + ascent/2
+		}
+	}
+	width := 0
+	for _, line := range b.Lines {
+		if w := indent + d.MeasureString(line).Ceil(); w > width {
+			width = w
+		}
+	}
+	height := lineHeight * len(b.Lines)
+
+	// Find the top left corner of the block
+	rect := im.Bounds()
+	x, y := rect.Min.X+margin, rect.Min.Y+margin
+	if strings.HasSuffix(b.Position, "right") {
+		x = rect.Max.X - margin - width
+	} else if !strings.HasSuffix(b.Position, "left") {
+		x = rect.Min.X + (rect.Dx()-width)/2
+	}
+	if strings.HasPrefix(b.Position, "bottom") {
+		y = rect.Max.Y - margin - height
+	} else if !strings.HasPrefix(b.Position, "top") {
+		y = rect.Min.Y + (rect.Dy()-height)/2
+	}
+
+	// Draw each line below the previous one
+	for i, line := range b.Lines {
+		top := y + i*lineHeight
+		if b.Swatches[i] != nil {
+			swatch := image.Rect(x, top+ascent-side, x+side, top+ascent)
+			draw.Draw(im, swatch, image.NewUniform(b.Swatches[i]), image.Point{}, draw.Src)
+		}
+		d.Dot = fixed.P(x+indent, top+ascent)
+		d.DrawString(line)
+	}
+}
@@ -0,0 +1,69 @@
+package img
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseOverlays(t *testing.T) {
+	testCases := []struct {
+		set    string
+		expect any
+	}{
+		{"date", "date"},
+		{"title=Lockdown", "title=Lockdown"},
+		{"title@bottom=Lockdown;date@top_right;legend", "title@bottom=Lockdown;date@top_right;legend"},
+		{"totals@center", "totals@center"},
+		{"", errors.New("unexpected empty value")},
+		{"foo", errors.New(`overlay "foo" not recognized`)},
+		{"date@foo", errors.New(`position "foo" not recognized`)},
+		{"title", errors.New(`overlay "title" requires text`)},
+		{"date=foo", errors.New(`overlay "date=foo" requires text`)},
+		{"date;", errors.New(`overlay "" not recognized`)},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			overlays, err := ParseOverlays(testCase.set)
+			if err != nil {
+				if expectErr, ok := testCase.expect.(error); !ok {
+					t.Fatal(err)
+				} else if !strings.Contains(err.Error(), expectErr.Error()) {
+					t.Fatal(err, "!=", testCase.expect)
+				} else {
+					return
+				}
+			}
+			parts := make([]string, len(overlays))
+			for j, o := range overlays {
+				parts[j] = o.String()
+			}
+			if actual := strings.Join(parts, ";"); actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+func TestOverlayPlacement(t *testing.T) {
+	testCases := []struct {
+		overlay Overlay
+		expect  string
+	}{
+		{Overlay{Kind: "title", Text: "foo"}, "top"},
+		{Overlay{Kind: "date"}, "top_left"},
+		{Overlay{Kind: "totals"}, "bottom_left"},
+		{Overlay{Kind: "legend"}, "right"},
+		{Overlay{Kind: "legend", Position: "bottom_right"}, "bottom_right"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			if actual := testCase.overlay.Placement(); actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package img
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooLarge is returned when rendered images would take more memory than allowed.
+var ErrTooLarge = errors.New("image too large")
+
+// CheckSize returns ErrTooLarge if count images of width by height pixels, using depth bytes per pixel, take more than max bytes.
+// A max of 0 allows images of any size.
+func CheckSize(width, height, count, depth int, max uint64) error {
+	if size := uint64(width) * uint64(height) * uint64(count) * uint64(depth); max > 0 && size > max {
+		return fmt.Errorf("%w, %d images of %dx%d pixels need %d bytes but at most %d are allowed", ErrTooLarge, count, width, height, size, max)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package img
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCheckSize(t *testing.T) {
+	testCases := []struct {
+		width, height, count, depth int
+		max                         uint64
+		expect                      error
+	}{
+		{100, 100, 10, 1, 0, nil},
+		{100, 100, 10, 1, 100000, nil},
+		{100, 100, 10, 4, 100000, ErrTooLarge},
+		{100, 100, 11, 1, 100000, ErrTooLarge},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			if err := CheckSize(testCase.width, testCase.height, testCase.count, testCase.depth, testCase.max); !errors.Is(err, testCase.expect) {
+				t.Fatal(err, "!=", testCase.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package img
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+)
+
+// jpegQuality is the quality of each frame of an MJPEG video.
+const jpegQuality = 90
+
+const (
+	aviHasIndex = 0x10 // The main header flag indicating the file has an index
+	aviKeyFrame = 0x10 // The index flag indicating a chunk is a key frame
+)
+
+// aviMainHeader is the avih chunk of an AVI file.
+type aviMainHeader struct {
+	MicroSecPerFrame    uint32    // The duration of each frame
+	MaxBytesPerSec      uint32    // The maximum data rate
+	PaddingGranularity  uint32    // The alignment of the data, or zero for none
+	Flags               uint32    // A combination of flags, see aviHasIndex
+	TotalFrames         uint32    // The number of frames
+	InitialFrames       uint32    // The number of frames before the first frame, always zero
+	Streams             uint32    // The number of streams
+	SuggestedBufferSize uint32    // The size of buffer large enough for any chunk
+	Width               uint32    // The width of the video in pixels
+	Height              uint32    // The height of the video in pixels
+	Reserved            [4]uint32 // Always zero
+}
+
+// aviStreamHeader is the strh chunk of an AVI stream.
+type aviStreamHeader struct {
+	Type                [4]byte   // The type of the stream, eg vids
+	Handler             [4]byte   // The codec of the stream, eg MJPG
+	Flags               uint32    // A combination of flags, or zero for none
+	Priority            uint16    // The priority of the stream, always zero
+	Language            uint16    // The language of the stream, always zero
+	InitialFrames       uint32    // The number of frames before the first frame, always zero
+	Scale               uint32    // Divided into Rate to give the frame rate
+	Rate                uint32    // Divided by Scale to give the frame rate
+	Start               uint32    // The start time of the stream, always zero
+	Length              uint32    // The number of frames
+	SuggestedBufferSize uint32    // The size of buffer large enough for any chunk
+	Quality             int32     // The quality of the stream, or -1 for the default
+	SampleSize          uint32    // The size of every sample, or zero if it varies
+	Frame               [4]uint16 // The left, top, right and bottom of the video
+}
+
+// bitmapInfoHeader is the strf chunk of an AVI video stream.
+type bitmapInfoHeader struct {
+	Size          uint32  // The size of the header
+	Width         int32   // The width of the video in pixels
+	Height        int32   // The height of the video in pixels
+	Planes        uint16  // The number of planes, always one
+	BitCount      uint16  // The number of bits per pixel
+	Compression   [4]byte // The codec of the video, eg MJPG
+	SizeImage     uint32  // The size of a decoded frame
+	XPelsPerMeter int32   // The horizontal resolution, or zero if unknown
+	YPelsPerMeter int32   // The vertical resolution, or zero if unknown
+	ClrUsed       uint32  // The number of palette colors, always zero
+	ClrImportant  uint32  // The number of important palette colors, always zero
+}
+
+// EncodeAVI writes the frames to w as an AVI video of JPEG frames (MJPEG) at fps frames per second.
+// The software string is stored in the INFO list of the file.
+// All frames must have the bounds of the first frame.
+func EncodeAVI(w io.Writer, frames []image.Image, fps uint, software string) error {
+	if len(frames) == 0 {
+		return errors.New("no frames")
+	}
+	if fps == 0 {
+		return errors.New("frame rate must be positive")
+	}
+	rect := frames[0].Bounds()
+	width, height := uint32(rect.Dx()), uint32(rect.Dy())
+
+	// Encode every frame as a JPEG in a movie data chunk, indexing each chunk as a key frame
+	movi := &bytes.Buffer{}
+	movi.WriteString("movi")
+	idx := &bytes.Buffer{}
+	maxSize := 0
+	jb := &bytes.Buffer{}
+	for i, im := range frames {
+		if im.Bounds() != rect {
+			return fmt.Errorf("frame %d bounds %v differ from %v", i, im.Bounds(), rect)
+		}
+		jb.Reset()
+		if err := jpeg.Encode(jb, im, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return err
+		}
+		if jb.Len() > maxSize {
+			maxSize = jb.Len()
+		}
+		idx.WriteString("00dc")
+		writeLE(idx, uint32(aviKeyFrame), uint32(movi.Len()), uint32(jb.Len()))
+		movi.Write(riffChunk("00dc", jb.Bytes()))
+	}
+
+	// Main AVI header
+	avih := &bytes.Buffer{}
+	writeLE(avih, aviMainHeader{
+		MicroSecPerFrame:    uint32(1000000 / fps),
+		MaxBytesPerSec:      uint32(maxSize * int(fps)),
+		Flags:               aviHasIndex,
+		TotalFrames:         uint32(len(frames)),
+		Streams:             1,
+		SuggestedBufferSize: uint32(maxSize),
+		Width:               width,
+		Height:              height,
+	})
+
+	// Video stream header
+	strh := &bytes.Buffer{}
+	writeLE(strh, aviStreamHeader{
+		Type:                [4]byte{'v', 'i', 'd', 's'},
+		Handler:             [4]byte{'M', 'J', 'P', 'G'},
+		Scale:               1,
+		Rate:                uint32(fps),
+		Length:              uint32(len(frames)),
+		SuggestedBufferSize: uint32(maxSize),
+		Quality:             -1,
+		Frame:               [4]uint16{0, 0, uint16(width), uint16(height)},
+	})
+
+	// Video stream format
+	strf := &bytes.Buffer{}
+	writeLE(strf, bitmapInfoHeader{
+		Size:        40,
+		Width:       int32(width),
+		Height:      int32(height),
+		Planes:      1,
+		BitCount:    24,
+		Compression: [4]byte{'M', 'J', 'P', 'G'},
+		SizeImage:   width * height * 3,
+	})
+
+	hdrl := riffList("hdrl",
+		riffChunk("avih", avih.Bytes()),
+		riffList("strl", riffChunk("strh", strh.Bytes()), riffChunk("strf", strf.Bytes())),
+	)
+	info := riffList("INFO", riffChunk("ISFT", append([]byte(software), 0)))
+
+	// Write the file, with the index last
+	bw := bufio.NewWriter(w)
+	body := [][]byte{[]byte("AVI "), hdrl, info, riffChunk("LIST", movi.Bytes()), riffChunk("idx1", idx.Bytes())}
+	size := 0
+	for _, b := range body {
+		size += len(b)
+	}
+	bw.WriteString("RIFF")
+	writeLE(bw, uint32(size))
+	for _, b := range body {
+		if _, err := bw.Write(b); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// riffChunk returns a RIFF chunk with the id and data, padded to an even length.
+func riffChunk(id string, data []byte) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString(id)
+	writeLE(b, uint32(len(data)))
+	b.Write(data)
+	if len(data)%2 == 1 {
+		b.WriteByte(0)
+	}
+	return b.Bytes()
+}
+
+// riffList returns a RIFF list of the given type containing the chunks.
+func riffList(typ string, chunks ...[]byte) []byte {
+	data := []byte(typ)
+	for _, c := range chunks {
+		data = append(data, c...)
+	}
+	return riffChunk("LIST", data)
+}
+
+// writeLE writes each value to w in little-endian byte order, ignoring errors.
+func writeLE(w io.Writer, values ...any) {
+	for _, v := range values {
+		_ = binary.Write(w, binary.LittleEndian, v)
+	}
+}
+
+// EncodeY4M writes the frames to w as an uncompressed YUV4MPEG2 stream at fps frames per second.
+// The frames are converted to full range YCbCr with 4:2:0 chroma subsampling, as read by most video encoders.
+// All frames must have the bounds of the first frame.
+func EncodeY4M(w io.Writer, frames []image.Image, fps uint) error {
+	if len(frames) == 0 {
+		return errors.New("no frames")
+	}
+	if fps == 0 {
+		return errors.New("frame rate must be positive")
+	}
+	rect := frames[0].Bounds()
+	width, height := rect.Dx(), rect.Dy()
+	cw, ch := (width+1)/2, (height+1)/2
+
+	bw := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(bw, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C420jpeg XCOLORRANGE=FULL\n", width, height, fps); err != nil {
+		return err
+	}
+
+	ys := make([]byte, width*height)
+	cbs := make([]int, cw*ch)
+	crs := make([]int, cw*ch)
+	counts := make([]int, cw*ch)
+	cb, cr := make([]byte, cw*ch), make([]byte, cw*ch)
+	for i, im := range frames {
+		if im.Bounds() != rect {
+			return fmt.Errorf("frame %d bounds %v differ from %v", i, im.Bounds(), rect)
+		}
+
+		// Convert every pixel, summing the chroma of each 2x2 block
+		for j := range cbs {
+			cbs[j], crs[j], counts[j] = 0, 0, 0
+		}
+		convert := ycbcrConverter(im)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				yy, u, v := convert(rect.Min.X+x, rect.Min.Y+y)
+				ys[y*width+x] = yy
+				j := (y/2)*cw + x/2
+				cbs[j] += int(u)
+				crs[j] += int(v)
+				counts[j]++
+			}
+		}
+		for j := range cb {
+			cb[j] = byte((cbs[j] + counts[j]/2) / counts[j])
+			cr[j] = byte((crs[j] + counts[j]/2) / counts[j])
+		}
+
+		// Write the planes of the frame
+		bw.WriteString("FRAME\n")
+		bw.Write(ys)
+		bw.Write(cb)
+		if _, err := bw.Write(cr); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ycbcrConverter returns a function that converts the pixel of im at x, y to YCbCr.
+// Paletted images convert each palette color only once, and opaque RGBA images skip the color model.
+func ycbcrConverter(im image.Image) func(x, y int) (uint8, uint8, uint8) {
+	toYCbCr := func(c color.Color) (uint8, uint8, uint8) {
+		r, g, b, _ := c.RGBA()
+		return color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+	}
+	if p, ok := im.(*image.Paletted); ok {
+		table := make([][3]uint8, len(p.Palette))
+		for i, c := range p.Palette {
+			table[i][0], table[i][1], table[i][2] = toYCbCr(c)
+		}
+		return func(x, y int) (uint8, uint8, uint8) {
+			t := table[p.ColorIndexAt(x, y)]
+			return t[0], t[1], t[2]
+		}
+	}
+	if rgba, ok := im.(*image.RGBA); ok {
+		return func(x, y int) (uint8, uint8, uint8) {
+			i := rgba.PixOffset(x, y)
+			return color.RGBToYCbCr(rgba.Pix[i], rgba.Pix[i+1], rgba.Pix[i+2])
+		}
+	}
+	return func(x, y int) (uint8, uint8, uint8) {
+		return toYCbCr(im.At(x, y))
+	}
+}
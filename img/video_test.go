@@ -0,0 +1,111 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// testFrames returns n paletted frames of the given size filled with white.
+func testFrames(n, width, height int) []image.Image {
+	frames := make([]image.Image, n)
+	for i := range frames {
+		frames[i] = image.NewPaletted(image.Rect(0, 0, width, height), color.Palette{color.White, color.Black})
+	}
+	return frames
+}
+
+func TestEncodeY4M(t *testing.T) {
+	testCases := []struct {
+		width, height int
+		fps           uint
+		header        string
+		frameSize     int
+	}{
+		{4, 2, 25, "YUV4MPEG2 W4 H2 F25:1 Ip A1:1 C420jpeg XCOLORRANGE=FULL\n", 4*2 + 2*2*1},
+		{3, 3, 10, "YUV4MPEG2 W3 H3 F10:1 Ip A1:1 C420jpeg XCOLORRANGE=FULL\n", 3*3 + 2*2*2},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			b := &bytes.Buffer{}
+			if err := EncodeY4M(b, testFrames(2, testCase.width, testCase.height), testCase.fps); err != nil {
+				t.Fatal(err)
+			}
+			actual := b.String()
+			if expect := len(testCase.header) + 2*(len("FRAME\n")+testCase.frameSize); len(actual) != expect {
+				t.Fatal(len(actual), "!=", expect)
+			}
+			if actual[:len(testCase.header)] != testCase.header {
+				t.Fatal(actual[:len(testCase.header)], "!=", testCase.header)
+			}
+			frame := actual[len(testCase.header)+len("FRAME\n"):]
+			if frame[0] != 255 || frame[testCase.width*testCase.height] != 128 {
+				t.Fatal(frame[0], frame[testCase.width*testCase.height], "!=", 255, 128)
+			}
+		})
+	}
+}
+
+func TestEncodeAVI(t *testing.T) {
+	testCases := []struct {
+		frames int
+		fps    uint
+	}{
+		{1, 20},
+		{3, 25},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			b := &bytes.Buffer{}
+			if err := EncodeAVI(b, testFrames(testCase.frames, 5, 3), testCase.fps, "foo"); err != nil {
+				t.Fatal(err)
+			}
+			buf := b.Bytes()
+
+			if string(buf[:4]) != "RIFF" || string(buf[8:12]) != "AVI " {
+				t.Fatal("not an AVI file")
+			}
+			if size := int(binary.LittleEndian.Uint32(buf[4:])); size != len(buf)-8 {
+				t.Fatal(size, "!=", len(buf)-8)
+			}
+
+			// The main header follows the hdrl list header
+			avih := buf[bytes.Index(buf, []byte("avih"))+8:]
+			if actual := binary.LittleEndian.Uint32(avih); actual != uint32(1000000/testCase.fps) {
+				t.Fatal(actual, "!=", 1000000/testCase.fps)
+			}
+			if actual := binary.LittleEndian.Uint32(avih[16:]); actual != uint32(testCase.frames) {
+				t.Fatal(actual, "!=", testCase.frames)
+			}
+			if !bytes.Contains(buf, []byte("ISFT\x04\x00\x00\x00foo\x00")) {
+				t.Fatal("missing software")
+			}
+
+			// Every frame is indexed and decodes as a JPEG
+			idx := buf[bytes.Index(buf, []byte("idx1")):]
+			if actual := int(binary.LittleEndian.Uint32(idx[4:])); actual != 16*testCase.frames {
+				t.Fatal(actual, "!=", 16*testCase.frames)
+			}
+			movi := bytes.Index(buf, []byte("movi"))
+			for j := 0; j < testCase.frames; j++ {
+				entry := idx[8+16*j:]
+				offset, size := binary.LittleEndian.Uint32(entry[8:]), binary.LittleEndian.Uint32(entry[12:])
+				chunk := buf[movi+int(offset):]
+				if string(chunk[:4]) != "00dc" {
+					t.Fatal(string(chunk[:4]), "!=", "00dc")
+				}
+				if im, err := jpeg.Decode(bytes.NewReader(chunk[8 : 8+size])); err != nil {
+					t.Fatal(err)
+				} else if im.Bounds().Dx() != 5 || im.Bounds().Dy() != 3 {
+					t.Fatal(im.Bounds())
+				}
+			}
+		})
+	}
+}
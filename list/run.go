@@ -0,0 +1,237 @@
+package list
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{"table", "csv", "json", "ndjson"}
+
+// SortKeys lists the supported sort keys.
+var SortKeys = []string{"start", "source", "sport", "duration", "distance", "pace", "records"}
+
+// Options are the options of the activity listing.
+type Options struct {
+	Input    []string       // The paths of the input files
+	Output   string         // The path of the output file, or empty for standard output
+	Format   string         // The output format, supports table, csv, json, ndjson
+	Sort     string         // The key to sort activities by, see SortKeys
+	Reverse  bool           // Whether to reverse the sort order
+	Limit    uint           // The maximum number of activities to output, or zero for all
+	Selector parse.Selector // The filters specifying which activities to use
+	Locale   string         // The BCP 47 language tag used to format the table
+	Units    string         // The system of measurement used in the table, or empty for the locale default
+}
+
+// Row is a summary of a single activity.
+type Row struct {
+	Source    string    `json:"source"`        // Source is the path of the file the activity was parsed from
+	Sport     string    `json:"sport"`         // Sport is the type of sport for the activity
+	Start     time.Time `json:"start"`         // Start is the time of the first record
+	Duration  float64   `json:"duration_s"`    // Duration is the elapsed time in seconds
+	Distance  float64   `json:"distance_m"`    // Distance is the distance covered in meters
+	Pace      float64   `json:"pace_s_per_km"` // Pace is the average time in seconds taken to cover each kilometer
+	Records   int       `json:"records"`       // Records is the number of records
+	BoundedBy rowCircle `json:"bounded_by"`    // BoundedBy is the circle enclosing all records
+	act       *parse.Activity
+	bounds    geo.Circle
+}
+
+// rowCircle is the serialized representation of a geo.Circle in degrees and meters.
+type rowCircle struct {
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	Radius float64 `json:"radius_m"`
+}
+
+// Run scans, parses and filters the input activities, then writes one row per activity.
+// Parsing progress is drawn on standard error if it is a terminal, and the work stops early once ctx is done.
+func Run(ctx context.Context, opts *Options) error {
+	// If no input was provided, the current directory is the input
+	if len(opts.Input) == 0 {
+		opts.Input = []string{"."}
+	}
+
+	p, err := conv.NewPrinter(opts.Locale, opts.Units)
+	if err != nil {
+		return err
+	}
+
+	// Draw the parsing progress on a terminal
+	var rep progress.Reporter
+	if progress.IsTerminal(os.Stderr) {
+		rep = progress.NewBar(os.Stderr)
+	}
+
+	files, err := scan.Scan(ctx, opts.Input)
+	if err != nil {
+		return err
+	}
+	acts, _, err := parse.Parse(ctx, files, &opts.Selector, rep)
+	if err != nil {
+		return err
+	}
+
+	rows := NewRows(acts)
+	if err := SortRows(rows, opts.Sort, opts.Reverse); err != nil {
+		return err
+	}
+	if opts.Limit > 0 && int(opts.Limit) < len(rows) {
+		rows = rows[:opts.Limit]
+	}
+
+	// Write to standard output unless an output file was specified
+	write := func(w io.Writer) error { return Write(w, rows, opts.Format, p) }
+	if opts.Output == "" {
+		return write(os.Stdout)
+	}
+	return img.SaveFile(opts.Output, write)
+}
+
+// NewRows summarizes each activity as a Row.
+func NewRows(acts []*parse.Activity) []*Row {
+	rows := make([]*Row, len(acts))
+	for i, act := range acts {
+		c := act.BoundedBy()
+		rows[i] = &Row{
+			Source:   act.Source,
+			Sport:    strings.ToLower(act.Sport),
+			Start:    act.Start(),
+			Duration: act.Duration().Seconds(),
+			Distance: act.Distance,
+			Pace:     act.Pace().Seconds() * 1000,
+			Records:  len(act.Records),
+			BoundedBy: rowCircle{
+				Lat:    geo.RadiansToDegrees(c.Origin.Lat),
+				Lon:    geo.RadiansToDegrees(c.Origin.Lon),
+				Radius: c.Radius,
+			},
+			act:    act,
+			bounds: c,
+		}
+		if rows[i].Sport == "" {
+			rows[i].Sport = "unknown"
+		}
+	}
+	return rows
+}
+
+// SortRows sorts rows by the given key, see SortKeys.
+// Ties are broken by start time so that the order is stable.
+func SortRows(rows []*Row, key string, reverse bool) error {
+	var less func(r0, r1 *Row) bool
+	switch key {
+	case "", "start":
+		less = func(r0, r1 *Row) bool { return false }
+	case "source":
+		less = func(r0, r1 *Row) bool { return r0.Source < r1.Source }
+	case "sport":
+		less = func(r0, r1 *Row) bool { return r0.Sport < r1.Sport }
+	case "duration":
+		less = func(r0, r1 *Row) bool { return r0.Duration < r1.Duration }
+	case "distance":
+		less = func(r0, r1 *Row) bool { return r0.Distance < r1.Distance }
+	case "pace":
+		less = func(r0, r1 *Row) bool { return r0.Pace < r1.Pace }
+	case "records":
+		less = func(r0, r1 *Row) bool { return r0.Records < r1.Records }
+	default:
+		return fmt.Errorf("sort key %q not recognized", key)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		r0, r1 := rows[i], rows[j]
+		if reverse {
+			r0, r1 = r1, r0
+		}
+		if less(r0, r1) {
+			return true
+		} else if less(r1, r0) {
+			return false
+		}
+		return r0.Start.Before(r1.Start)
+	})
+	return nil
+}
+
+// Write writes rows to w in the given format, see Formats.
+// The printer p is used for the human-readable table format.
+func Write(w io.Writer, rows []*Row, format string, p *conv.Printer) error {
+	switch format {
+	case "", "table":
+		return writeTable(w, rows, p)
+	case "csv":
+		return writeCSV(w, rows)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		for _, r := range rows {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("format %q not recognized", format)
+	}
+}
+
+// writeTable writes rows to w as human readable aligned columns.
+func writeTable(w io.Writer, rows []*Row, p *conv.Printer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprint(tw, conv.SprintHeader(p, "SOURCE", "SPORT", "START", "DURATION", "DISTANCE", "PACE", "RECORDS", "BOUNDED BY"))
+	for _, r := range rows {
+		_, _ = p.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			r.Source,
+			r.Sport,
+			r.Start.Format("2006-01-02 15:04"),
+			conv.SprintDuration(p, r.act.Duration()),
+			conv.SprintDistance(p, r.Distance),
+			conv.SprintPace(p, r.act.Pace()),
+			r.Records,
+			r.bounds,
+		)
+	}
+	return tw.Flush()
+}
+
+// writeCSV writes rows to w as comma separated values with a header row.
+func writeCSV(w io.Writer, rows []*Row) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"source", "sport", "start", "duration_s", "distance_m", "pace_s_per_km", "records", "lat", "lon", "radius_m"})
+	for _, r := range rows {
+		_ = cw.Write([]string{
+			r.Source,
+			r.Sport,
+			r.Start.Format(time.RFC3339),
+			conv.FormatFloat(r.Duration),
+			conv.FormatFloat(r.Distance),
+			conv.FormatFloat(r.Pace),
+			strconv.Itoa(r.Records),
+			conv.FormatFloat(r.BoundedBy.Lat),
+			conv.FormatFloat(r.BoundedBy.Lon),
+			conv.FormatFloat(r.BoundedBy.Radius),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
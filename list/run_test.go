@@ -0,0 +1,111 @@
+package list
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+func testActivities() []*parse.Activity {
+	ts := time.Date(2022, 2, 13, 0, 0, 0, 0, time.UTC)
+	newAct := func(source string, start time.Duration, dist float64) *parse.Activity {
+		return &parse.Activity{
+			Source:   source,
+			Sport:    "Running",
+			Distance: dist,
+			Records: []*parse.Record{
+				{Timestamp: ts.Add(start), Position: geo.NewPointFromDegrees(1, 2)},
+				{Timestamp: ts.Add(start + time.Hour), Position: geo.NewPointFromDegrees(1.01, 2)},
+			},
+		}
+	}
+	return []*parse.Activity{
+		newAct("b.gpx", 2*time.Hour, 10000),
+		newAct("a.gpx", time.Hour, 12000),
+		newAct("c.gpx", 0, 8000),
+	}
+}
+
+func TestSortRows(t *testing.T) {
+	testCases := []struct {
+		key     string
+		reverse bool
+		expect  string
+	}{
+		{"start", false, "c.gpx,a.gpx,b.gpx"},
+		{"start", true, "b.gpx,a.gpx,c.gpx"},
+		{"source", false, "a.gpx,b.gpx,c.gpx"},
+		{"distance", true, "a.gpx,b.gpx,c.gpx"},
+		{"pace", false, "a.gpx,b.gpx,c.gpx"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.key, func(t *testing.T) {
+			rows := NewRows(testActivities())
+			if err := SortRows(rows, testCase.key, testCase.reverse); err != nil {
+				t.Fatal(err)
+			}
+			sources := make([]string, len(rows))
+			for i, r := range rows {
+				sources[i] = r.Source
+			}
+			if actual := strings.Join(sources, ","); actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+
+	if err := SortRows(nil, "foo", false); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := Write(b, NewRows(testActivities()[:1]), "csv", nil); err != nil {
+		t.Fatal(err)
+	}
+	expect := "source,sport,start,duration_s,distance_m,pace_s_per_km,records,lat,lon,radius_m\n" +
+		"b.gpx,running,2022-02-13T02:00:00Z,3600,10000,360,2,1.005,2,555.97463\n"
+	if b.String() != expect {
+		t.Fatal(b.String(), "!=", expect)
+	}
+}
+
+func TestWriteTableLocale(t *testing.T) {
+	testCases := []struct {
+		locale string
+		units  string
+		expect []string
+	}{
+		{"en", "", []string{"SOURCE", "10.0km", "6m0s/km"}},
+		{"en-US", "", []string{"SOURCE", "6.2mi", "9m39s/mi"}},
+		{"en-US", "metric", []string{"10.0km"}},
+		{"de-DE", "", []string{"QUELLE", "10,0km"}},
+		{"fr", "imperial", []string{"DURÉE", "6,2mi"}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			p, err := conv.NewPrinter(testCase.locale, testCase.units)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := &bytes.Buffer{}
+			if err := Write(b, NewRows(testActivities()[:1]), "table", p); err != nil {
+				t.Fatal(err)
+			}
+			for _, expect := range testCase.expect {
+				if !strings.Contains(b.String(), expect) {
+					t.Fatal(b.String(), "does not contain", expect)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/signal"
 	"strings"
+	_ "time/tzdata" // Embed the time zone database, so --time_zone works without one installed
 
 	"github.com/spf13/cobra"
 )
@@ -30,8 +34,21 @@ func main() {
 		rootCmd.SetArgs(append([]string{wormsCmd.Name()}, os.Args[1:]...))
 	}
 
-	// Execute the root command and exit if an error occurs
-	if rootCmd.Execute() != nil {
+	// Cancel the context on Ctrl-C so that long running commands stop and clean up
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+
+	// Exit if an error occurs
+	if err != nil {
 		os.Exit(1)
 	}
 }
+
+// cancelled stops the usage being printed when err is because the command was cancelled rather than misused.
+func cancelled(cmd *cobra.Command, err error) error {
+	if errors.Is(err, context.Canceled) {
+		cmd.SilenceUsage = true
+	}
+	return err
+}
@@ -0,0 +1,261 @@
+package manifest
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/NathanBaulch/rainbow-roads/stats"
+)
+
+// Version is the version of the manifest structure, incremented whenever it changes incompatibly.
+const Version = 1
+
+// Keyword is the PNG text keyword of an embedded manifest.
+const Keyword = "Manifest"
+
+// ZIPName is the name of the manifest entry in a zip of frames.
+const ZIPName = "manifest.json"
+
+// Manifest describes everything needed to reproduce a rendered image.
+type Manifest struct {
+	Version  int               `json:"manifest_version"`
+	Program  string            `json:"program"`
+	Command  string            `json:"command"`
+	Created  time.Time         `json:"created"`
+	Flags    map[string]string `json:"flags"`
+	Inputs   []string          `json:"inputs"`
+	Files    []*File           `json:"files"`
+	Selector *Selector         `json:"selector"`
+	Stats    *stats.Summary    `json:"stats"`
+}
+
+// File is an input file and the SHA-256 hash of its content.
+type File struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// Selector is the machine-readable representation of a resolved parse.Selector.
+// Distances are in meters, durations in seconds and paces in seconds per kilometer.
+type Selector struct {
+	Sports        []string `json:"sports,omitempty"`
+	After         string   `json:"after,omitempty"`
+	Before        string   `json:"before,omitempty"`
+	MinDuration   float64  `json:"min_duration_s,omitempty"`
+	MaxDuration   float64  `json:"max_duration_s,omitempty"`
+	MinDistance   float64  `json:"min_distance_m,omitempty"`
+	MaxDistance   float64  `json:"max_distance_m,omitempty"`
+	MinPace       float64  `json:"min_pace_s_per_km,omitempty"`
+	MaxPace       float64  `json:"max_pace_s_per_km,omitempty"`
+	BoundedBy     string   `json:"bounded_by,omitempty"`
+	BoundedByBox  string   `json:"bounded_by_box,omitempty"`
+	StartsNear    string   `json:"starts_near,omitempty"`
+	EndsNear      string   `json:"ends_near,omitempty"`
+	PassesThrough string   `json:"passes_through,omitempty"`
+}
+
+// New creates a manifest of the given program, command, flag values and input paths.
+func New(program, command string, flags map[string]string, inputs []string) *Manifest {
+	return &Manifest{
+		Version: Version,
+		Program: program,
+		Command: command,
+		Flags:   flags,
+		Inputs:  inputs,
+	}
+}
+
+// Resolve completes the manifest with the hashes of files, the resolved selector sel and the summary of st.
+func (m *Manifest) Resolve(files []*scan.File, sel *parse.Selector, st *parse.Stats) error {
+	m.Created = time.Now().UTC().Truncate(time.Second)
+
+	// Hash every input file
+	m.Files = make([]*File, len(files))
+	for i, f := range files {
+		if h, err := hash(f); err != nil {
+			return err
+		} else {
+			m.Files[i] = &File{Path: f.Path, SHA256: h}
+		}
+	}
+
+	m.Selector = newSelector(sel)
+	m.Stats = stats.NewSummary(st)
+	return nil
+}
+
+// hash returns the hex encoded SHA-256 hash of the content of file f.
+func hash(f *scan.File) (string, error) {
+	r, err := f.Opener()
+	if err != nil {
+		return "", err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer func() { _ = c.Close() }()
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", fmt.Errorf("hashing %s: %w", f.Path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// newSelector converts sel into its machine-readable representation.
+func newSelector(sel *parse.Selector) *Selector {
+	s := &Selector{
+		Sports:      sel.Sports,
+		MinDuration: sel.MinDuration.Seconds(),
+		MaxDuration: sel.MaxDuration.Seconds(),
+		MinDistance: sel.MinDistance,
+		MaxDistance: sel.MaxDistance,
+		MinPace:     sel.MinPace.Seconds() * 1000,
+		MaxPace:     sel.MaxPace.Seconds() * 1000,
+	}
+	if !sel.After.IsZero() {
+		s.After = sel.After.Format(time.RFC3339)
+	}
+	if !sel.Before.IsZero() {
+		s.Before = sel.Before.Format(time.RFC3339)
+	}
+	if !sel.BoundedBy.IsZero() {
+		s.BoundedBy = sel.BoundedBy.String()
+	}
+	if !sel.BoundedByBox.IsZero() {
+		s.BoundedByBox = sel.BoundedByBox.String()
+	}
+	if !sel.StartsNear.IsZero() {
+		s.StartsNear = sel.StartsNear.String()
+	}
+	if !sel.EndsNear.IsZero() {
+		s.EndsNear = sel.EndsNear.String()
+	}
+	if !sel.PassesThrough.IsZero() {
+		s.PassesThrough = sel.PassesThrough.String()
+	}
+	return s
+}
+
+// Marshal encodes the manifest as compact JSON.
+func (m *Manifest) Marshal() (string, error) {
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+// Unmarshal decodes a manifest from JSON, returning an error if it is not a supported manifest.
+func Unmarshal(b []byte) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	} else if m.Version == 0 || m.Command == "" {
+		return nil, errors.New("not a render manifest")
+	} else if m.Version > Version {
+		return nil, fmt.Errorf("manifest version %d not supported", m.Version)
+	}
+	return m, nil
+}
+
+// Read reads the manifest embedded in the gif, png or zip file at path.
+func Read(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	// Find the candidate manifest texts for the file format
+	var texts []string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".gif":
+		if texts, err = img.ReadGIFComments(f); err != nil {
+			return nil, err
+		}
+	case ".png":
+		if pts, err := img.ReadPNGTexts(f); err != nil {
+			return nil, err
+		} else {
+			for _, t := range pts {
+				if t.Keyword == Keyword {
+					texts = append(texts, t.Text)
+				}
+			}
+		}
+	case ".zip":
+		if fi, err := f.Stat(); err != nil {
+			return nil, err
+		} else if z, err := zip.NewReader(f, fi.Size()); err != nil {
+			return nil, err
+		} else if b, err := readZIPEntry(z, ZIPName); err != nil {
+			return nil, err
+		} else if b != nil {
+			texts = append(texts, string(b))
+		}
+	default:
+		return nil, fmt.Errorf("file extension %q not supported", ext)
+	}
+
+	// Use the first text that decodes as a manifest
+	for _, t := range texts {
+		if strings.HasPrefix(t, "{") {
+			if m, err := Unmarshal([]byte(t)); err == nil {
+				return m, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no render manifest found in %s", path)
+}
+
+// readZIPEntry reads the entry with the given name from z, or returns nil if there is no such entry.
+func readZIPEntry(z *zip.Reader, name string) ([]byte, error) {
+	for _, zf := range z.File {
+		if zf.Name == name {
+			r, err := zf.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer func() { _ = r.Close() }()
+			return io.ReadAll(r)
+		}
+	}
+	return nil, nil
+}
+
+// Verify compares files with the input files recorded in the manifest.
+// It returns a description of every file that is missing, new or has a different hash.
+func (m *Manifest) Verify(files []*scan.File) ([]string, error) {
+	recorded := make(map[string]string, len(m.Files))
+	for _, f := range m.Files {
+		recorded[f.Path] = f.SHA256
+	}
+
+	var diffs []string
+	for _, f := range files {
+		if h, err := hash(f); err != nil {
+			return nil, err
+		} else if r, ok := recorded[f.Path]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s is new", f.Path))
+		} else {
+			if r != h {
+				diffs = append(diffs, fmt.Sprintf("%s has changed", f.Path))
+			}
+			delete(recorded, f.Path)
+		}
+	}
+	for p := range recorded {
+		diffs = append(diffs, fmt.Sprintf("%s is missing", p))
+	}
+	sort.Strings(diffs)
+	return diffs, nil
+}
@@ -0,0 +1,136 @@
+package manifest
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+)
+
+func TestUnmarshal(t *testing.T) {
+	testCases := []struct {
+		json   string
+		expect any
+	}{
+		{`{"manifest_version":1,"command":"worms","flags":{"frames":"10"}}`, "worms 10"},
+		{`{"manifest_version":1}`, errors.New("not a render manifest")},
+		{`{"command":"worms"}`, errors.New("not a render manifest")},
+		{`{"manifest_version":2,"command":"worms"}`, errors.New("manifest version 2 not supported")},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			m, err := Unmarshal([]byte(testCase.json))
+			if expectErr, ok := testCase.expect.(error); ok {
+				if err == nil || err.Error() != expectErr.Error() {
+					t.Fatal(err, "!=", expectErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if actual := m.Command + " " + m.Flags["frames"]; actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	m := New("rainbow-roads", "worms", map[string]string{"frames": "10", "sport": strings.Repeat("running,", 100)}, []string{"."})
+	text, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pal := color.Palette{color.Black, color.White}
+	im := image.NewPaletted(image.Rect(0, 0, 2, 2), pal)
+
+	testCases := []struct {
+		name  string
+		write func(w io.Writer) error
+	}{
+		{"out.gif", func(w io.Writer) error {
+			g := &gif.GIF{Image: []*image.Paletted{im, im}, Delay: []int{0, 0}}
+			return gif.EncodeAll(&img.GIFWriter{Writer: bufio.NewWriter(w), Comments: []string{"title", text}}, g)
+		}},
+		{"out.png", func(w io.Writer) error {
+			texts := []img.PNGText{{Keyword: "Software", Text: "title"}, {Keyword: Keyword, Text: text, Compressed: true}}
+			return png.Encode(&img.PNGWriter{Writer: w, Texts: texts}, im)
+		}},
+		{"out.zip", func(w io.Writer) error {
+			z := zip.NewWriter(w)
+			if zw, err := z.Create(ZIPName); err != nil {
+				return err
+			} else if _, err = io.WriteString(zw, text); err != nil {
+				return err
+			}
+			return z.Close()
+		}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), testCase.name)
+			if f, err := os.Create(path); err != nil {
+				t.Fatal(err)
+			} else if err = testCase.write(f); err != nil {
+				t.Fatal(err)
+			} else if err = f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if actual, err := Read(path); err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(actual, m) {
+				t.Fatal(actual, "!=", m)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	newFile := func(path, content string) *scan.File {
+		return &scan.File{Path: path, Ext: ".gpx", Opener: func() (io.Reader, error) { return strings.NewReader(content), nil }}
+	}
+
+	testCases := []struct {
+		files  []*scan.File
+		expect []string
+	}{
+		{[]*scan.File{newFile("a.gpx", "foo"), newFile("b.gpx", "bar")}, nil},
+		{[]*scan.File{newFile("a.gpx", "foo"), newFile("b.gpx", "baz")}, []string{"b.gpx has changed"}},
+		{[]*scan.File{newFile("a.gpx", "foo")}, []string{"b.gpx is missing"}},
+		{[]*scan.File{newFile("a.gpx", "foo"), newFile("b.gpx", "bar"), newFile("c.gpx", "")}, []string{"c.gpx is new"}},
+	}
+
+	// Record the hashes of the first test case
+	m := &Manifest{}
+	for _, f := range testCases[0].files {
+		if h, err := hash(f); err != nil {
+			t.Fatal(err)
+		} else {
+			m.Files = append(m.Files, &File{Path: f.Path, SHA256: h})
+		}
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			if actual, err := m.Verify(testCase.files); err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(actual, testCase.expect) {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/NathanBaulch/rainbow-roads/geo"
 	"github.com/NathanBaulch/rainbow-roads/paint"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -11,8 +13,9 @@ import (
 var (
 	// paintOpts are the options to make paint map
 	paintOpts = &paint.Options{
-		Title:   Title,
-		Version: Version,
+		Title:      Title,
+		Version:    Version,
+		Projection: "mercator",
 	}
 	// paintCmd represents the "paint" command
 	paintCmd = &cobra.Command{
@@ -20,15 +23,13 @@ var (
 		Short: "Track coverage in a region of interest",
 		// Pre-checks to ensure value are in bounds
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			if paintOpts.Width == 0 {
-				return flagError("width", paintOpts.Width, "must be positive")
-			}
-			return nil
+			return checkPaint(paintOpts)
 		},
 		// Run the command
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			paintOpts.Input = args
-			return paint.Run(paintOpts)
+			paintOpts.Manifest = newManifest(cmd, args)
+			return cancelled(cmd, paint.Run(cmd.Context(), paintOpts))
 		},
 	}
 )
@@ -38,17 +39,13 @@ func init() {
 	rootCmd.AddCommand(paintCmd)
 
 	// General flags (region and output location)
-	general := &pflag.FlagSet{}
-	general.VarP((*CircleFlag)(&paintOpts.Region), "region", "r", "target region of interest, eg -37.8,144.9,10km")
-	general.StringVarP(&paintOpts.Output, "output", "o", "out", "optional path of the generated file")
+	general, rendering := paintFlagSets(paintOpts)
+	general.AddFlagSet(configFlagSet())
 	general.VisitAll(func(f *pflag.Flag) { paintCmd.Flags().Var(f.Value, f.Name, f.Usage) })
-	_ = paintCmd.MarkFlagRequired("region")
+	paintCmd.MarkFlagsOneRequired("region", "region_box")
+	paintCmd.MarkFlagsMutuallyExclusive("region", "region_box")
 
 	// Rendering flags
-	rendering := &pflag.FlagSet{}
-	rendering.UintVarP(&paintOpts.Width, "width", "w", 1000, "width of the generated image in pixels")
-	rendering.BoolVar(&paintOpts.NoWatermark, "no_watermark", false, "suppress the embedded project name and version string")
-	rendering.BoolVar(&paintOpts.Minimalist, "minimal", false, "only paint the paths of the activities")
 	rendering.VisitAll(func(f *pflag.Flag) { paintCmd.Flags().Var(f.Value, f.Name, f.Usage) })
 
 	// Filtering flags
@@ -70,3 +67,37 @@ func init() {
 		return nil
 	})
 }
+
+// paintFlagSets creates the general and rendering flags of the "paint" command bound to opts, excluding the config flags.
+func paintFlagSets(opts *paint.Options) (general, rendering *pflag.FlagSet) {
+	// General flags (region and output location)
+	general = &pflag.FlagSet{}
+	general.VarP((*CircleFlag)(&opts.Region), "region", "r", "target region of interest, eg -37.8,144.9,10km")
+	general.Var((*BoxFlag)(&opts.RegionBox), "region_box", "rectangular target region of interest, eg -37.9,144.8,-37.7,145 or -37.8,144.9,10kmx5km")
+	general.StringVarP(&opts.Output, "output", "o", "out", "optional path of the generated file")
+	general.AddFlagSet(statsFlagSet(&opts.StatsFormat, &opts.StatsFile))
+	general.AddFlagSet(localeFlagSet(&opts.Locale, &opts.Units))
+
+	// Rendering flags
+	rendering = &pflag.FlagSet{}
+	rendering.UintVarP(&opts.Width, "width", "w", 1000, "width of the generated image in pixels")
+	rendering.Var((*ProjectionFlag)(&opts.Projection), "projection", "map projection, supports "+strings.Join(geo.ProjectionNames(), ", "))
+	rendering.BoolVar(&opts.NoWatermark, "no_watermark", false, "suppress the embedded project name and version string")
+	rendering.BoolVar(&opts.Minimalist, "minimal", false, "only paint the paths of the activities")
+	rendering.AddFlagSet(overlayFlagSet(&opts.Overlays, &opts.Text))
+	return general, rendering
+}
+
+// checkPaint checks that the options of the "paint" command are in bounds.
+func checkPaint(opts *paint.Options) error {
+	if opts.Width == 0 {
+		return flagError("width", opts.Width, "must be positive")
+	}
+	if err := checkText(&opts.Text); err != nil {
+		return err
+	}
+	if err := checkLocale(opts.Locale, opts.Units); err != nil {
+		return err
+	}
+	return checkStatsFormat(opts.StatsFormat)
+}
@@ -1,13 +1,17 @@
 package paint
 
 import (
+	"context"
 	"errors"
 	"hash/fnv"
 	"log"
 	"math"
 	"math/big"
+	"net/http"
+	"net/url"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/NathanBaulch/rainbow-roads/geo"
@@ -27,8 +31,24 @@ type way struct {
 // ttl represents the time-to-live duration for cached OSM data.
 const ttl = 168 * time.Hour
 
-// osmLookup performs a lookup for OSM data based on the provided query string.
-func osmLookup(query string) ([]*way, error) {
+// osmEndpoint is the URL of the Overpass API.
+const osmEndpoint = "https://overpass-api.de/api/interpreter"
+
+// contextClient is an overpass.HTTPClient that cancels its requests once ctx is done.
+type contextClient struct{ ctx context.Context }
+
+// PostForm posts the URL encoded data to url.
+func (c contextClient) PostForm(url string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, url, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return http.DefaultClient.Do(req)
+}
+
+// osmLookup performs a lookup for OSM data based on the provided query string, cancelling the download once ctx is done.
+func osmLookup(ctx context.Context, query string) ([]*way, error) {
 	// Generate a unique filename based on the query string hash.
 	h := fnv.New64()
 	_, _ = h.Write([]byte(query))
@@ -52,7 +72,8 @@ func osmLookup(query string) ([]*way, error) {
 	}
 
 	// Query OSM for data and cache the result.
-	if res, err := overpass.Query(query); err != nil {
+	client := overpass.NewWithSettings(osmEndpoint, 1, contextClient{ctx})
+	if res, err := client.Query(query); err != nil {
 		return nil, err
 	} else if data, err := packWays(res.Ways); err != nil {
 		return nil, err
@@ -0,0 +1,59 @@
+package paint
+
+import (
+	"image/color"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+// overlayBlocks returns the text of every overlay of the options, printed with p.
+func (r *Renderer) overlayBlocks(p *conv.Printer) []img.TextBlock {
+	blocks := make([]img.TextBlock, len(r.o.Overlays))
+	for i, ov := range r.o.Overlays {
+		b := &blocks[i]
+		b.Position = ov.Placement()
+		switch ov.Kind {
+		case "title":
+			b.Lines = []string{ov.Text}
+		case "date":
+			b.Lines = []string{period(r.activities, p)}
+		case "totals":
+			dist := 0.0
+			for _, act := range r.activities {
+				dist += act.Distance
+			}
+			b.Lines = []string{p.Sprintf("%d %s", len(r.activities), p.Sprintf("activities")), conv.SprintDistance(p, dist)}
+		case "legend":
+			b.Lines, b.Swatches = r.legend(p)
+		}
+	}
+	return blocks
+}
+
+// period returns the dates of the first and last activity, printed with p.
+func period(activities []*parse.Activity, p *conv.Printer) string {
+	if len(activities) == 0 {
+		return ""
+	}
+	first, last := activities[0], activities[0]
+	for _, act := range activities[1:] {
+		if act.Start().Before(first.Start()) {
+			first = act
+		}
+		if act.Start().After(last.Start()) {
+			last = act
+		}
+	}
+	return p.Sprintf("%s to %s", first.Local(first.Start()).Format("2006-01-02"), last.Local(last.Start()).Format("2006-01-02"))
+}
+
+// legend returns the labels and colors of a legend of the painted colors, printed with p.
+func (r *Renderer) legend(p *conv.Printer) ([]string, []color.Color) {
+	if r.o.Minimalist {
+		return []string{p.Sprintf("activities")}, []color.Color{actCol}
+	}
+	return []string{p.Sprintf("traveled"), p.Sprintf("not traveled"), p.Sprintf("activities")},
+		[]color.Color{donePriCol, pendPriCol, actCol}
+}
@@ -17,17 +17,29 @@ import (
 // buildQuery constructs an Overpass query based on the provided region and filter criteria.
 // It returns the constructed query string or any encountered error.
 func buildQuery(region geo.Circle, filter string) (string, error) {
+	// Construct the query prefix with the specified region
+	prefix := fmt.Sprintf("way(around:%s,%s,%s)",
+		conv.FormatFloat(region.Radius),
+		conv.FormatFloat(geo.RadiansToDegrees(region.Origin.Lat)),
+		conv.FormatFloat(geo.RadiansToDegrees(region.Origin.Lon)),
+	)
+	return buildPrefixedQuery(prefix, filter)
+}
+
+// buildBoxQuery constructs an Overpass query based on the provided bounding box and filter criteria.
+// It returns the constructed query string or any encountered error.
+func buildBoxQuery(box geo.Box, filter string) (string, error) {
+	// Construct the query prefix with the specified bounding box
+	return buildPrefixedQuery(fmt.Sprintf("way(%s)", box), filter)
+}
+
+// buildPrefixedQuery constructs an Overpass query that applies each filter criterion to the prefix statement.
+// It returns the constructed query string or any encountered error.
+func buildPrefixedQuery(prefix, filter string) (string, error) {
 	// Build criteria based on the filter string
 	if crits, err := buildCriteria(filter); err != nil {
 		return "", fmt.Errorf("overpass query error: %w", err)
 	} else {
-		// Construct the query prefix with the specified region
-		prefix := fmt.Sprintf("way(around:%s,%s,%s)",
-			conv.FormatFloat(region.Radius),
-			conv.FormatFloat(geo.RadiansToDegrees(region.Origin.Lat)),
-			conv.FormatFloat(geo.RadiansToDegrees(region.Origin.Lon)),
-		)
-
 		// Build the parts of the query
 		parts := make([]string, 0, len(crits)*3+2)
 		parts = append(parts, "[out:json];(")
@@ -32,6 +32,17 @@ func TestBuildQuery(t *testing.T) {
 	}
 }
 
+func TestBuildBoxQuery(t *testing.T) {
+	box := geo.Box{Min: geo.NewPointFromDegrees(1, 2), Max: geo.NewPointFromDegrees(3, 4)}
+	got, err := buildBoxQuery(box, "is_tag(highway)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[out:json];(way(1,2,3,4)[highway];);out tags geom qt;`; got != want {
+		t.Fatalf("%s != %s", got, want)
+	}
+}
+
 func TestBuildCriteria(t *testing.T) {
 	testCases := []struct{ input, want string }{
 		{"lit", `[lit="yes"]`},
@@ -0,0 +1,372 @@
+package paint
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/manifest"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/fogleman/gg"
+)
+
+// Renderer renders the coverage map of a set of activities.
+// All the state of a render is kept in the Renderer, so multiple renders can run concurrently.
+type Renderer struct {
+	o          Options           // The options to use when painting the image
+	fullTitle  string            // The text for the watermark in the bottom-right corner
+	files      []*scan.File      // All the input files
+	activities []*parse.Activity // The filtered input activities
+	roads      []*way            // The roads in the specified region downloaded from OSM
+	im         image.Image       // The generated image
+}
+
+// New creates a Renderer with a copy of the options.
+func New(opts *Options) *Renderer {
+	r := &Renderer{o: *opts}
+
+	// Construct the full title
+	r.fullTitle = "NathanBaulch/" + r.o.Title
+	if r.o.Version != "" {
+		r.fullTitle += " " + r.o.Version
+	}
+
+	// If no input was provided, the current directory is the input
+	if len(r.o.Input) == 0 {
+		r.o.Input = []string{"."}
+	}
+
+	// If no progress reporter was specified, ignore progress
+	if r.o.Progress == nil {
+		r.o.Progress = progress.Nop
+	}
+
+	// If no locale was specified, format overlays in English
+	if r.o.Locale == "" {
+		r.o.Locale = "en"
+	}
+
+	// If no text size or color was specified, draw 16 pixel white overlays
+	if r.o.Text.Size == 0 {
+		r.o.Text.Size = 16
+	}
+	if r.o.Text.Color.A == 0 {
+		r.o.Text.Color = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	}
+
+	return r
+}
+
+// Render scans and parses the input activities, downloads the roads unless minimalist, and paints the image.
+// Rendering stops early with the context error once ctx is done.
+func (r *Renderer) Render(ctx context.Context) (image.Image, error) {
+	// Run each step of the rendering pipeline sequentially
+	steps := []func(context.Context) error{r.scanStep, r.parseStep, r.fetchStep, r.renderStep}
+	if r.o.Minimalist {
+		// Only draws the activities
+		steps = []func(context.Context) error{r.scanStep, r.parseStep, r.renderStep}
+	}
+	for _, step := range steps {
+		if err := step(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return r.im, nil
+}
+
+// scanStep scans the input paths for activity files.
+func (r *Renderer) scanStep(ctx context.Context) error {
+	if f, err := scan.Scan(ctx, r.o.Input); err != nil {
+		return err
+	} else {
+		r.files = f
+		if r.o.OnFiles != nil {
+			r.o.OnFiles(len(r.files))
+		}
+		return nil
+	}
+}
+
+// parseStep parses the files with the selector filters and keeps the filtered activities.
+func (r *Renderer) parseStep(ctx context.Context) error {
+	if a, st, err := r.o.Cache.Parse(ctx, r.files, &r.o.Selector, r.o.Progress); err != nil {
+		return err
+	} else {
+		r.activities = a
+		if r.o.Manifest != nil {
+			if err := r.o.Manifest.Resolve(r.files, &r.o.Selector, st); err != nil {
+				return err
+			}
+		}
+		if r.o.OnStats != nil {
+			return r.o.OnStats(st)
+		}
+		return nil
+	}
+}
+
+// fetchStep downloads the roads from OSM that are in the specified region.
+func (r *Renderer) fetchStep(ctx context.Context) error {
+	var query string
+	var err error
+	if !r.o.RegionBox.IsZero() {
+		query, err = buildBoxQuery(r.o.RegionBox.Grow(1/0.9), queryExpr)
+	} else {
+		query, err = buildQuery(r.o.Region.Grow(1/0.9), queryExpr)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.o.Progress.Start("fetching", 0)
+	defer r.o.Progress.Finish()
+	r.roads, err = osmLookup(ctx, query)
+	return err
+}
+
+// renderStep renders the map image based on the provided options and data.
+// It generates the map using geographic information and activity paths.
+// The rendered image includes different road types and activity paths.
+// It also calculates the fraction of primary roads traveled and reports it as progress.
+func (r *Renderer) renderStep(ctx context.Context) error {
+	o := &r.o
+
+	// Create the projection centered on the region
+	origin := o.Region.Origin
+	if !o.RegionBox.IsZero() {
+		origin = o.RegionBox.Center()
+	}
+	proj, err := geo.NewProjection(o.Projection, origin)
+	if err != nil {
+		return err
+	}
+
+	// Calculate origin coordinates, image size and scale for rendering
+	oX, oY := proj.Project(origin)
+	width, height := float64(o.Width), float64(o.Width)
+	var scale float64
+	if !o.RegionBox.IsZero() {
+		// Fit the projected box into the image width
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, pt := range o.RegionBox.Boundary(16) {
+			x, y := proj.Project(pt.Unwrap(origin.Lon))
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+		scale = 0.9 * width / (maxX - minX)
+		height = math.Ceil((maxY - minY) * scale / 0.9)
+		oX, oY = (minX+maxX)/2, (minY+maxY)/2
+	} else {
+		scale = 0.9 * width / (2 * o.Region.Radius) / proj.Scale(origin)
+	}
+
+	// drawLine draws a line on the graphics context based on a geographic point
+	drawLine := func(gc *gg.Context, pt geo.Point) {
+		x, y := proj.Project(pt.Unwrap(origin.Lon))
+		x = width/2 + (x-oX)*scale
+		y = height/2 - (y-oY)*scale
+		gc.LineTo(x, y)
+	}
+
+	// drawRegion draws the shape of the region of interest, excluding the margins
+	drawRegion := func(gc *gg.Context) {
+		if !o.RegionBox.IsZero() {
+			gc.DrawRectangle(0.05*width, 0.05*height, 0.9*width, 0.9*height)
+		} else {
+			gc.DrawCircle(width/2, height/2, 0.9*width/2)
+		}
+	}
+
+	// drawActs draws activity paths on the graphics context with a specified line width
+	drawActs := func(gc *gg.Context, lineWidth float64) {
+		gc.SetLineWidth(1.3 * lineWidth * scale)
+		for _, a := range r.activities {
+			if ctx.Err() != nil {
+				return
+			}
+			for _, rec := range a.Records {
+				drawLine(gc, rec.Position)
+			}
+			gc.Stroke()
+			o.Progress.Add(1)
+		}
+	}
+
+	// Refuse an image that takes more memory than allowed, drawn in two RGBA contexts
+	if err := img.CheckSize(int(width), int(height), 2, 4, o.MaxBytes); err != nil {
+		return err
+	}
+
+	// Every activity is drawn twice and every road four times
+	o.Progress.Start("rendering", int64(2*len(r.activities)+4*len(r.roads)))
+	defer o.Progress.Finish()
+
+	// Initialize the graphics context for drawing the map
+	gc := gg.NewContext(int(width), int(height))
+	gc.SetFillStyle(gg.NewSolidPattern(backCol))
+	gc.DrawRectangle(0, 0, width, height)
+	gc.Fill()
+
+	// Draw activity paths on the graphics context
+	gc.SetStrokeStyle(gg.NewSolidPattern(actCol))
+	drawActs(gc, 10)
+
+	// drawWays draws roads on the graphics context based on their status (primary or secondary)
+	drawWays := func(primary bool, strokeColor color.Color) {
+		gc.SetStrokeStyle(gg.NewSolidPattern(strokeColor))
+
+		for _, w := range r.roads {
+			if ctx.Err() != nil {
+				return
+			}
+			if !primary || mustRun(primaryExpr, (*wayEnv)(w)).(bool) {
+				lineWidth := 10.0
+				switch w.Highway {
+				case "motorway", "trunk", "primary", "secondary", "tertiary":
+					lineWidth *= 3.6
+				case "motorway_link", "trunk_link", "primary_link", "secondary_link", "tertiary_link", "residential", "living_street":
+					lineWidth *= 2.4
+				case "pedestrian", "footway", "cycleway", "track":
+					lineWidth *= 1.4
+				}
+				gc.SetLineWidth(lineWidth * scale)
+				for _, pt := range w.Geometry {
+					drawLine(gc, pt)
+				}
+				gc.Stroke()
+			}
+			o.Progress.Add(1)
+		}
+	}
+
+	// Create a mask graphics context for drawing the road colors
+	maskGC := gg.NewContext(int(width), int(height))
+	drawActs(maskGC, 50)
+	actMask := maskGC.AsMask()
+
+	// Draw secondary roads
+	_ = gc.SetMask(actMask)
+	drawWays(false, doneSecCol)
+	gc.InvertMask()
+	drawWays(false, pendSecCol)
+
+	// Draw primary roads
+	_ = maskGC.SetMask(actMask)
+	maskGC.SetColor(color.Transparent)
+	maskGC.Clear()
+	maskGC.SetColor(color.Black)
+	drawRegion(maskGC)
+	maskGC.Fill()
+	_ = gc.SetMask(maskGC.AsMask())
+	drawWays(true, pendPriCol)
+
+	// Invert the mask for drawing done primary roads
+	maskGC.InvertMask()
+	maskGC.SetColor(color.Transparent)
+	maskGC.Clear()
+	maskGC.SetColor(color.Black)
+	drawRegion(maskGC)
+	maskGC.Fill()
+	_ = gc.SetMask(maskGC.AsMask())
+	drawWays(true, donePriCol)
+
+	// Stop if rendering was cancelled
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Draw watermark if not disabled
+	if !o.NoWatermark {
+		img.DrawWatermark(gc.Image(), r.fullTitle, pendSecCol)
+	}
+
+	// Calculate and report progress
+	done, pend := 0, 0
+	rect := gc.Image().Bounds()
+	for y := rect.Min.Y; y <= rect.Max.Y; y++ {
+		for x := rect.Min.X; x <= rect.Max.X; x++ {
+			switch gc.Image().At(x, y) {
+			case donePriCol:
+				done++
+			case pendPriCol:
+				pend++
+			}
+		}
+	}
+	if done == 0 && pend == 0 {
+		pend = 1
+	}
+	if o.OnProgress != nil {
+		o.OnProgress(float64(done) / float64(done+pend))
+	}
+
+	// Draw the overlays, after measuring the progress so they don't hide any roads
+	if len(o.Overlays) > 0 {
+		printer, err := conv.NewPrinter(o.Locale, o.Units)
+		if err != nil {
+			return err
+		}
+		face, err := o.Text.NewFace()
+		if err != nil {
+			return err
+		}
+		img.DrawTextBlocks(gc.Image().(draw.Image), face, o.Text.Color, r.overlayBlocks(printer))
+	}
+
+	r.im = gc.Image() // Set the rendered image
+	return nil
+}
+
+// wayEnv is an extension of way that implements a Fetch function.
+type wayEnv way
+
+// Fetch returns the type of highway, access type, and surface material of the wayEnv e
+// when given the string parameters "highway", "access", and "surface", respectively.
+func (e *wayEnv) Fetch(k any) any {
+	switch k.(string) {
+	case "highway":
+		return e.Highway
+	case "access":
+		return e.Access
+	case "surface":
+		return e.Surface
+	}
+	return nil
+}
+
+// Save encodes the painted image to w as a png.
+// Encoding stops early with the context error once ctx is done.
+func (r *Renderer) Save(ctx context.Context, w io.Writer) error {
+	if r.im == nil {
+		return errors.New("nothing rendered")
+	}
+
+	// Report the encoded bytes and check for cancellation on every write
+	r.o.Progress.Start("encoding", 0)
+	defer r.o.Progress.Finish()
+	w = progress.NewWriter(ctx, w, r.o.Progress)
+
+	// Embed the title and compressed manifest as text
+	texts := []img.PNGText{{Keyword: "Software", Text: r.fullTitle}}
+	if r.o.Manifest != nil {
+		if m, err := r.o.Manifest.Marshal(); err != nil {
+			return err
+		} else {
+			texts = append(texts, img.PNGText{Keyword: manifest.Keyword, Text: m, Compressed: true})
+		}
+	}
+
+	// Save the image
+	return png.Encode(&img.PNGWriter{Writer: w, Texts: texts}, r.im)
+}
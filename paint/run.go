@@ -1,36 +1,25 @@
 package paint
 
 import (
+	"context"
 	"errors"
-	"image"
-	"image/color"
-	"image/png"
+	"io"
 	"io/fs"
-	"log"
-	"math"
 	"os"
 	"path/filepath"
 
+	"github.com/NathanBaulch/rainbow-roads/conv"
 	"github.com/NathanBaulch/rainbow-roads/geo"
 	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/manifest"
 	"github.com/NathanBaulch/rainbow-roads/parse"
-	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/stats"
 	"github.com/antonmedv/expr"
-	"github.com/fogleman/gg"
 	"golang.org/x/image/colornames"
-	"golang.org/x/text/language"
-	"golang.org/x/text/message"
 )
 
 var (
-	o          *Options                               // The options to use when painting the image
-	fullTitle  string                                 // The text for the watermark in the bottom-right corner
-	en         = message.NewPrinter(language.English) // The printer to ouput text to the command line
-	files      []*scan.File                           // All the input files
-	activities []*parse.Activity                      // The filtered input activities
-	roads      []*way                                 // The roads in the specified region downloaded from OSM
-	im         image.Image                            // The generated image
-
 	backCol    = colornames.Black   // The background color
 	donePriCol = colornames.Lime    // The primairy color for roads that have been traveled
 	doneSecCol = colornames.Green   // the secondairy color for roads that have been traveled
@@ -49,32 +38,49 @@ var (
 			" and surface not in ['cobblestone','sett']", expr.AsBool())
 )
 
+// Options are the options of the paint image.
 type Options struct {
-	Title       string         // The title of this program
-	Version     string         // The version of this program
-	Input       []string       // The paths of the input files
-	Output      string         // The path of the ouput file
-	Width       uint           // The width of the output image in pixels
-	Region      geo.Circle     // The region to load the map of
-	NoWatermark bool           // Whether the watermark is drawn
-	Selector    parse.Selector // The filters specifying which activities to use
-	Minimalist  bool           // Whether to only draw the activity paths
+	Title       string                      // The title of this program
+	Version     string                      // The version of this program
+	Input       []string                    // The paths of the input files
+	Output      string                      // The path of the ouput file
+	Width       uint                        // The width of the output image in pixels
+	Region      geo.Circle                  // The region to load the map of
+	RegionBox   geo.Box                     // The rectangular region to load the map of, used instead of Region when set
+	Projection  string                      // The name of the map projection, see geo.ProjectionNames
+	NoWatermark bool                        // Whether the watermark is drawn
+	Selector    parse.Selector              // The filters specifying which activities to use
+	StatsFormat string                      // The format of the printed stats, supports text, json, yaml
+	StatsFile   string                      // The path of the file to write stats to, or empty for standard output
+	Locale      string                      // The BCP 47 language tag used to format text output
+	Units       string                      // The system of measurement used in text output, or empty for the locale default
+	Minimalist  bool                        // Whether to only draw the activity paths
+	Overlays    []img.Overlay               // The overlays drawn over the image
+	Text        img.TextStyle               // The font, size and color of the overlays, defaults to 16 pixel white Go Regular
+	Manifest    *manifest.Manifest          // The render manifest to embed in the output, or nil for none
+	Cache       *parse.Cache                // The cache of previously parsed files, or nil to parse every file
+	MaxBytes    uint64                      // The most memory the image may take in bytes, or 0 for no limit
+	OnFiles     func(n int)                 // Called with the number of input files found, if not nil
+	OnStats     func(st *parse.Stats) error // Called with the stats of the included activities, if not nil
+	OnProgress  func(done float64)          // Called with the fraction of primary roads in the region that have been traveled, if not nil
+	Progress    progress.Reporter           // Receives the progress of each stage, or nil to ignore progress
 }
 
-// Run executes all the steps needed to genetate the image.
-func Run(opts *Options) error {
-	// Copy the options
-	o = opts
+// Run paints the image and saves it to the output file, printing progress messages and stats.
+// Once ctx is done the render stops and any partially written output file is removed.
+func Run(ctx context.Context, opts *Options) error {
+	o := *opts
 
-	// Construct the full title
-	fullTitle = "NathanBaulch/" + o.Title
-	if o.Version != "" {
-		fullTitle += " " + o.Version
+	// Create the printer for the locale and units
+	printer, err := conv.NewPrinter(o.Locale, o.Units)
+	if err != nil {
+		return err
 	}
 
-	// If no input was provided, the current directory is the input
-	if len(o.Input) == 0 {
-		o.Input = []string{"."}
+	// Keep standard output clean when it is used for machine-readable stats
+	var msgs io.Writer = os.Stdout
+	if o.StatsFile == "" && o.StatsFormat != "" && o.StatsFormat != "text" {
+		msgs = os.Stderr
 	}
 
 	// Check if the output is valid
@@ -94,218 +100,24 @@ func Run(opts *Options) error {
 		o.Output += ".png"
 	}
 
-	// Run each stop of the rendering pipeline sequentially
-	if o.Minimalist {
-		// Only draws the activities
-		for _, step := range []func() error{scanStep, parseStep, renderStep, saveStep} {
-			if err := step(); err != nil {
-				return err
-			}
-		}
-	} else {
-		// Draws the activities and the map of streets
-		for _, step := range []func() error{scanStep, parseStep, fetchStep, renderStep, saveStep} {
-			if err := step(); err != nil {
-				return err
-			}
-		}
+	// Report progress and stats on the command line
+	o.OnFiles = func(n int) {
+		conv.FprintField(msgs, printer, "files", printer.Sprintf("%d", n))
 	}
-
-	return nil
-}
-
-// scanStep scans the input directory (o.Input) and puts the files in the "files" global variable.
-func scanStep() error {
-	if f, err := scan.Scan(o.Input); err != nil {
-		return err
-	} else {
-		files = f
-		en.Println("files:        ", len(files))
-		return nil
+	o.OnStats = func(st *parse.Stats) error {
+		return stats.Save(o.StatsFile, st, o.StatsFormat, printer)
 	}
-}
-
-// parseStep parses the files with the selector filters and puts the filtered activities in the global variable.
-func parseStep() error {
-	if a, stats, err := parse.Parse(files, &o.Selector); err != nil {
-		return err
-	} else {
-		activities = a
-		stats.Print(en)
-		return nil
+	o.OnProgress = func(done float64) {
+		conv.FprintField(msgs, printer, "progress", printer.Sprintf("%.2f%%", 100*done))
 	}
-}
 
-// fetchStep downloads the roads from OSM that are in the specified region.
-func fetchStep() error {
-	query, err := buildQuery(o.Region.Grow(1/0.9), queryExpr)
-	if err != nil {
-		return err
+	if progress.IsTerminal(os.Stderr) {
+		o.Progress = progress.NewBar(os.Stderr)
 	}
 
-	roads, err = osmLookup(query)
-	return err
-}
-
-// renderStep renders the map image based on the provided options and data.
-// It generates the map using geographic information and activity paths.
-// The rendered image includes different road types and activity paths.
-// It also calculates the progress and displays it as a percentage.
-func renderStep() error {
-	// Calculate origin coordinates and scale for rendering
-	oX, oY := o.Region.Origin.MercatorProjection()
-	scale := math.Cos(o.Region.Origin.Lat) * 0.9 * float64(o.Width) / (2 * o.Region.Radius)
-
-	// drawLine draws a line on the graphics context based on a geographic point
-	drawLine := func(gc *gg.Context, pt geo.Point) {
-		x, y := pt.MercatorProjection()
-		x = float64(o.Width)/2 + (x-oX)*scale
-		y = float64(o.Width)/2 - (y-oY)*scale
-		gc.LineTo(x, y)
-	}
-
-	// drawActs draws activity paths on the graphics context with a specified line width
-	drawActs := func(gc *gg.Context, lineWidth float64) {
-		gc.SetLineWidth(1.3 * lineWidth * scale)
-		for _, a := range activities {
-			for _, r := range a.Records {
-				drawLine(gc, r.Position)
-			}
-			gc.Stroke()
-		}
-	}
-
-	// Initialize the graphics context for drawing the map
-	gc := gg.NewContext(int(o.Width), int(o.Width))
-	gc.SetFillStyle(gg.NewSolidPattern(backCol))
-	gc.DrawRectangle(0, 0, float64(o.Width), float64(o.Width))
-	gc.Fill()
-
-	// Draw activity paths on the graphics context
-	gc.SetStrokeStyle(gg.NewSolidPattern(actCol))
-	drawActs(gc, 10)
-
-	// drawWays draws roads on the graphics context based on their status (primary or secondary)
-	drawWays := func(primary bool, strokeColor color.Color) {
-		gc.SetStrokeStyle(gg.NewSolidPattern(strokeColor))
-
-		for _, w := range roads {
-			if !primary || mustRun(primaryExpr, (*wayEnv)(w)).(bool) {
-				lineWidth := 10.0
-				switch w.Highway {
-				case "motorway", "trunk", "primary", "secondary", "tertiary":
-					lineWidth *= 3.6
-				case "motorway_link", "trunk_link", "primary_link", "secondary_link", "tertiary_link", "residential", "living_street":
-					lineWidth *= 2.4
-				case "pedestrian", "footway", "cycleway", "track":
-					lineWidth *= 1.4
-				}
-				gc.SetLineWidth(lineWidth * scale)
-				for _, pt := range w.Geometry {
-					drawLine(gc, pt)
-				}
-				gc.Stroke()
-			}
-		}
-	}
-
-	// Create a mask graphics context for drawing the road colors
-	maskGC := gg.NewContext(int(o.Width), int(o.Width))
-	drawActs(maskGC, 50)
-	actMask := maskGC.AsMask()
-
-	// Draw secondary roads
-	_ = gc.SetMask(actMask)
-	drawWays(false, doneSecCol)
-	gc.InvertMask()
-	drawWays(false, pendSecCol)
-
-	// Draw primary roads
-	_ = maskGC.SetMask(actMask)
-	maskGC.SetColor(color.Transparent)
-	maskGC.Clear()
-	maskGC.SetColor(color.Black)
-	maskGC.DrawCircle(float64(o.Width)/2, float64(o.Width)/2, 0.9*float64(o.Width)/2)
-	maskGC.Fill()
-	_ = gc.SetMask(maskGC.AsMask())
-	drawWays(true, pendPriCol)
-
-	// Invert the mask for drawing done primary roads
-	maskGC.InvertMask()
-	maskGC.SetColor(color.Transparent)
-	maskGC.Clear()
-	maskGC.SetColor(color.Black)
-	maskGC.DrawCircle(float64(o.Width)/2, float64(o.Width)/2, 0.9*float64(o.Width)/2)
-	maskGC.Fill()
-	_ = gc.SetMask(maskGC.AsMask())
-	drawWays(true, donePriCol)
-
-	// Draw watermark if not disabled
-	if !o.NoWatermark {
-		img.DrawWatermark(gc.Image(), fullTitle, pendSecCol)
-	}
-
-	// Calculate and print progress
-	done, pend := 0, 0
-	rect := gc.Image().Bounds()
-	for y := rect.Min.Y; y <= rect.Max.Y; y++ {
-		for x := rect.Min.X; x <= rect.Max.X; x++ {
-			switch gc.Image().At(x, y) {
-			case donePriCol:
-				done++
-			case pendPriCol:
-				pend++
-			}
-		}
-	}
-	if done == 0 && pend == 0 {
-		pend = 1
-	}
-	en.Printf("progress:      %.2f%%\n", 100*float64(done)/float64(done+pend))
-
-	im = gc.Image() // Set the rendered image
-	return nil
-}
-
-// wayEnv is an extension of way that implements a Fetch function.
-type wayEnv way
-
-// Fetch returns the type of highway, access type, and surface material of the wayEnv e
-// when given the string parameters "highway", "access", and "surface", respectively.
-func (e *wayEnv) Fetch(k any) any {
-	switch k.(string) {
-	case "highway":
-		return e.Highway
-	case "access":
-		return e.Access
-	case "surface":
-		return e.Surface
-	}
-	return nil
-}
-
-// saveStep saves the image to the specified output directory and file name as a png.
-func saveStep() error {
-	// Create the save directory if it doesn't exist
-	if dir := filepath.Dir(o.Output); dir != "." {
-		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-			return err
-		}
-	}
-
-	// Create an empty file
-	out, err := os.Create(o.Output)
-	if err != nil {
+	r := New(&o)
+	if _, err := r.Render(ctx); err != nil {
 		return err
 	}
-
-	// At the very end, ensure the file is closed
-	defer func() {
-		if err := out.Close(); err != nil {
-			log.Fatal(err)
-		}
-	}()
-
-	// Save the image to the file
-	return png.Encode(out, im)
+	return img.SaveFile(o.Output, func(w io.Writer) error { return r.Save(ctx, w) })
 }
@@ -0,0 +1,105 @@
+package parse
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+)
+
+// Cache keeps the unfiltered activities of every parsed file in memory, keyed by path,
+// so each file is only read and decoded once no matter how many times it is parsed.
+// Files are assumed not to change once they have been parsed.
+// A Cache is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex         // Guards entries
+	entries map[string]*result // The activities or error of each parsed file
+}
+
+// NewCache creates an empty Cache.
+func NewCache() *Cache {
+	return &Cache{entries: make(map[string]*result)}
+}
+
+// Len returns the number of files in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Load reads and parses the files that are not already in the cache, printing a warning for every file that fails.
+// Every read file is reported to rep, which may be nil, and reading stops early with the context error once ctx is done.
+func (c *Cache) Load(ctx context.Context, files []*scan.File, rep progress.Reporter) error {
+	// Find the files that have not been parsed yet
+	c.mu.Lock()
+	missing := make([]*scan.File, 0, len(files))
+	for _, f := range files {
+		if _, ok := c.entries[f.Path]; !ok {
+			missing = append(missing, f)
+		}
+	}
+	c.mu.Unlock()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Parse them without filters, so the activities can be filtered differently each time
+	res, err := readFiles(ctx, missing, &Selector{}, rep)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, f := range missing {
+		if res[i].err != nil {
+			fmt.Fprintln(os.Stderr, "WARN:", res[i].err)
+		}
+		c.entries[f.Path] = &res[i]
+	}
+	return nil
+}
+
+// Parse is the same as the package level Parse, except that unchanged files are read from the cache.
+// The returned activities are copies, so their records may be modified freely.
+// A nil Cache parses the files directly.
+func (c *Cache) Parse(ctx context.Context, files []*scan.File, selector *Selector, rep progress.Reporter) ([]*Activity, *Stats, error) {
+	if c == nil {
+		return Parse(ctx, files, selector, rep)
+	}
+
+	if err := c.Load(ctx, files, rep); err != nil {
+		return nil, nil, err
+	}
+
+	// Copy the cached activities that pass the non-geographic filters
+	c.mu.Lock()
+	activities := make([]*Activity, 0, len(files))
+	for _, f := range files {
+		if r, ok := c.entries[f.Path]; ok && r.err == nil {
+			for _, act := range r.acts {
+				if selector.Activity(act) {
+					activities = append(activities, act.clone())
+				}
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	return summarize(activities, selector)
+}
+
+// clone returns a copy of the activity with copies of all its records.
+func (a *Activity) clone() *Activity {
+	c := *a
+	c.Records = make([]*Record, len(a.Records))
+	for i, r := range a.Records {
+		rec := *r
+		c.Records[i] = &rec
+	}
+	return &c
+}
@@ -0,0 +1,76 @@
+package parse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/scan"
+)
+
+func TestCache(t *testing.T) {
+	opens := 0
+	files := []*scan.File{{
+		Path: "a.gpx",
+		Ext:  ".gpx",
+		Opener: func() (io.Reader, error) {
+			opens++
+			return strings.NewReader(`
+				<gpx>
+				  <trk>
+				    <type>running</type>
+				    <trkseg>
+				      <trkpt lat="-37.80" lon="144.90"><time>2022-02-13T00:00:00Z</time></trkpt>
+				      <trkpt lat="-37.81" lon="144.91"><time>2022-02-13T00:01:00Z</time></trkpt>
+				    </trkseg>
+				  </trk>
+				  <trk>
+				    <type>cycling</type>
+				    <trkseg>
+				      <trkpt lat="-37.80" lon="144.90"><time>2022-02-14T00:00:00Z</time></trkpt>
+				      <trkpt lat="-37.85" lon="144.95"><time>2022-02-14T00:10:00Z</time></trkpt>
+				    </trkseg>
+				  </trk>
+				</gpx>`), nil
+		},
+	}}
+
+	testCases := []struct {
+		selector *Selector
+		expect   int
+		err      error
+	}{
+		{&Selector{}, 2, nil},
+		{&Selector{Sports: []string{"running"}}, 1, nil},
+		{&Selector{Sports: []string{"cycling"}}, 1, nil},
+		{&Selector{Sports: []string{"swimming"}}, 0, ErrNoActivities},
+	}
+
+	c := NewCache()
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			acts, _, err := c.Parse(context.Background(), files, testCase.selector, nil)
+			if !errors.Is(err, testCase.err) {
+				t.Fatal(err, "!=", testCase.err)
+			}
+			if len(acts) != testCase.expect {
+				t.Fatal(len(acts), "!=", testCase.expect)
+			}
+			if opens != 1 {
+				t.Fatal(opens, "!=", 1)
+			}
+			for _, act := range acts {
+				if act.Source != "a.gpx" {
+					t.Fatal(act.Source, "!=", "a.gpx")
+				}
+				if act.Records[0].Percent != 0 {
+					t.Fatal("cached record modified")
+				}
+				act.Records[0].Percent = 1
+			}
+		})
+	}
+}
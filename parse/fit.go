@@ -3,6 +3,8 @@ package parse
 import (
 	"errors"
 	"io"
+	"math"
+	"time"
 
 	"github.com/NathanBaulch/rainbow-roads/geo"
 	"github.com/tormoder/fit"
@@ -32,6 +34,10 @@ func parseFIT(r io.Reader, selector *Selector) ([]*Activity, error) {
 			Sport:    a.Sessions[0].Sport.String(),
 			Distance: a.Sessions[0].GetTotalDistanceScaled(),
 		}
+		// Use the local timestamp of the activity to determine its time zone, if available
+		if a.Activity != nil {
+			act.Location = fitLocation(a.Activity.Timestamp, a.Activity.LocalTimestamp)
+		}
 
 		// Get the first and last Records
 		r0, r1 := a.Records[0], a.Records[len(a.Records)-1]
@@ -51,9 +57,19 @@ func parseFIT(r io.Reader, selector *Selector) ([]*Activity, error) {
 		for _, rec := range a.Records {
 			// If it is valid, append it to the Activity
 			if !rec.PositionLat.Invalid() && !rec.PositionLong.Invalid() {
+				ele := rec.GetEnhancedAltitudeScaled()
+				if math.IsNaN(ele) {
+					ele = rec.GetAltitudeScaled()
+				}
+				hr := math.NaN()
+				if rec.HeartRate != 0xFF && rec.HeartRate != 0 {
+					hr = float64(rec.HeartRate)
+				}
 				act.Records = append(act.Records, &Record{
 					Timestamp: rec.Timestamp,
 					Position:  geo.NewPointFromSemicircles(rec.PositionLat.Semicircles(), rec.PositionLong.Semicircles()),
+					Elevation: ele,
+					HeartRate: hr,
 				})
 			}
 		}
@@ -67,3 +83,16 @@ func parseFIT(r io.Reader, selector *Selector) ([]*Activity, error) {
 		return []*Activity{act}, nil
 	}
 }
+
+// fitLocation returns the fixed time zone implied by the difference between the UTC timestamp ts and local timestamp lts.
+// Nil is returned if either timestamp is missing or the offset is not a plausible time zone.
+func fitLocation(ts, lts time.Time) *time.Location {
+	if ts.IsZero() || lts.IsZero() || ts.Year() < 1990 || lts.Year() < 1990 {
+		return nil
+	}
+	offset := lts.Sub(ts).Round(15 * time.Minute)
+	if offset < -12*time.Hour || offset > 14*time.Hour {
+		return nil
+	}
+	return time.FixedZone("", int(offset.Seconds()))
+}
@@ -3,6 +3,7 @@ package parse
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"testing"
 	"time"
 
@@ -27,3 +28,32 @@ func TestFITShortDistance(t *testing.T) {
 		t.Fatal("expected 1 activity")
 	}
 }
+
+func TestFITLocation(t *testing.T) {
+	ts := time.Date(2022, 2, 13, 0, 7, 6, 0, time.UTC)
+	testCases := []struct {
+		lts    time.Time
+		expect int
+		ok     bool
+	}{
+		{ts.Add(11 * time.Hour), 11 * 3600, true},
+		{ts.Add(-5*time.Hour + 2*time.Second), -5 * 3600, true},
+		{ts.Add(5*time.Hour + 30*time.Minute), 5*3600 + 1800, true},
+		{ts.Add(20 * time.Hour), 0, false},
+		{time.Time{}, 0, false},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			loc := fitLocation(ts, tc.lts)
+			if (loc != nil) != tc.ok {
+				t.Fatal(loc, "!=", tc.ok)
+			}
+			if loc != nil {
+				if _, actual := ts.In(loc).Zone(); actual != tc.expect {
+					t.Fatal(actual, "!=", tc.expect)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package parse
 
 import (
 	"io"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/NathanBaulch/rainbow-roads/geo"
@@ -101,9 +103,15 @@ func parseGPX(r io.Reader, selector *Selector) ([]*Activity, error) {
 				p1 = p
 
 				// Append the time and position to the activity
+				ele := math.NaN()
+				if p.Elevation.NotNull() {
+					ele = p.Elevation.Value()
+				}
 				act.Records = append(act.Records, &Record{
 					Timestamp: p.Timestamp,
 					Position:  geo.NewPointFromDegrees(p.Latitude, p.Longitude),
+					Elevation: ele,
+					HeartRate: gpxHeartRate(p.Extensions.Nodes),
 				})
 
 				// Add the distance from the previous to current Record to the total distance of the Activity
@@ -132,3 +140,18 @@ func parseGPX(r io.Reader, selector *Selector) ([]*Activity, error) {
 	// Return the slice of all valid filtered activities in the file
 	return acts, nil
 }
+
+// gpxHeartRate returns the heart rate in beats per minute found in the extension nodes of a GPX point, or NaN if there is none.
+// Heart rates are usually nested in a Garmin TrackPointExtension element, but any element named hr or heartrate is accepted.
+func gpxHeartRate(nodes []gpx.ExtensionNode) float64 {
+	for _, n := range nodes {
+		if name := strings.ToLower(n.LocalName()); name == "hr" || name == "heartrate" {
+			if hr, err := strconv.ParseFloat(strings.TrimSpace(n.Data), 64); err == nil && hr > 0 {
+				return hr
+			}
+		} else if hr := gpxHeartRate(n.Nodes); !math.IsNaN(hr) {
+			return hr
+		}
+	}
+	return math.NaN()
+}
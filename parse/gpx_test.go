@@ -2,6 +2,7 @@ package parse
 
 import (
 	"bytes"
+	"math"
 	"testing"
 )
 
@@ -36,3 +37,73 @@ func TestGPXStravaTypeCodes(t *testing.T) {
 		t.Fatal("expected 1 activity")
 	}
 }
+
+func TestGPXElevation(t *testing.T) {
+	if acts, err := parseGPX(bytes.NewBufferString(`
+		<gpx>
+		  <trk>
+		    <trkseg>
+		      <trkpt lat="-37.8" lon="144.9">
+		        <ele>10</ele>
+		        <time>2022-02-13T00:07:06Z</time>
+		      </trkpt>
+		      <trkpt lat="-37.81" lon="144.9">
+		        <time>2022-02-13T00:07:07Z</time>
+		      </trkpt>
+		      <trkpt lat="-37.82" lon="144.9">
+		        <ele>25.5</ele>
+		        <time>2022-02-13T00:07:08Z</time>
+		      </trkpt>
+		      <trkpt lat="-37.83" lon="144.9">
+		        <ele>20</ele>
+		        <time>2022-02-13T00:07:09Z</time>
+		      </trkpt>
+		    </trkseg>
+		  </trk>
+		</gpx>`), &Selector{}); err != nil {
+		t.Fatal(err)
+	} else if len(acts) != 1 {
+		t.Fatal("expected 1 activity")
+	} else if actual := acts[0].Ascent(); actual != 15.5 {
+		t.Fatal(actual, "!=", 15.5)
+	} else if actual := acts[0].Local(acts[0].Start()).Format("15:04"); actual != "10:07" {
+		t.Fatal(actual, "!=", "10:07")
+	}
+}
+
+func TestGPXHeartRate(t *testing.T) {
+	if acts, err := parseGPX(bytes.NewBufferString(`
+		<gpx xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+		  <trk>
+		    <trkseg>
+		      <trkpt lat="-37.8" lon="144.9">
+		        <time>2022-02-13T00:07:06Z</time>
+		        <extensions>
+		          <gpxtpx:TrackPointExtension>
+		            <gpxtpx:hr>142</gpxtpx:hr>
+		          </gpxtpx:TrackPointExtension>
+		        </extensions>
+		      </trkpt>
+		      <trkpt lat="-37.81" lon="144.9">
+		        <time>2022-02-13T00:07:07Z</time>
+		      </trkpt>
+		      <trkpt lat="-37.82" lon="144.9">
+		        <time>2022-02-13T00:07:08Z</time>
+		        <extensions>
+		          <heartrate>150</heartrate>
+		        </extensions>
+		      </trkpt>
+		    </trkseg>
+		  </trk>
+		</gpx>`), &Selector{}); err != nil {
+		t.Fatal(err)
+	} else if len(acts) != 1 {
+		t.Fatal("expected 1 activity")
+	} else if actual := acts[0].Records[0].HeartRate; actual != 142 {
+		t.Fatal(actual, "!=", 142)
+	} else if actual := acts[0].Records[1].HeartRate; !math.IsNaN(actual) {
+		t.Fatal(actual, "!=", math.NaN())
+	} else if actual := acts[0].Records[2].HeartRate; actual != 150 {
+		t.Fatal(actual, "!=", 150)
+	}
+}
@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -11,28 +12,66 @@ import (
 	"sync"
 	"time"
 
+	"github.com/NathanBaulch/rainbow-roads/conv"
 	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/progress"
 	"github.com/NathanBaulch/rainbow-roads/scan"
 	"golang.org/x/exp/slices"
-	"golang.org/x/text/message"
 )
 
+// ErrNoActivities is returned when no activities match the selector.
+var ErrNoActivities = errors.New("no matching activities found")
+
 // Parse parses the files and filters the activities with selector.
 // The activities are returned together with the Stats over all activities.
+// Every parsed file is reported to rep, which may be nil, and parsing stops early once ctx is done.
 // An error is returned if anything goes wrong.
-func Parse(files []*scan.File, selector *Selector) ([]*Activity, *Stats, error) {
+func Parse(ctx context.Context, files []*scan.File, selector *Selector, rep progress.Reporter) ([]*Activity, *Stats, error) {
+	res, err := readFiles(ctx, files, selector, rep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// print a warning for every file that was not parsed correctly,
+	// otherwise append it to an Activity slice.
+	activities := make([]*Activity, 0, len(files))
+	for _, r := range res {
+		if r.err != nil {
+			fmt.Fprintln(os.Stderr, "WARN:", r.err)
+		} else {
+			activities = append(activities, r.acts...)
+		}
+	}
+	return summarize(activities, selector)
+}
+
+// result is the outcome of reading a single file, either a slice of Activities or an error.
+type result struct {
+	acts []*Activity
+	err  error
+}
+
+// readFiles reads and parses all files in parallel, keeping the activities that pass the non-geographic filters of selector.
+// Every read file is reported to rep, which may be nil, and reading stops early with the context error once ctx is done.
+func readFiles(ctx context.Context, files []*scan.File, selector *Selector, rep progress.Reporter) ([]result, error) {
+	if rep == nil {
+		rep = progress.Nop
+	}
+	rep.Start("parsing", int64(len(files)))
+
 	// Read and parse all files in parallel.
 	// The result, either a slice of Activities or an error is saved in res
 	wg := sync.WaitGroup{}
 	wg.Add(len(files))
-	res := make([]struct {
-		acts []*Activity
-		err  error
-	}, len(files))
+	res := make([]result, len(files))
 	for i := range files {
 		i := i
 		go func() {
 			defer wg.Done()
+			defer rep.Add(1)
+			if ctx.Err() != nil {
+				return
+			}
 			var parser func(io.Reader, *Selector) ([]*Activity, error)
 			switch files[i].Ext {
 			case ".fit":
@@ -48,24 +87,26 @@ func Parse(files []*scan.File, selector *Selector) ([]*Activity, *Stats, error)
 				res[i].err = err
 			} else {
 				res[i].acts, res[i].err = parser(r, selector)
+				for _, act := range res[i].acts {
+					act.Source = files[i].Path
+				}
 			}
 		}()
 	}
 	wg.Wait()
-
-	// print a warning for every file that was not parsed correctly,
-	// otherwise append it to an Activity slice.
-	activities := make([]*Activity, 0, len(files))
-	for _, r := range res {
-		if r.err != nil {
-			fmt.Fprintln(os.Stderr, "WARN:", r.err)
-		} else {
-			activities = append(activities, r.acts...)
-		}
+	rep.Finish()
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	return res, nil
+}
+
+// summarize removes the activities that fail the geographic filters of selector or are duplicates,
+// and summarizes the remaining activities to Stats.
+func summarize(activities []*Activity, selector *Selector) ([]*Activity, *Stats, error) {
 	// If not activities were (successfully) parsed, return an error
 	if len(activities) == 0 {
-		return nil, nil, errors.New("no matching activities found")
+		return nil, nil, ErrNoActivities
 	}
 
 	// Init stats with default (extreme) values
@@ -117,14 +158,14 @@ func Parse(files []*scan.File, selector *Selector) ([]*Activity, *Stats, error)
 		} else {
 			stats.SportCounts[strings.ToLower(act.Sport)]++
 		}
-		ts0, ts1 := act.Records[0].Timestamp, act.Records[len(act.Records)-1].Timestamp
+		ts0, ts1 := act.Start(), act.End()
 		if ts0.Before(stats.After) {
 			stats.After = ts0
 		}
 		if ts1.After(stats.Before) {
 			stats.Before = ts1
 		}
-		dur := ts1.Sub(ts0)
+		dur := act.Duration()
 		if dur < stats.MinDuration {
 			stats.MinDuration = dur
 		}
@@ -137,7 +178,7 @@ func Parse(files []*scan.File, selector *Selector) ([]*Activity, *Stats, error)
 		if act.Distance > stats.MaxDistance {
 			stats.MaxDistance = act.Distance
 		}
-		pace := time.Duration(float64(dur) / act.Distance)
+		pace := act.Pace()
 		if pace < stats.MinPace {
 			stats.MinPace = pace
 		}
@@ -158,7 +199,7 @@ func Parse(files []*scan.File, selector *Selector) ([]*Activity, *Stats, error)
 
 	// If no activities remain, return an error
 	if len(activities) == 0 {
-		return nil, nil, errors.New("no matching activities found")
+		return nil, nil, ErrNoActivities
 	}
 
 	// Finish stats
@@ -190,11 +231,25 @@ type Selector struct {
 	MinPace       time.Duration // MinPace specifies the minimum pace of activities.
 	MaxPace       time.Duration // MaxPace specifies the maximum pace of activities.
 	BoundedBy     geo.Circle    // BoundedBy specifies a Circle that activities must completely lay within.
+	BoundedByBox  geo.Box       // BoundedByBox specifies a Box that activities must completely lay within.
 	StartsNear    geo.Circle    // StartsNear specifies a Circle that the starting points of activities must lay within.
 	EndsNear      geo.Circle    // EndsNear specifies a Circle that the ending points of activities must lay within.
 	PassesThrough geo.Circle    // PassesThrough specifies a Circle that activities must pass through.
 }
 
+// Activity checks if the sport, time, duration, distance and pace of the parsed activity satisfy the Selector.
+func (s *Selector) Activity(act *Activity) bool {
+	if len(act.Records) == 0 {
+		return false
+	}
+	dur := act.Duration()
+	return s.Sport(act.Sport) &&
+		s.Timestamp(act.Start(), act.End()) &&
+		s.Duration(dur) &&
+		s.Distance(act.Distance) &&
+		s.Pace(dur, act.Distance)
+}
+
 // Sport checks if the given sport is included in the Selector's sports list.
 func (s *Selector) Sport(sport string) bool {
 	return len(s.Sports) == 0 || slices.IndexFunc(s.Sports, func(s string) bool { return strings.EqualFold(s, sport) }) >= 0
@@ -229,7 +284,8 @@ func (s *Selector) Pace(duration time.Duration, distance float64) bool {
 
 // Bounded checks if the activity falls within the bounding area specified by Selector.
 func (s *Selector) Bounded(pt geo.Point) bool {
-	return s.BoundedBy.IsZero() || s.BoundedBy.Contains(pt)
+	return (s.BoundedBy.IsZero() || s.BoundedBy.Contains(pt)) &&
+		(s.BoundedByBox.IsZero() || s.BoundedByBox.Contains(pt))
 }
 
 // Starts checks if the activity starts near the specified point by Selector.
@@ -249,17 +305,79 @@ func (s *Selector) Passes(pt geo.Point) bool {
 
 // Activity represents an activity with its sport, distance, and records.
 type Activity struct {
-	Sport    string    // Sport represents the type of sport for the activity.
-	Distance float64   // Distance represents the distance covered in the activity.
-	Records  []*Record // Records represents the records associated with the activity.
+	Source   string         // Source represents the path of the file the activity was parsed from.
+	Sport    string         // Sport represents the type of sport for the activity.
+	Distance float64        // Distance represents the distance covered in the activity.
+	Location *time.Location // Location represents the time zone the activity took place in, if known.
+	Records  []*Record      // Records represents the records associated with the activity.
+}
+
+// Start returns the timestamp of the first record of the activity.
+func (a *Activity) Start() time.Time {
+	return a.Records[0].Timestamp
+}
+
+// End returns the timestamp of the last record of the activity.
+func (a *Activity) End() time.Time {
+	return a.Records[len(a.Records)-1].Timestamp
+}
+
+// Duration returns the elapsed time between the first and last records of the activity.
+func (a *Activity) Duration() time.Duration {
+	return a.End().Sub(a.Start())
+}
+
+// Pace returns the average time taken to cover each meter of the activity.
+func (a *Activity) Pace() time.Duration {
+	return time.Duration(float64(a.Duration()) / a.Distance)
+}
+
+// Ascent returns the total elevation gained over the activity in meters.
+// Records without an elevation are skipped.
+func (a *Activity) Ascent() float64 {
+	ascent := 0.0
+	prev := math.NaN()
+	for _, r := range a.Records {
+		if math.IsNaN(r.Elevation) {
+			continue
+		}
+		if r.Elevation > prev {
+			ascent += r.Elevation - prev
+		}
+		prev = r.Elevation
+	}
+	return ascent
+}
+
+// Local returns the timestamp ts in the time zone of the activity.
+// If the time zone is unknown, it is estimated from the longitude of the first record.
+func (a *Activity) Local(ts time.Time) time.Time {
+	if a.Location != nil {
+		return ts.In(a.Location)
+	}
+	hours := math.Round(geo.RadiansToDegrees(geo.NormalizeLon(a.Records[0].Position.Lon)) / 15)
+	return ts.In(time.FixedZone("", int(hours)*3600))
 }
 
-// Record represents a record of an activity including timestamp, position, coordinates, and percent.
+// BoundedBy returns a Circle enclosing all records of the activity.
+func (a *Activity) BoundedBy() geo.Circle {
+	var box geo.Box
+	for _, r := range a.Records {
+		box = box.Enclose(r.Position)
+	}
+	c := geo.Circle{Origin: box.Center()}
+	for _, r := range a.Records {
+		c = c.Enclose(r.Position)
+	}
+	return c
+}
+
+// Record represents a record of an activity including timestamp, position, and percent.
 type Record struct {
 	Timestamp time.Time // Timestamp represents the time when the record was made.
 	Position  geo.Point // Position represents the geographical position associated with the record.
-	X         int       // X is the x-coordinate of the record.
-	Y         int       // Y is the y-coordinate of the record.
+	Elevation float64   // Elevation represents the altitude in meters of the record, or NaN if unknown.
+	HeartRate float64   // HeartRate represents the heart rate in beats per minute of the record, or NaN if unknown.
 	Percent   float64   // Percent represents a percentage associated with the record.
 }
 
@@ -284,26 +402,46 @@ type Stats struct {
 	Extent          geo.Box        // Extent is a Box enclosing all activities.
 }
 
-// Print prints statistics information using a given printer.
-func (s *Stats) Print(p *message.Printer) {
-	avgDur := s.SumDuration / time.Duration(s.CountActivities)
-	avgDist := s.SumDistance / float64(s.CountActivities)
-	avgPace := s.SumDuration / time.Duration(s.SumDistance)
+// AvgDuration returns the average duration of all activities.
+func (s *Stats) AvgDuration() time.Duration {
+	return s.SumDuration / time.Duration(s.CountActivities)
+}
+
+// AvgDistance returns the average distance of all activities.
+func (s *Stats) AvgDistance() float64 {
+	return s.SumDistance / float64(s.CountActivities)
+}
+
+// AvgPace returns the average pace over all activities combined.
+func (s *Stats) AvgPace() time.Duration {
+	return s.SumDuration / time.Duration(s.SumDistance)
+}
 
-	p.Printf("activities:    %d\n", s.CountActivities)
-	p.Printf("records:       %d\n", s.CountRecords)
-	p.Printf("sports:        %s\n", sprintSportStats(p, s.SportCounts))
-	p.Printf("period:        %s\n", sprintPeriod(p, s.After, s.Before))
-	p.Printf("duration:      %s to %s, average %s, total %s\n", sprintDuration(p, s.MinDuration), sprintDuration(p, s.MaxDuration), sprintDuration(p, avgDur), sprintDuration(p, s.SumDuration))
-	p.Printf("distance:      %s to %s, average %s, total %s\n", sprintDistance(p, s.MinDistance), sprintDistance(p, s.MaxDistance), sprintDistance(p, avgDist), sprintDistance(p, s.SumDistance))
-	p.Printf("pace:          %s to %s, average %s\n", sprintPace(p, s.MinPace), sprintPace(p, s.MaxPace), sprintPace(p, avgPace))
-	p.Printf("bounds:        %s\n", s.BoundedBy)
-	p.Printf("starts within: %s\n", s.StartsNear)
-	p.Printf("ends within:   %s\n", s.EndsNear)
+// Print prints statistics information to standard output using a given printer.
+func (s *Stats) Print(p *conv.Printer) {
+	s.Fprint(os.Stdout, p)
+}
+
+// Fprint prints statistics information to w using a given printer.
+func (s *Stats) Fprint(w io.Writer, p *conv.Printer) {
+	avgDur := s.AvgDuration()
+	avgDist := s.AvgDistance()
+	avgPace := s.AvgPace()
+
+	conv.FprintField(w, p, "activities", p.Sprintf("%d", s.CountActivities))
+	conv.FprintField(w, p, "records", p.Sprintf("%d", s.CountRecords))
+	conv.FprintField(w, p, "sports", sprintSportStats(p, s.SportCounts))
+	conv.FprintField(w, p, "period", sprintPeriod(p, s.After, s.Before))
+	conv.FprintField(w, p, "duration", p.Sprintf("%s to %s, average %s, total %s", conv.SprintDuration(p, s.MinDuration), conv.SprintDuration(p, s.MaxDuration), conv.SprintDuration(p, avgDur), conv.SprintDuration(p, s.SumDuration)))
+	conv.FprintField(w, p, "distance", p.Sprintf("%s to %s, average %s, total %s", conv.SprintDistance(p, s.MinDistance), conv.SprintDistance(p, s.MaxDistance), conv.SprintDistance(p, avgDist), conv.SprintDistance(p, s.SumDistance)))
+	conv.FprintField(w, p, "pace", p.Sprintf("%s to %s, average %s", conv.SprintPace(p, s.MinPace), conv.SprintPace(p, s.MaxPace), conv.SprintPace(p, avgPace)))
+	conv.FprintField(w, p, "bounds", s.BoundedBy)
+	conv.FprintField(w, p, "starts within", s.StartsNear)
+	conv.FprintField(w, p, "ends within", s.EndsNear)
 }
 
 // sprintSportStats formats sports statistics into a string using the given printer.
-func sprintSportStats(p *message.Printer, stats map[string]int) string {
+func sprintSportStats(p *conv.Printer, stats map[string]int) string {
 	// Convert the map into a slice of key-value pairs for sorting
 	pairs := make([]struct {
 		k string
@@ -335,7 +473,7 @@ func sprintSportStats(p *message.Printer, stats map[string]int) string {
 }
 
 // sprintPeriod formats the period between two dates into a string using the given printer.
-func sprintPeriod(p *message.Printer, minDate, maxDate time.Time) string {
+func sprintPeriod(p *conv.Printer, minDate, maxDate time.Time) string {
 	// Calculate the duration between minDate and maxDate
 	d := maxDate.Sub(minDate)
 	var num float64
@@ -358,23 +496,5 @@ func sprintPeriod(p *message.Printer, minDate, maxDate time.Time) string {
 		num, unit = d.Seconds(), "seconds"
 	}
 	// Format and return the period string
-	return p.Sprintf("%.1f %s (%s to %s)", num, unit, minDate.Format("2006-01-02"), maxDate.Format("2006-01-02"))
-}
-
-// sprintDuration formats the duration into a string using the given printer.
-// The duration is in seconds.
-func sprintDuration(p *message.Printer, dur time.Duration) string {
-	return p.Sprintf("%s", dur.Truncate(time.Second))
-}
-
-// sprintDistance formats the distance into a string using the given printer.
-// The distance is in kilometers.
-func sprintDistance(p *message.Printer, dist float64) string {
-	return p.Sprintf("%.1fkm", dist/1000)
-}
-
-// sprintPace formats the pace into a string using the given printer.
-// The pace is formatted as seconds per kilometer.
-func sprintPace(p *message.Printer, pace time.Duration) string {
-	return p.Sprintf("%s/km", (pace * 1000).Truncate(time.Second))
+	return p.Sprintf("%.1f %s (%s to %s)", num, p.Sprintf(unit), minDate.Format("2006-01-02"), maxDate.Format("2006-01-02"))
 }
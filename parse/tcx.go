@@ -1,19 +1,39 @@
 package parse
 
 import (
+	"encoding/xml"
+	"fmt"
 	"io"
+	"math"
 
 	"github.com/NathanBaulch/rainbow-roads/geo"
 	"github.com/llehouerou/go-tcx"
 )
 
+// tcxFile is the part of a TCX document that is parsed, like tcx.Tcx but with trackpoints that know whether they have an altitude.
+type tcxFile struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		Laps  []struct {
+			DistanceInMeters float64         `xml:"DistanceMeters"`
+			Track            []tcxTrackpoint `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+// tcxTrackpoint is a tcx.Trackpoint whose altitude is nil when the optional AltitudeMeters element is absent.
+type tcxTrackpoint struct {
+	tcx.Trackpoint
+	AltitudeMeters *float64 `xml:"AltitudeMeters"` // The altitude in meters, or nil if missing
+}
+
 // parseTCX parses text in TCX format from r and returns a slice of activities that pass the selector filter.
 // If an error occurs when parsing the TCX data, this error is returned.
 func parseTCX(r io.Reader, selector *Selector) ([]*Activity, error) {
 	// Parse r to a TCX type struct
-	f, err := tcx.Parse(r)
-	if err != nil {
-		return nil, err
+	f := &tcxFile{}
+	if err := xml.NewDecoder(r).Decode(f); err != nil {
+		return nil, fmt.Errorf("couldn't parse tcx data: %v", err)
 	}
 
 	// Init slice of activities
@@ -32,7 +52,7 @@ func parseTCX(r io.Reader, selector *Selector) ([]*Activity, error) {
 			Records: make([]*Record, 0, len(a.Laps[0].Track)),
 		}
 
-		var t0, t1 tcx.Trackpoint
+		var t0, t1 tcxTrackpoint
 		for _, l := range a.Laps {
 			// Skip if the laps does not contain any GPS points
 			if len(l.Track) == 0 {
@@ -57,9 +77,20 @@ func parseTCX(r io.Reader, selector *Selector) ([]*Activity, error) {
 				t1 = t
 
 				// Append the time and position to the activity
+				// The elevation is missing if the optional altitude element is absent, while 0 is sea level
+				ele := math.NaN()
+				if t.AltitudeMeters != nil {
+					ele = *t.AltitudeMeters
+				}
+				hr := math.NaN()
+				if t.HeartRateInBpm > 0 {
+					hr = float64(t.HeartRateInBpm)
+				}
 				act.Records = append(act.Records, &Record{
 					Timestamp: t.Time,
 					Position:  geo.NewPointFromDegrees(t.LatitudeInDegrees, t.LongitudeInDegrees),
+					Elevation: ele,
+					HeartRate: hr,
 				})
 			}
 		}
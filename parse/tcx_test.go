@@ -2,6 +2,8 @@ package parse
 
 import (
 	"bytes"
+	"fmt"
+	"math"
 	"testing"
 )
 
@@ -25,3 +27,52 @@ func TestTCXNoPosition(t *testing.T) {
 		t.Fatal("expected no activities")
 	}
 }
+
+func TestTCXElevation(t *testing.T) {
+	testCases := []struct {
+		altitude string
+		expect   float64
+	}{
+		{"<AltitudeMeters>12.5</AltitudeMeters>", 12.5},
+		{"<AltitudeMeters>0</AltitudeMeters>", 0},
+		{"", math.NaN()},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			acts, err := parseTCX(bytes.NewBufferString(fmt.Sprintf(`
+				<TrainingCenterDatabase>
+				  <Activities>
+				    <Activity Sport="Running">
+				      <Lap>
+				        <DistanceMeters>100</DistanceMeters>
+				        <Track>
+				          <Trackpoint>
+				            <Time>2022-02-10T00:00:00Z</Time>
+				            <Position><LatitudeDegrees>-37.8</LatitudeDegrees><LongitudeDegrees>144.9</LongitudeDegrees></Position>
+				            %[1]s
+				          </Trackpoint>
+				          <Trackpoint>
+				            <Time>2022-02-10T00:01:00Z</Time>
+				            <Position><LatitudeDegrees>-37.81</LatitudeDegrees><LongitudeDegrees>144.91</LongitudeDegrees></Position>
+				            %[1]s
+				          </Trackpoint>
+				        </Track>
+				      </Lap>
+				    </Activity>
+				  </Activities>
+				</TrainingCenterDatabase>`, testCase.altitude)), &Selector{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(acts) != 1 {
+				t.Fatal(len(acts), "!=", 1)
+			}
+			for _, rec := range acts[0].Records {
+				if actual := rec.Elevation; actual != testCase.expect && !(math.IsNaN(actual) && math.IsNaN(testCase.expect)) {
+					t.Fatal(actual, "!=", testCase.expect)
+				}
+			}
+		})
+	}
+}
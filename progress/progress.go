@@ -0,0 +1,154 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reporter receives progress events from the stages of a long running operation.
+// Implementations must be safe for concurrent use.
+type Reporter interface {
+	// Start begins a stage with the given name and total units of work, or zero if the total is unknown.
+	Start(stage string, total int64)
+	// Add reports that n more units of work of the current stage are complete.
+	Add(n int64)
+	// Finish reports that the current stage is complete.
+	Finish()
+}
+
+// Nop is a Reporter that ignores all events.
+var Nop Reporter = nop{}
+
+// nop is the type of Nop.
+type nop struct{}
+
+// Start does nothing.
+func (nop) Start(string, int64) {}
+
+// Add does nothing.
+func (nop) Add(int64) {}
+
+// Finish does nothing.
+func (nop) Finish() {}
+
+// Bar is a Reporter that draws a single line progress bar per stage to a terminal.
+// Stages with an unknown total are drawn as the number of bytes completed.
+type Bar struct {
+	w       io.Writer     // Where to draw the bar, usually standard error
+	mu      sync.Mutex    // Guards the fields below
+	stage   string        // The name of the current stage
+	total   int64         // The total units of work of the current stage, or zero if unknown
+	done    int64         // The units of work of the current stage completed so far
+	drawn   time.Time     // When the bar was last drawn
+	every   time.Duration // The minimum time between draws
+	started bool          // Whether a stage is in progress
+}
+
+// barWidth is the number of characters in a bar.
+const barWidth = 30
+
+// NewBar creates a Bar that draws to w.
+func NewBar(w io.Writer) *Bar {
+	return &Bar{w: w, every: 100 * time.Millisecond}
+}
+
+// Start begins a stage with the given name and total units of work, finishing any stage already in progress.
+func (b *Bar) Start(stage string, total int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.started {
+		b.finish()
+	}
+	b.stage, b.total, b.done, b.started = stage, total, 0, true
+	b.draw()
+}
+
+// Add reports that n more units of work of the current stage are complete.
+func (b *Bar) Add(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done += n
+	if time.Since(b.drawn) >= b.every {
+		b.draw()
+	}
+}
+
+// Finish draws the final state of the current stage and ends its line.
+func (b *Bar) Finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.started {
+		b.finish()
+	}
+}
+
+// finish draws the final state of the current stage and ends its line, with the lock held.
+func (b *Bar) finish() {
+	b.draw()
+	_, _ = fmt.Fprintln(b.w)
+	b.started = false
+}
+
+// draw redraws the line of the current stage, with the lock held.
+func (b *Bar) draw() {
+	b.drawn = time.Now()
+	if b.total > 0 {
+		n := int(b.done * barWidth / b.total)
+		if n > barWidth {
+			n = barWidth
+		}
+		bar := strings.Repeat("#", n) + strings.Repeat("-", barWidth-n)
+		_, _ = fmt.Fprintf(b.w, "\r%-10s [%s] %3d%% %d/%d", b.stage, bar, b.done*100/b.total, b.done, b.total)
+	} else if b.done > 0 {
+		_, _ = fmt.Fprintf(b.w, "\r%-10s %s", b.stage, formatBytes(b.done))
+	} else {
+		_, _ = fmt.Fprintf(b.w, "\r%-10s ...", b.stage)
+	}
+}
+
+// formatBytes formats n bytes with a binary unit prefix, eg 1.5MiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// writer is an io.Writer that reports the bytes written and stops once its context is done.
+type writer struct {
+	ctx context.Context
+	w   io.Writer
+	rep Reporter
+}
+
+// NewWriter wraps w to report every byte written to rep, failing with the context error once ctx is done.
+func NewWriter(ctx context.Context, w io.Writer, rep Reporter) io.Writer {
+	return &writer{ctx, w, rep}
+}
+
+// Write writes p to the underlying writer unless the context is done.
+func (w *writer) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := w.w.Write(p)
+	w.rep.Add(int64(n))
+	return n, err
+}
+
+// IsTerminal returns true if f is a character device, such as an interactive terminal.
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,85 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestBar(t *testing.T) {
+	testCases := []struct {
+		total  int64
+		adds   []int64
+		expect string
+	}{
+		{0, nil, "\rstage      ...\rstage      ...\n"},
+		{0, []int64{1536}, "\rstage      ...\rstage      1.5KiB\n"},
+		{4, []int64{1, 1}, "\rstage      [------------------------------]   0% 0/4\rstage      [###############---------------]  50% 2/4\n"},
+		{2, []int64{3}, "\rstage      [------------------------------]   0% 0/2\rstage      [##############################] 150% 3/2\n"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			b := &bytes.Buffer{}
+			bar := NewBar(b)
+			bar.every = 1 << 62
+			bar.Start("stage", testCase.total)
+			for _, n := range testCase.adds {
+				bar.Add(n)
+			}
+			bar.Finish()
+			bar.Finish()
+			if actual := b.String(); actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	testCases := []struct {
+		n      int64
+		expect string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1.0KiB"},
+		{3 << 20, "3.0MiB"},
+		{5 << 30, "5.0GiB"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			if actual := formatBytes(testCase.n); actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+// counter is a Reporter that sums the units of work added.
+type counter struct{ n int64 }
+
+func (c *counter) Start(string, int64) {}
+func (c *counter) Add(n int64)         { c.n += n }
+func (c *counter) Finish()             {}
+
+func TestWriter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &bytes.Buffer{}
+	c := &counter{}
+	w := NewWriter(ctx, b, c)
+
+	if n, err := w.Write([]byte("hello")); err != nil || n != 5 {
+		t.Fatal(n, err)
+	}
+	cancel()
+	if _, err := w.Write([]byte("world")); !errors.Is(err, context.Canceled) {
+		t.Fatal(err, "!=", context.Canceled)
+	}
+	if b.String() != "hello" || c.n != 5 {
+		t.Fatal(b.String(), c.n)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/NathanBaulch/rainbow-roads/manifest"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// replayCmd represents the "replay" command
+var replayCmd = &cobra.Command{
+	Use:   "replay file",
+	Short: "Re-render an image from its embedded manifest",
+	// Flags are parsed by the replayed command
+	DisableFlagParsing: true,
+	// Run the command
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+			return cmd.Help()
+		}
+		return cancelled(cmd, replay(cmd.Context(), args[0], args[1:]))
+	},
+}
+
+// unrecordedFlags are the flags that are never recorded in a manifest, since they do not affect the rendering.
+var unrecordedFlags = map[string]bool{"config": true, "profile": true, "help": true}
+
+func init() {
+	// Add the "replay" command to the root command
+	rootCmd.AddCommand(replayCmd)
+
+	// Prints the help command
+	replayCmd.SetUsageFunc(func(*cobra.Command) error {
+		fmt.Fprintln(replayCmd.OutOrStderr())
+		fmt.Fprintln(replayCmd.OutOrStderr(), "Usage:")
+		fmt.Fprintln(replayCmd.OutOrStderr(), " ", replayCmd.UseLine(), "[input]")
+		fmt.Fprintln(replayCmd.OutOrStderr())
+		fmt.Fprintln(replayCmd.OutOrStderr(), "Reads the render manifest embedded in a gif, png or zip file and renders it again.")
+		fmt.Fprintln(replayCmd.OutOrStderr(), "Any flags or inputs of the original command override the recorded values.")
+		fmt.Fprintln(replayCmd.OutOrStderr(), "The output defaults to the file name with a .replay suffix.")
+		return nil
+	})
+}
+
+// newManifest creates a manifest recording every flag value of cmd and the input paths args.
+func newManifest(cmd *cobra.Command, args []string) *manifest.Manifest {
+	return recordManifest(cmd.Name(), cmd.Flags(), args)
+}
+
+// recordManifest creates a manifest of the named command recording every flag value of fs and the input paths args.
+func recordManifest(command string, fs *pflag.FlagSet, args []string) *manifest.Manifest {
+	flags := make(map[string]string)
+	fs.VisitAll(func(f *pflag.Flag) {
+		if !unrecordedFlags[f.Name] {
+			flags[f.Name] = f.Value.String()
+		}
+	})
+	program := Title
+	if Version != "" {
+		program += " " + Version
+	}
+	return manifest.New(program, command, flags, args)
+}
+
+// replayCommands are the commands that embed a manifest in their output.
+func replayCommands() []*cobra.Command {
+	return []*cobra.Command{wormsCmd, paintCmd, heatmapCmd}
+}
+
+// replay re-renders the manifest embedded in the file at path, with command line args overriding the recorded flags and inputs.
+func replay(ctx context.Context, path string, args []string) error {
+	m, err := manifest.Read(path)
+	if err != nil {
+		return err
+	}
+
+	// Find the command that rendered the file
+	var cmd *cobra.Command
+	for _, c := range replayCommands() {
+		if c.Name() == m.Command {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		return fmt.Errorf("command %q not supported", m.Command)
+	}
+
+	// Parse the overrides
+	if err := cmd.ParseFlags(args); err != nil {
+		return err
+	}
+
+	// Never overwrite the file being replayed
+	if !cmd.Flags().Changed("output") {
+		ext := filepath.Ext(path)
+		if err := cmd.Flags().Set("output", strings.TrimSuffix(path, ext)+".replay"+ext); err != nil {
+			return err
+		}
+	}
+
+	// Apply the recorded flags that were not overridden
+	if err := applyManifest(cmd.Flags(), m); err != nil {
+		return err
+	}
+	inputs := cmd.Flags().Args()
+	if len(inputs) == 0 {
+		inputs = m.Inputs
+	}
+
+	// Warn about any inputs that differ from those recorded
+	if files, err := scan.Scan(ctx, inputs); err != nil {
+		return err
+	} else if diffs, err := m.Verify(files); err != nil {
+		return err
+	} else {
+		for _, d := range diffs {
+			fmt.Fprintln(os.Stderr, "WARN:", d)
+		}
+	}
+
+	// Validate the flags as cobra would, then run the command
+	if err := cmd.ValidateArgs(inputs); err != nil {
+		return err
+	}
+	if err := cmd.ValidateRequiredFlags(); err != nil {
+		return err
+	}
+	if err := cmd.ValidateFlagGroups(); err != nil {
+		return err
+	}
+	cmd.SetContext(ctx)
+	if err := cmd.PreRunE(cmd, inputs); err != nil {
+		return err
+	}
+	return cmd.RunE(cmd, inputs)
+}
+
+// applyManifest sets the flags of flags to the values recorded in m, in a stable order.
+// Flags that were changed are skipped along with the flags mutually exclusive with them, as are flags that no longer exist.
+// Recorded values that the flag already holds are left alone, and only mark the flag as changed if they differ from its default,
+// so that flag groups validate as they did when recorded.
+func applyManifest(flags *pflag.FlagSet, m *manifest.Manifest) error {
+	names := make([]string, 0, len(m.Flags))
+	for n := range m.Flags {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	set := settledFlags(flags)
+	for _, n := range names {
+		f := flags.Lookup(n)
+		if f == nil {
+			fmt.Fprintf(os.Stderr, "WARN: recorded flag %q not recognized\n", n)
+			continue
+		}
+		if set[n] || unrecordedFlags[n] {
+			continue
+		}
+		if m.Flags[n] != f.Value.String() {
+			if err := f.Value.Set(m.Flags[n]); err != nil {
+				return fmt.Errorf("invalid recorded value %q for flag %s: %w", m.Flags[n], n, err)
+			}
+		}
+		f.Changed = m.Flags[n] != f.DefValue
+	}
+	return nil
+}
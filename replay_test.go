@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/heatmap"
+	"github.com/NathanBaulch/rainbow-roads/paint"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/worms"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestApplyManifest(t *testing.T) {
+	testCases := []struct {
+		record   []string
+		override []string
+		expect   []string
+	}{
+		{nil, nil, nil},
+		{[]string{"--sport", "running,walking", "--after", "2020-03-01", "--min_distance", "2km"}, nil, []string{"--sport", "running,walking", "--after", "2020-03-01", "--min_distance", "2km"}},
+		{[]string{"--min_duration", "15m", "--max_pace", "10m/mi", "--bounded_by", "-37.8,144.9,10km"}, nil, []string{"--min_duration", "15m", "--max_pace", "10m/mi", "--bounded_by", "-37.8,144.9,10km"}},
+		{[]string{"--bounded_by_box", "-37.9,144.8,-37.7,145", "--passes_through", "geo:40.69,-74.12;u=16093"}, nil, []string{"--bounded_by_box", "-37.9,144.8,-37.7,145", "--passes_through", "geo:40.69,-74.12;u=16093"}},
+		{[]string{"--sport", "running", "--before", "2021-06-30T12:00:00+10:00"}, []string{"--sport", "cycling"}, []string{"--sport", "cycling", "--before", "2021-06-30T12:00:00+10:00"}},
+		{[]string{"--min_distance", "2km"}, []string{"--max_distance", "5km"}, []string{"--min_distance", "2km", "--max_distance", "5km"}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			newCmd := func(args []string) (*cobra.Command, *parse.Selector) {
+				sel := &parse.Selector{}
+				cmd := &cobra.Command{Use: "worms"}
+				filterFlagSet(sel).VisitAll(func(f *pflag.Flag) { cmd.Flags().Var(f.Value, f.Name, f.Usage) })
+				if err := cmd.Flags().Parse(args); err != nil {
+					t.Fatal(err)
+				}
+				return cmd, sel
+			}
+
+			// Record the flags, then apply them to a fresh command with the overrides
+			cmd, _ := newCmd(testCase.record)
+			m := newManifest(cmd, nil)
+			cmd, actual := newCmd(testCase.override)
+			if err := applyManifest(cmd.Flags(), m); err != nil {
+				t.Fatal(err)
+			}
+
+			_, expect := newCmd(testCase.expect)
+			if !reflect.DeepEqual(actual, expect) {
+				t.Fatal(actual, "!=", expect)
+			}
+		})
+	}
+}
+
+func TestApplyManifestDefaults(t *testing.T) {
+	testCases := []func() (any, *pflag.FlagSet){
+		func() (any, *pflag.FlagSet) {
+			opts := &worms.Options{}
+			general, rendering := wormsFlagSets(opts)
+			general.AddFlagSet(rendering)
+			general.AddFlagSet(filterFlagSet(&opts.Selector))
+			return opts, general
+		},
+		func() (any, *pflag.FlagSet) {
+			opts := &paint.Options{}
+			general, rendering := paintFlagSets(opts)
+			general.AddFlagSet(rendering)
+			general.AddFlagSet(filterFlagSet(&opts.Selector))
+			return opts, general
+		},
+		func() (any, *pflag.FlagSet) {
+			opts := &heatmap.Options{}
+			general, rendering := heatmapFlagSets(opts)
+			general.AddFlagSet(rendering)
+			general.AddFlagSet(filterFlagSet(&opts.Selector))
+			return opts, general
+		},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			// Record the default flags, then apply them to fresh flags holding a different width
+			_, fs := testCase()
+			m := recordManifest("test", fs, nil)
+			actual, fs := testCase()
+			if err := fs.Lookup("width").Value.Set("123"); err != nil {
+				t.Fatal(err)
+			}
+			if err := applyManifest(fs, m); err != nil {
+				t.Fatal(err)
+			}
+
+			expect, _ := testCase()
+			if !reflect.DeepEqual(actual, expect) {
+				t.Fatal(actual, "!=", expect)
+			}
+			fs.Visit(func(f *pflag.Flag) { t.Fatal(f.Name, "changed") })
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -15,14 +16,18 @@ import (
 
 // File represents a file with its extension and an opener function
 type File struct {
+	Path   string                    // Path represents the location of the file, including any containing zip files
 	Ext    string                    // Ext represents the file extension
 	Opener func() (io.Reader, error) // Opener is a function to open the file and return an io.Reader
 }
 
-// Scan scans the provided paths and returns a slice of files and an error if any
-func Scan(paths []string) ([]*File, error) {
+// Scan scans the provided paths and returns a slice of files and an error if any, stopping early once ctx is done
+func Scan(ctx context.Context, paths []string) ([]*File, error) {
 	var files []*File
-	err := walkPaths(paths, func(fsys fs.FS, path string) error {
+	err := walkPaths(paths, func(fsys fs.FS, path, name string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		ext := strings.ToLower(filepath.Ext(path))
 		opener := func() (io.Reader, error) { return fsys.Open(path) }
 		if ext == ".gz" {
@@ -35,14 +40,17 @@ func Scan(paths []string) ([]*File, error) {
 				}
 			}
 		}
-		files = append(files, &File{ext, opener})
+		files = append(files, &File{name, ext, opener})
 		return nil
 	})
 	return files, err
 }
 
+// walkFunc is the function executed on each file, where path is relative to fsys and name is the full display path
+type walkFunc func(fsys fs.FS, path, name string) error
+
 // walkPaths walks through the provided paths and executes the given function on each path
-func walkPaths(paths []string, fn func(fsys fs.FS, path string) error) error {
+func walkPaths(paths []string, fn walkFunc) error {
 	for _, path := range paths {
 		paths := []string{path}
 		if strings.ContainsAny(path, "*?[") {
@@ -68,10 +76,10 @@ func walkPaths(paths []string, fn func(fsys fs.FS, path string) error) error {
 				}
 				return err
 			} else if fi.IsDir() {
-				if err := walkDir(fsys, name, fn); err != nil {
+				if err := walkDir(fsys, name, dir, fn); err != nil {
 					return err
 				}
-			} else if err := walkFile(fsys, name, fn); err != nil {
+			} else if err := walkFile(fsys, name, dir, fn); err != nil {
 				return err
 			}
 		}
@@ -81,18 +89,18 @@ func walkPaths(paths []string, fn func(fsys fs.FS, path string) error) error {
 }
 
 // walkDir walks through a directory and executes the given function on each file
-func walkDir(fsys fs.FS, path string, fn func(fsys fs.FS, path string) error) error {
+func walkDir(fsys fs.FS, path, prefix string, fn walkFunc) error {
 	return fs.WalkDir(fsys, path, func(path string, d fs.DirEntry, err error) error {
 		if err != nil || d.IsDir() {
 			return err
 		} else {
-			return walkFile(fsys, path, fn)
+			return walkFile(fsys, path, prefix, fn)
 		}
 	})
 }
 
 // walkFile walks through a file and executes the given function if it's not a zip file
-func walkFile(fsys fs.FS, path string, fn func(fsys fs.FS, path string) error) error {
+func walkFile(fsys fs.FS, path, prefix string, fn walkFunc) error {
 	if strings.EqualFold(filepath.Ext(path), ".zip") {
 		if f, err := fsys.Open(path); err != nil {
 			return err
@@ -110,10 +118,10 @@ func walkFile(fsys fs.FS, path string, fn func(fsys fs.FS, path string) error) e
 			if fsys, err := zip.NewReader(r, s.Size()); err != nil {
 				return err
 			} else {
-				return walkDir(fsys, ".", fn)
+				return walkDir(fsys, ".", filepath.Join(prefix, path), fn)
 			}
 		}
 	} else {
-		return fn(fsys, path)
+		return fn(fsys, path, filepath.Join(prefix, path))
 	}
 }
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/paint"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/NathanBaulch/rainbow-roads/serve"
+	"github.com/NathanBaulch/rainbow-roads/stats"
+	"github.com/NathanBaulch/rainbow-roads/worms"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// serveOpts are the options of the HTTP render service
+	serveOpts = &serve.Options{}
+	// serveLimits are the size limits of the served renders
+	serveLimits = &renderLimits{}
+	// serveCmd represents the "serve" command
+	serveCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Serve worms, paint and stats renders over HTTP",
+		// Pre-checks to ensure value are in bounds
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if serveOpts.Concurrency < 1 {
+				return flagError("concurrency", serveOpts.Concurrency, "must be positive")
+			}
+			if serveOpts.MaxBody < 1 {
+				return flagError("max_body", serveOpts.MaxBody, "must be positive")
+			}
+			if serveOpts.Timeout < 0 {
+				return flagError("timeout", serveOpts.Timeout, "must not be negative")
+			}
+			return nil
+		},
+		// Run the command
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serveOpts.Input = args
+			serveOpts.Endpoints = serveEndpoints(serveLimits)
+			return cancelled(cmd, serve.Run(cmd.Context(), serveOpts))
+		},
+	}
+)
+
+// renderLimits are the largest renders a client may request.
+type renderLimits struct {
+	Width    uint    // The maximum width of an image in pixels
+	Frames   uint    // The maximum number of animation frames
+	Memory   uint    // The maximum memory of the rendered frames in megabytes, counting 4 bytes per truecolor pixel
+	FontSize float64 // The maximum size of the overlay text in pixels
+	Radius   float64 // The maximum radius of a paint region in meters, or half the width and height of a region box
+}
+
+// maxBytes returns the memory limit in bytes.
+func (l *renderLimits) maxBytes() uint64 {
+	return uint64(l.Memory) << 20
+}
+
+// unservedRenderFlags are the flags of the "worms" and "paint" commands that clients may not set,
+// since they refer to local files or programs, or only affect the printed stats.
+var unservedRenderFlags = map[string]bool{"output": true, "encoder": true, "font": true, "stats_format": true, "stats_file": true, "locale": true, "units": true}
+
+// unservedStatsFlags are the flags of the "stats" command that clients may not set, since they refer to local files.
+var unservedStatsFlags = map[string]bool{"stats_file": true}
+
+// wormsContentTypes maps each worms output format to its media type.
+var wormsContentTypes = map[string]string{
+	"gif": "image/gif",
+	"png": "image/apng",
+	"zip": "application/zip",
+	"avi": "video/x-msvideo",
+	"y4m": "video/x-yuv4mpeg",
+	"mp4": "video/mp4",
+}
+
+// statsContentTypes maps each stats output format to its media type.
+var statsContentTypes = map[string]string{"text": "text/plain; charset=utf-8", "json": "application/json", "yaml": "application/yaml"}
+
+func init() {
+	// Add the "serve" command to the root command
+	rootCmd.AddCommand(serveCmd)
+
+	// General flags (address and limits)
+	general := &pflag.FlagSet{}
+	general.StringVar(&serveOpts.Addr, "addr", ":8080", "TCP address to listen on")
+	general.IntVar(&serveOpts.Concurrency, "concurrency", runtime.NumCPU(), "maximum number of requests rendered at once, further requests are rejected")
+	general.Int64Var(&serveOpts.MaxBody, "max_body", 1<<20, "maximum size of a JSON request body in bytes")
+	general.DurationVar(&serveOpts.Timeout, "timeout", 5*time.Minute, "maximum duration of each render, or 0 for no limit")
+	general.UintVar(&serveLimits.Width, "max_width", 2000, "maximum width of a requested image in pixels")
+	general.UintVar(&serveLimits.Frames, "max_frames", 1000, "maximum number of requested animation frames")
+	general.UintVar(&serveLimits.Memory, "max_memory", 1024, "maximum memory of the frames of a requested render in megabytes, counting 4 bytes per truecolor pixel")
+	general.Float64Var(&serveLimits.FontSize, "max_font_size", 200, "maximum size of the requested overlay text in pixels")
+	serveLimits.Radius = 50000
+	general.Var((*DistanceFlag)(&serveLimits.Radius), "max_radius", "maximum radius of a requested paint region, or half the width and height of a region box")
+	general.AddFlagSet(configFlagSet())
+	general.VisitAll(func(f *pflag.Flag) { serveCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+
+	// Prints the help command
+	serveCmd.SetUsageFunc(func(*cobra.Command) error {
+		fmt.Fprintln(serveCmd.OutOrStderr())
+		fmt.Fprintln(serveCmd.OutOrStderr(), "Usage:")
+		fmt.Fprintln(serveCmd.OutOrStderr(), " ", serveCmd.UseLine(), "[input]")
+		fmt.Fprintln(serveCmd.OutOrStderr())
+		fmt.Fprintln(serveCmd.OutOrStderr(), "Serves the /worms, /paint and /stats endpoints, rendered from the activities in input.")
+		fmt.Fprintln(serveCmd.OutOrStderr(), "Options are the flags of the command, passed as query parameters or a JSON object in a POST body.")
+		fmt.Fprintln(serveCmd.OutOrStderr())
+		fmt.Fprintln(serveCmd.OutOrStderr(), "General flags:")
+		fmt.Fprint(serveCmd.OutOrStderr(), general.FlagUsages())
+		return nil
+	})
+}
+
+// serveEndpoints returns the served commands, limited to the renders allowed by limits.
+func serveEndpoints(limits *renderLimits) []serve.Endpoint {
+	return []serve.Endpoint{
+		{Name: wormsCmd.Name(), New: func() serve.Request { return newWormsRequest(limits) }},
+		{Name: paintCmd.Name(), New: func() serve.Request { return newPaintRequest(limits) }},
+		{Name: statsCmd.Name(), New: func() serve.Request { return newStatsRequest() }},
+	}
+}
+
+// requestFlags combines the flag sets into one, skipping the flags in skip.
+func requestFlags(skip map[string]bool, sets ...*pflag.FlagSet) *pflag.FlagSet {
+	fs := &pflag.FlagSet{}
+	for _, set := range sets {
+		set.VisitAll(func(f *pflag.Flag) {
+			if !skip[f.Name] {
+				fs.AddFlag(f)
+			}
+		})
+	}
+	return fs
+}
+
+// checkLimit returns a flag error if the named value is larger than max.
+func checkLimit[T uint | float64](name string, value, max T) error {
+	if value > max {
+		return flagError(name, value, fmt.Sprintf("must be at most %v", max))
+	}
+	return nil
+}
+
+// wormsRequest is a served worms animation.
+type wormsRequest struct {
+	opts   worms.Options   // The options of the animation
+	flags  *pflag.FlagSet  // The flags bound to opts
+	limits *renderLimits   // The largest allowed animation
+	r      *worms.Renderer // The renderer, once rendered
+	warns  []string        // The warnings of the render
+}
+
+// newWormsRequest creates a worms request with the default options of the "worms" command.
+func newWormsRequest(limits *renderLimits) *wormsRequest {
+	req := &wormsRequest{opts: worms.Options{Title: Title, Version: Version, Projection: "mercator"}, limits: limits}
+	general, rendering := wormsFlagSets(&req.opts)
+	req.flags = requestFlags(unservedRenderFlags, general, rendering, filterFlagSet(&req.opts.Selector))
+	return req
+}
+
+// Flags returns the options of the request.
+func (req *wormsRequest) Flags() *pflag.FlagSet {
+	return req.flags
+}
+
+// Check validates the options and enforces the size limits.
+func (req *wormsRequest) Check() error {
+	if err := checkWorms(&req.opts); err != nil {
+		return err
+	}
+	if _, ok := wormsContentTypes[req.opts.Format]; !ok {
+		return flagError("format", req.opts.Format, "supports "+strings.Join(worms.Formats, ", "))
+	}
+	if err := checkLimit("width", req.opts.Width, req.limits.Width); err != nil {
+		return err
+	}
+	if err := checkLimit("frames", req.opts.Frames, req.limits.Frames); err != nil {
+		return err
+	}
+	req.opts.MaxBytes = req.limits.maxBytes()
+	return checkLimit("font_size", req.opts.Text.Size, req.limits.FontSize)
+}
+
+// Render renders the animation.
+func (req *wormsRequest) Render(ctx context.Context, input []string, cache *parse.Cache) error {
+	req.opts.Input = input
+	req.opts.Cache = cache
+	req.opts.Manifest = recordManifest(wormsCmd.Name(), req.flags, input)
+	req.opts.OnWarn = func(msg string) { req.warns = append(req.warns, msg) }
+	req.r = worms.New(&req.opts)
+	_, err := req.r.Render(ctx)
+	return err
+}
+
+// Warnings returns the warnings of the render.
+func (req *wormsRequest) Warnings() []string {
+	return req.warns
+}
+
+// ContentType returns the media type of the output format.
+func (req *wormsRequest) ContentType() string {
+	return wormsContentTypes[req.opts.Format]
+}
+
+// Save streams the animation to w.
+func (req *wormsRequest) Save(ctx context.Context, w io.Writer) error {
+	return req.r.Save(ctx, w)
+}
+
+// paintRequest is a served paint map.
+type paintRequest struct {
+	opts   paint.Options   // The options of the map
+	flags  *pflag.FlagSet  // The flags bound to opts
+	limits *renderLimits   // The largest allowed map
+	r      *paint.Renderer // The renderer, once rendered
+}
+
+// newPaintRequest creates a paint request with the default options of the "paint" command.
+func newPaintRequest(limits *renderLimits) *paintRequest {
+	req := &paintRequest{opts: paint.Options{Title: Title, Version: Version, Projection: "mercator"}, limits: limits}
+	general, rendering := paintFlagSets(&req.opts)
+	req.flags = requestFlags(unservedRenderFlags, general, rendering, filterFlagSet(&req.opts.Selector))
+	return req
+}
+
+// Flags returns the options of the request.
+func (req *paintRequest) Flags() *pflag.FlagSet {
+	return req.flags
+}
+
+// Check validates the options and enforces the size limits.
+func (req *paintRequest) Check() error {
+	if err := checkPaint(&req.opts); err != nil {
+		return err
+	}
+	if req.opts.Region.IsZero() == req.opts.RegionBox.IsZero() {
+		return errors.New("exactly one of region or region_box is required")
+	}
+	if err := checkLimit("width", req.opts.Width, req.limits.Width); err != nil {
+		return err
+	}
+	if err := checkLimit("font_size", req.opts.Text.Size, req.limits.FontSize); err != nil {
+		return err
+	}
+	req.opts.MaxBytes = req.limits.maxBytes()
+
+	// Keep the map small enough to load quickly
+	if !req.opts.Region.IsZero() {
+		if req.opts.Region.Radius > req.limits.Radius {
+			return flagError("region", (*CircleFlag)(&req.opts.Region), fmt.Sprintf("radius must be at most %sm", conv.FormatFloat(req.limits.Radius)))
+		}
+	} else if w, h := req.opts.RegionBox.Size(); w > 2*req.limits.Radius || h > 2*req.limits.Radius {
+		return flagError("region_box", (*BoxFlag)(&req.opts.RegionBox), fmt.Sprintf("width and height must be at most %sm", conv.FormatFloat(2*req.limits.Radius)))
+	}
+	return nil
+}
+
+// Render paints the map.
+func (req *paintRequest) Render(ctx context.Context, input []string, cache *parse.Cache) error {
+	req.opts.Input = input
+	req.opts.Cache = cache
+	req.opts.Manifest = recordManifest(paintCmd.Name(), req.flags, input)
+	req.r = paint.New(&req.opts)
+	_, err := req.r.Render(ctx)
+	return err
+}
+
+// ContentType returns the media type of a png.
+func (req *paintRequest) ContentType() string {
+	return "image/png"
+}
+
+// Save streams the map to w.
+func (req *paintRequest) Save(ctx context.Context, w io.Writer) error {
+	return req.r.Save(ctx, w)
+}
+
+// statsRequest is a served stats report.
+type statsRequest struct {
+	opts    stats.Options  // The options of the report
+	flags   *pflag.FlagSet // The flags bound to opts
+	printer *conv.Printer  // The printer for the locale and units, once checked
+	report  *stats.Report  // The report, once rendered
+}
+
+// newStatsRequest creates a stats request with the default options of the "stats" command.
+func newStatsRequest() *statsRequest {
+	req := &statsRequest{}
+	req.flags = requestFlags(unservedStatsFlags, statsCmdFlagSet(&req.opts), filterFlagSet(&req.opts.Selector))
+	return req
+}
+
+// Flags returns the options of the request.
+func (req *statsRequest) Flags() *pflag.FlagSet {
+	return req.flags
+}
+
+// Check validates the options.
+func (req *statsRequest) Check() error {
+	if err := checkStats(&req.opts); err != nil {
+		return err
+	}
+	var err error
+	req.printer, err = conv.NewPrinter(req.opts.Locale, req.opts.Units)
+	return err
+}
+
+// Render builds the report.
+func (req *statsRequest) Render(ctx context.Context, input []string, cache *parse.Cache) error {
+	files, err := scan.Scan(ctx, input)
+	if err != nil {
+		return err
+	}
+	activities, st, err := cache.Parse(ctx, files, &req.opts.Selector, nil)
+	if err != nil {
+		return err
+	}
+	req.report = stats.NewReport(activities, st, req.opts.Bins, req.printer.Units, time.Now())
+	return nil
+}
+
+// ContentType returns the media type of the report format.
+func (req *statsRequest) ContentType() string {
+	return statsContentTypes[req.opts.Format]
+}
+
+// Save streams the report to w.
+func (req *statsRequest) Save(_ context.Context, w io.Writer) error {
+	return stats.WriteReport(w, req.report, req.opts.Format, req.printer)
+}
@@ -0,0 +1,314 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/spf13/pflag"
+)
+
+// Request is a single render of an endpoint, created fresh for every HTTP request.
+type Request interface {
+	// Flags returns the options of the request, which are set from the query parameters and JSON body.
+	Flags() *pflag.FlagSet
+	// Check validates the options once they have been set.
+	Check() error
+	// Render renders the activities found in the input paths, using cache to avoid parsing files again.
+	Render(ctx context.Context, input []string, cache *parse.Cache) error
+	// ContentType returns the media type of the rendered response.
+	ContentType() string
+	// Save streams the rendered response to w.
+	Save(ctx context.Context, w io.Writer) error
+}
+
+// Warner is implemented by requests that collect warnings while rendering, which are sent to the client as Warning headers.
+type Warner interface {
+	// Warnings returns the warnings of the render.
+	Warnings() []string
+}
+
+// Endpoint is a command served over HTTP.
+type Endpoint struct {
+	Name string         // The URL path of the endpoint below the root, eg worms
+	New  func() Request // Creates a request with default options
+}
+
+// Options are the options of the HTTP render service.
+type Options struct {
+	Input       []string          // The paths of the activity files to render
+	Addr        string            // The TCP address to listen on, eg :8080
+	Concurrency int               // The maximum number of requests rendered at once, further requests are rejected
+	MaxBody     int64             // The maximum size of a JSON request body in bytes
+	Timeout     time.Duration     // The maximum duration of each render, or zero for no limit
+	Endpoints   []Endpoint        // The commands to serve
+	Progress    progress.Reporter // Receives the progress of warming the cache, or nil to ignore progress
+}
+
+// Server is an http.Handler that renders the endpoints against a warm parse cache.
+type Server struct {
+	o     Options        // The options of the service
+	cache *parse.Cache   // The activities of every file parsed so far
+	sem   chan struct{}  // Holds a value for every request being rendered
+	mux   *http.ServeMux // Routes requests to the endpoints
+}
+
+// New creates a Server and warms its cache by parsing every input file.
+// Files added to the inputs later are parsed when first requested, but files are assumed not to change once parsed.
+func New(ctx context.Context, opts *Options) (*Server, error) {
+	s := &Server{o: *opts, cache: parse.NewCache(), mux: http.NewServeMux()}
+
+	// If no input was provided, the current directory is the input
+	if len(s.o.Input) == 0 {
+		s.o.Input = []string{"."}
+	}
+	if s.o.Concurrency < 1 {
+		s.o.Concurrency = 1
+	}
+	s.sem = make(chan struct{}, s.o.Concurrency)
+
+	// Warm the cache
+	if files, err := scan.Scan(ctx, s.o.Input); err != nil {
+		return nil, err
+	} else if err := s.cache.Load(ctx, files, s.o.Progress); err != nil {
+		return nil, err
+	}
+
+	// Route each endpoint by name
+	for _, e := range s.o.Endpoints {
+		s.mux.Handle("/"+e.Name, s.handler(e))
+	}
+	return s, nil
+}
+
+// Files returns the number of parsed files in the cache.
+func (s *Server) Files() int {
+	return s.cache.Len()
+}
+
+// ServeHTTP routes the request to its endpoint.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handler returns the http.Handler that renders the endpoint e.
+func (s *Server) handler(e Endpoint) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Options are passed as query parameters or a JSON body
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Parse and validate the options
+		req := e.New()
+		if err := setFlags(r, req.Flags(), s.o.MaxBody); err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := req.Check(); err != nil {
+			writeError(w, &statusError{http.StatusBadRequest, err})
+			return
+		}
+
+		// Reject the request if too many are already being rendered
+		select {
+		case s.sem <- struct{}{}:
+			defer func() { <-s.sem }()
+		default:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many concurrent requests", http.StatusServiceUnavailable)
+			return
+		}
+
+		// Stop rendering when the client goes away or the render takes too long
+		ctx := r.Context()
+		if s.o.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.o.Timeout)
+			defer cancel()
+		}
+
+		// Render fully before responding, so errors can still be reported with a status code
+		if err := req.Render(ctx, s.o.Input, s.cache); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Report any warnings that didn't stop the render
+		if wr, ok := req.(Warner); ok {
+			for _, msg := range wr.Warnings() {
+				w.Header().Add("Warning", fmt.Sprintf("199 - %q", msg))
+			}
+		}
+
+		// Stream the response, which can only be abandoned once started
+		w.Header().Set("Content-Type", req.ContentType())
+		if err := req.Save(ctx, w); err != nil && ctx.Err() == nil {
+			fmt.Fprintln(os.Stderr, "WARN:", r.URL.Path, err)
+		}
+	})
+}
+
+// statusError is an error with the HTTP status code to respond with.
+type statusError struct {
+	status int
+	err    error
+}
+
+// Error returns the message of the underlying error.
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// writeError responds with the message of err and a status code derived from it.
+func writeError(w http.ResponseWriter, err error) {
+	var serr *statusError
+	status := http.StatusInternalServerError
+	if errors.As(err, &serr) {
+		status = serr.status
+	} else if errors.Is(err, parse.ErrNoActivities) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, img.ErrTooLarge) {
+		status = http.StatusBadRequest
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusServiceUnavailable
+	} else if errors.Is(err, context.Canceled) {
+		// The client went away, so nobody is listening
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// setFlags sets flags from the query parameters of r, then from the JSON object in the body of a POST request.
+// Each key is the name of a flag, and lists set a flag multiple times.
+func setFlags(r *http.Request, flags *pflag.FlagSet, maxBody int64) error {
+	set := func(key string, vals []string) error {
+		if flags.Lookup(key) == nil {
+			return &statusError{http.StatusBadRequest, fmt.Errorf("option %q not recognized", key)}
+		}
+		for _, val := range vals {
+			if err := flags.Set(key, val); err != nil {
+				return &statusError{http.StatusBadRequest, fmt.Errorf("invalid value %q for option %s: %w", val, key, err)}
+			}
+		}
+		return nil
+	}
+
+	// Apply the query parameters in a stable order
+	query := r.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := set(key, query[key]); err != nil {
+			return err
+		}
+	}
+
+	if r.Method != http.MethodPost {
+		return nil
+	}
+
+	// Read the body, rejecting it if it is too large
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	if err != nil {
+		return err
+	} else if int64(len(body)) > maxBody {
+		return &statusError{http.StatusRequestEntityTooLarge, fmt.Errorf("request body larger than %d bytes", maxBody)}
+	} else if len(body) == 0 {
+		return nil
+	}
+
+	// Decode the body, keeping numbers as they were written
+	var obj map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return &statusError{http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err)}
+	}
+
+	// Apply the body in a stable order
+	keys = keys[:0]
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if vals, err := jsonStrings(obj[key]); err != nil {
+			return &statusError{http.StatusBadRequest, fmt.Errorf("option %q: %w", key, err)}
+		} else if err := set(key, vals); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonStrings converts a decoded JSON value into the strings to pass to pflag.Value.Set.
+func jsonStrings(val any) ([]string, error) {
+	switch v := val.(type) {
+	case []any:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := jsonStrings(item)
+			if err != nil {
+				return nil, err
+			}
+			strs = append(strs, s...)
+		}
+		return strs, nil
+	case map[string]any:
+		return nil, errors.New("unexpected object value")
+	case nil:
+		return nil, errors.New("unexpected null value")
+	default:
+		return []string{fmt.Sprint(v)}, nil
+	}
+}
+
+// Run serves the endpoints over HTTP until ctx is done, then waits for the requests in flight to finish.
+func Run(ctx context.Context, opts *Options) error {
+	o := *opts
+	if o.Progress == nil && progress.IsTerminal(os.Stderr) {
+		o.Progress = progress.NewBar(os.Stderr)
+	}
+
+	s, err := New(ctx, &o)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Addr: o.Addr, Handler: s}
+	errs := make(chan error, 1)
+	go func() { errs <- srv.ListenAndServe() }()
+	fmt.Fprintf(os.Stderr, "serving %d files on %s\n", s.Files(), o.Addr)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		// Stop accepting requests and give the renders in flight a chance to finish
+		sctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return srv.Shutdown(sctx)
+	}
+}
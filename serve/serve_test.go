@@ -0,0 +1,146 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/spf13/pflag"
+)
+
+// echoRequest is a Request that responds with its options.
+type echoRequest struct {
+	flags *pflag.FlagSet
+	width uint
+	sport []string
+	err   error
+	start chan struct{}
+	block chan struct{}
+}
+
+func (req *echoRequest) Flags() *pflag.FlagSet { return req.flags }
+
+func (req *echoRequest) Check() error {
+	if req.width > 100 {
+		return errors.New("too wide")
+	}
+	return nil
+}
+
+func (req *echoRequest) Render(ctx context.Context, _ []string, _ *parse.Cache) error {
+	if req.block != nil {
+		req.start <- struct{}{}
+		<-req.block
+	}
+	return req.err
+}
+
+func (req *echoRequest) ContentType() string { return "text/plain" }
+
+func (req *echoRequest) Save(_ context.Context, w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%d %s", req.width, strings.Join(req.sport, ","))
+	return err
+}
+
+// newTestServer creates a Server with an "echo" endpoint whose requests fail with err.
+// If block is not nil, renders signal start and then wait for block.
+func newTestServer(t *testing.T, err error, start, block chan struct{}) *Server {
+	s, serr := New(context.Background(), &Options{
+		Input:       []string{t.TempDir()},
+		Concurrency: 1,
+		MaxBody:     32,
+		Endpoints: []Endpoint{{Name: "echo", New: func() Request {
+			req := &echoRequest{flags: &pflag.FlagSet{}, err: err, start: start, block: block}
+			req.flags.UintVar(&req.width, "width", 10, "")
+			req.flags.StringSliceVar(&req.sport, "sport", nil, "")
+			return req
+		}}},
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	return s
+}
+
+func TestServer(t *testing.T) {
+	testCases := []struct {
+		method string
+		target string
+		body   string
+		err    error
+		status int
+		expect string
+	}{
+		{"GET", "/echo", "", nil, http.StatusOK, "10 "},
+		{"GET", "/echo?width=20&sport=running&sport=cycling", "", nil, http.StatusOK, "20 running,cycling"},
+		{"POST", "/echo?width=20", `{"width":30,"sport":["hiking"]}`, nil, http.StatusOK, "30 hiking"},
+		{"POST", "/echo", "", nil, http.StatusOK, "10 "},
+		{"PUT", "/echo", "", nil, http.StatusMethodNotAllowed, "method not allowed\n"},
+		{"GET", "/other", "", nil, http.StatusNotFound, "404 page not found\n"},
+		{"GET", "/echo?height=1", "", nil, http.StatusBadRequest, "option \"height\" not recognized\n"},
+		{"GET", "/echo?width=abc", "", nil, http.StatusBadRequest, "invalid value \"abc\" for option width: invalid argument \"abc\" for \"--width\" flag: strconv.ParseUint: parsing \"abc\": invalid syntax\n"},
+		{"GET", "/echo?width=200", "", nil, http.StatusBadRequest, "too wide\n"},
+		{"POST", "/echo", `{"width":{}}`, nil, http.StatusBadRequest, "option \"width\": unexpected object value\n"},
+		{"POST", "/echo", `[1]`, nil, http.StatusBadRequest, "invalid JSON body: json: cannot unmarshal array into Go value of type map[string]interface {}\n"},
+		{"POST", "/echo", `{"sport":["` + strings.Repeat("a", 32) + `"]}`, nil, http.StatusRequestEntityTooLarge, "request body larger than 32 bytes\n"},
+		{"GET", "/echo", "", parse.ErrNoActivities, http.StatusNotFound, "no matching activities found\n"},
+		{"GET", "/echo", "", context.DeadlineExceeded, http.StatusServiceUnavailable, "context deadline exceeded\n"},
+		{"GET", "/echo", "", errors.New("failed"), http.StatusInternalServerError, "failed\n"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			s := newTestServer(t, testCase.err, nil, nil)
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(testCase.method, testCase.target, strings.NewReader(testCase.body)))
+			if w.Code != testCase.status {
+				t.Fatal(w.Code, "!=", testCase.status)
+			}
+			if actual := w.Body.String(); actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+func TestServerConcurrency(t *testing.T) {
+	start, block := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(newTestServer(t, nil, start, block))
+	defer srv.Close()
+
+	// Start a request that holds the only render slot until unblocked
+	done := make(chan int)
+	go func() {
+		if res, err := http.Get(srv.URL + "/echo"); err != nil {
+			done <- 0
+		} else {
+			_ = res.Body.Close()
+			done <- res.StatusCode
+		}
+	}()
+	<-start
+
+	// Further requests are rejected while the slot is taken
+	if res, err := http.Get(srv.URL + "/echo"); err != nil {
+		t.Fatal(err)
+	} else {
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusServiceUnavailable {
+			t.Fatal(res.StatusCode, "!=", http.StatusServiceUnavailable)
+		}
+		if res.Header.Get("Retry-After") == "" {
+			t.Fatal("missing Retry-After")
+		}
+	}
+
+	close(block)
+	if status := <-done; status != http.StatusOK {
+		t.Fatal(status, "!=", http.StatusOK)
+	}
+}
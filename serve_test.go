@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/serve"
+)
+
+func TestServeEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.gpx"), []byte(`
+		<gpx>
+		  <trk>
+		    <type>running</type>
+		    <trkseg>
+		      <trkpt lat="-37.80" lon="144.90"><time>2022-02-13T00:00:00Z</time></trkpt>
+		      <trkpt lat="-37.81" lon="144.91"><time>2022-02-13T00:01:00Z</time></trkpt>
+		      <trkpt lat="-37.82" lon="144.90"><time>2022-02-13T00:02:00Z</time></trkpt>
+		    </trkseg>
+		  </trk>
+		</gpx>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := serve.New(context.Background(), &serve.Options{
+		Input:       []string{dir},
+		Concurrency: 2,
+		MaxBody:     1 << 10,
+		Endpoints:   serveEndpoints(&renderLimits{Width: 200, Frames: 10, Memory: 1, FontSize: 50, Radius: 5000}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		method      string
+		target      string
+		body        string
+		status      int
+		contentType string
+	}{
+		{"GET", "/worms?width=50&frames=3", "", http.StatusOK, "image/gif"},
+		{"POST", "/worms", `{"width":50,"frames":3,"format":"png","sport":["running"]}`, http.StatusOK, "image/apng"},
+		{"GET", "/worms?width=500", "", http.StatusBadRequest, "text/plain; charset=utf-8"},
+		{"GET", "/worms?frames=20", "", http.StatusBadRequest, "text/plain; charset=utf-8"},
+		{"GET", "/worms?output=x.gif", "", http.StatusBadRequest, "text/plain; charset=utf-8"},
+		{"GET", "/worms?width=50&frames=3&color_depth=9", "", http.StatusBadRequest, "text/plain; charset=utf-8"},
+		{"GET", "/worms?width=200&frames=10&truecolor=true", "", http.StatusBadRequest, "text/plain; charset=utf-8"},
+		{"GET", "/worms?width=50&frames=3&font_size=100", "", http.StatusBadRequest, "text/plain; charset=utf-8"},
+		{"GET", "/worms?width=50&frames=3&sport=cycling", "", http.StatusNotFound, "text/plain; charset=utf-8"},
+		{"GET", "/paint?width=50&minimal=true&region=-37.81,144.905,2km", "", http.StatusOK, "image/png"},
+		{"GET", "/paint?width=50&minimal=true&region=-37.81,144.905,20km", "", http.StatusBadRequest, "text/plain; charset=utf-8"},
+		{"GET", "/paint?width=50&minimal=true&region_box=-37.81,144.905,2kmx20km", "", http.StatusBadRequest, "text/plain; charset=utf-8"},
+		{"GET", "/paint?width=50&minimal=true", "", http.StatusBadRequest, "text/plain; charset=utf-8"},
+		{"GET", "/stats?stats_format=json", "", http.StatusOK, "application/json"},
+		{"GET", "/stats?stats_file=x.txt", "", http.StatusBadRequest, "text/plain; charset=utf-8"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(testCase.method, testCase.target, strings.NewReader(testCase.body)))
+			if w.Code != testCase.status {
+				t.Fatal(w.Code, "!=", testCase.status, w.Body.String())
+			}
+			if actual := w.Header().Get("Content-Type"); actual != testCase.contentType {
+				t.Fatal(actual, "!=", testCase.contentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/NathanBaulch/rainbow-roads/stats"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// statsOpts are the options to report statistics
+	statsOpts = &stats.Options{}
+	// statsCmd represents the "stats" command
+	statsCmd = &cobra.Command{
+		Use:   "stats",
+		Short: "Report statistics and breakdowns of exercise activities",
+		// Pre-checks to ensure value are in bounds
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return checkStats(statsOpts)
+		},
+		// Run the command
+		RunE: func(cmd *cobra.Command, args []string) error {
+			statsOpts.Input = args
+			return cancelled(cmd, stats.Run(cmd.Context(), statsOpts))
+		},
+	}
+)
+
+func init() {
+	// Add the "stats" command to the root command
+	rootCmd.AddCommand(statsCmd)
+
+	// General flags (output location and format)
+	general := statsCmdFlagSet(statsOpts)
+	general.AddFlagSet(configFlagSet())
+	general.VisitAll(func(f *pflag.Flag) { statsCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+
+	// Filtering flags
+	filters := filterFlagSet(&statsOpts.Selector)
+	filters.VisitAll(func(f *pflag.Flag) { statsCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+
+	// Prints the help command
+	statsCmd.SetUsageFunc(func(*cobra.Command) error {
+		fmt.Fprintln(statsCmd.OutOrStderr())
+		fmt.Fprintln(statsCmd.OutOrStderr(), "Usage:")
+		fmt.Fprintln(statsCmd.OutOrStderr(), " ", statsCmd.UseLine(), "[input]")
+		fmt.Fprintln(statsCmd.OutOrStderr())
+		fmt.Fprintln(statsCmd.OutOrStderr(), "General flags:")
+		fmt.Fprintln(statsCmd.OutOrStderr(), general.FlagUsages())
+		fmt.Fprintln(statsCmd.OutOrStderr(), "Filtering flags:")
+		fmt.Fprint(statsCmd.OutOrStderr(), filters.FlagUsages())
+		return nil
+	})
+}
+
+// statsCmdFlagSet creates the general flags of the "stats" command bound to opts, excluding the config flags.
+func statsCmdFlagSet(opts *stats.Options) *pflag.FlagSet {
+	general := statsFlagSet(&opts.Format, &opts.Output)
+	general.IntVar(&opts.Bins, "bins", 10, "maximum number of bins in each histogram")
+	general.AddFlagSet(localeFlagSet(&opts.Locale, &opts.Units))
+	return general
+}
+
+// checkStats checks that the options of the "stats" command are in bounds.
+func checkStats(opts *stats.Options) error {
+	if opts.Bins < 1 {
+		return flagError("bins", opts.Bins, "must be positive")
+	}
+	if err := checkLocale(opts.Locale, opts.Units); err != nil {
+		return err
+	}
+	return checkStatsFormat(opts.Format)
+}
@@ -0,0 +1,301 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+// Totals are the combined quantities of a group of activities.
+// Distances are in meters, durations in seconds and paces in seconds per kilometer.
+type Totals struct {
+	Activities int     `json:"activities" yaml:"activities"`
+	Distance   float64 `json:"distance_m" yaml:"distance_m"`
+	Duration   float64 `json:"duration_s" yaml:"duration_s"`
+	Pace       float64 `json:"pace_s_per_km" yaml:"pace_s_per_km"`
+	Ascent     float64 `json:"ascent_m" yaml:"ascent_m"`
+}
+
+// add adds the quantities of activity act to the totals.
+func (t *Totals) add(act *parse.Activity) {
+	t.Activities++
+	t.Distance += act.Distance
+	t.Duration += act.Duration().Seconds()
+	t.Pace = t.Duration / t.Distance * 1000
+	t.Ascent += act.Ascent()
+}
+
+// Group is the Totals of all activities sharing the same Key, such as a sport or period.
+type Group struct {
+	Key    string `json:"key" yaml:"key"`
+	Totals `yaml:",inline"`
+}
+
+// Bin is a histogram bin counting the activities with a value from Min up to but excluding Max.
+type Bin struct {
+	Min   float64 `json:"min" yaml:"min"`
+	Max   float64 `json:"max" yaml:"max"`
+	Count int     `json:"count" yaml:"count"`
+}
+
+// Histograms are the distributions of the distance, duration and pace of activities.
+type Histograms struct {
+	Distance []*Bin `json:"distance_m" yaml:"distance_m"`
+	Duration []*Bin `json:"duration_s" yaml:"duration_s"`
+	Pace     []*Bin `json:"pace_s_per_km" yaml:"pace_s_per_km"`
+}
+
+// Day is the number of activities started on a local calendar date.
+type Day struct {
+	Date       string `json:"date" yaml:"date"`
+	Activities int    `json:"activities" yaml:"activities"`
+}
+
+// Breakdown is the activity Totals grouped by sport and period, with histograms and calendar day counts.
+// Periods are based on the local start time of each activity, with weeks following ISO 8601.
+type Breakdown struct {
+	Sports     []*Group   `json:"sports" yaml:"sports"`
+	Years      []*Group   `json:"years" yaml:"years"`
+	Months     []*Group   `json:"months" yaml:"months"`
+	Weeks      []*Group   `json:"weeks" yaml:"weeks"`
+	Histograms Histograms `json:"histograms" yaml:"histograms"`
+	Days       []*Day     `json:"days" yaml:"days"`
+}
+
+// NewBreakdown summarizes activities into a Breakdown, with up to bins bins per histogram.
+// Histogram bins have round widths in the given units, although their edges are always in stable units.
+func NewBreakdown(activities []*parse.Activity, bins int, units conv.Units) *Breakdown {
+	length, _ := units.Length()
+	sports := make(map[string]*Group)
+	years := make(map[string]*Group)
+	months := make(map[string]*Group)
+	weeks := make(map[string]*Group)
+	days := make(map[string]*Day)
+	dists := make([]float64, len(activities))
+	durs := make([]float64, len(activities))
+	paces := make([]float64, len(activities))
+
+	// Accumulate every activity into its groups
+	for i, act := range activities {
+		sport := strings.ToLower(act.Sport)
+		if sport == "" {
+			sport = "unknown"
+		}
+		ts := act.Local(act.Start())
+		year, week := ts.ISOWeek()
+		addGroup(sports, sport, act)
+		addGroup(years, ts.Format("2006"), act)
+		addGroup(months, ts.Format("2006-01"), act)
+		addGroup(weeks, fmt.Sprintf("%04d-W%02d", year, week), act)
+
+		date := ts.Format("2006-01-02")
+		if d, ok := days[date]; ok {
+			d.Activities++
+		} else {
+			days[date] = &Day{Date: date, Activities: 1}
+		}
+
+		dists[i] = act.Distance
+		durs[i] = act.Duration().Seconds()
+		paces[i] = act.Pace().Seconds() * 1000
+	}
+
+	b := &Breakdown{
+		Sports: sortedGroups(sports),
+		Years:  sortedGroups(years),
+		Months: sortedGroups(months),
+		Weeks:  sortedGroups(weeks),
+		Histograms: Histograms{
+			Distance: histogram(dists, bins, length/10),
+			Duration: histogram(durs, bins, 60),
+			Pace:     histogram(paces, bins, 1000/length),
+		},
+		Days: make([]*Day, 0, len(days)),
+	}
+
+	// Sports are ordered by descending activity count
+	sort.SliceStable(b.Sports, func(i, j int) bool { return b.Sports[i].Activities > b.Sports[j].Activities })
+
+	for _, d := range days {
+		b.Days = append(b.Days, d)
+	}
+	sort.Slice(b.Days, func(i, j int) bool { return b.Days[i].Date < b.Days[j].Date })
+
+	return b
+}
+
+// addGroup adds activity act to the Group with the given key, creating it if necessary.
+func addGroup(groups map[string]*Group, key string, act *parse.Activity) {
+	g, ok := groups[key]
+	if !ok {
+		g = &Group{Key: key}
+		groups[key] = g
+	}
+	g.add(act)
+}
+
+// sortedGroups returns the groups ordered by key.
+func sortedGroups(groups map[string]*Group) []*Group {
+	s := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		s = append(s, g)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Key < s[j].Key })
+	return s
+}
+
+// histogram counts values into evenly sized bins with a round width, using at most n bins.
+// Widths are rounded after dividing values by scale, such as the length of a unit, and are at least one.
+func histogram(values []float64, n int, scale float64) []*Bin {
+	if len(values) == 0 || n <= 0 {
+		return nil
+	}
+
+	lo, hi := values[0]/scale, values[0]/scale
+	for _, v := range values[1:] {
+		lo = math.Min(lo, v/scale)
+		hi = math.Max(hi, v/scale)
+	}
+
+	// Find the smallest round width that covers the range with at most n bins
+	width := math.Max(1, niceStep((hi-lo)/float64(n)))
+	start := math.Floor(lo/width) * width
+	count := int(math.Floor((hi-start)/width)) + 1
+	for count > n {
+		width = niceStep(width * 1.01)
+		start = math.Floor(lo/width) * width
+		count = int(math.Floor((hi-start)/width)) + 1
+	}
+
+	bins := make([]*Bin, count)
+	for i := range bins {
+		bins[i] = &Bin{Min: (start + float64(i)*width) * scale, Max: (start + float64(i+1)*width) * scale}
+	}
+	for _, v := range values {
+		bins[int(math.Floor((v/scale-start)/width))].Count++
+	}
+	return bins
+}
+
+// niceStep returns the smallest 1, 2 or 5 times a power of ten that is at least step.
+func niceStep(step float64) float64 {
+	if step <= 0 {
+		return 1
+	}
+	mag := math.Pow(10, math.Floor(math.Log10(step)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*mag >= step {
+			return m * mag
+		}
+	}
+	return 10 * mag
+}
+
+// Fprint prints the breakdown to w as text tables using a given printer.
+func (b *Breakdown) Fprint(w io.Writer, p *conv.Printer) {
+	fprintGroups(w, p, "SPORT", b.Sports)
+	fprintGroups(w, p, "YEAR", b.Years)
+	fprintGroups(w, p, "MONTH", b.Months)
+	fprintGroups(w, p, "WEEK", b.Weeks)
+
+	fprintHistogram(w, p, "DISTANCE", b.Histograms.Distance, func(v float64) string { return conv.SprintDistance(p, v) })
+	fprintHistogram(w, p, "DURATION", b.Histograms.Duration, func(v float64) string { return conv.SprintDuration(p, seconds(v)) })
+	fprintHistogram(w, p, "PACE", b.Histograms.Pace, func(v float64) string { return conv.SprintPace(p, seconds(v)/1000) })
+
+	fprintCalendar(w, b.Days)
+}
+
+// seconds converts a number of seconds into a time.Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// fprintGroups prints the groups to w as a table, using title as the header of the key column.
+func fprintGroups(w io.Writer, p *conv.Printer, title string, groups []*Group) {
+	_, _ = fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprint(tw, conv.SprintHeader(p, title, "ACTIVITIES", "DISTANCE", "DURATION", "PACE", "ASCENT", ""))
+	for _, g := range groups {
+		_, _ = p.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t\n",
+			g.Key,
+			g.Activities,
+			conv.SprintDistance(p, g.Distance),
+			conv.SprintDuration(p, seconds(g.Duration)),
+			conv.SprintPace(p, seconds(g.Pace)/1000),
+			conv.SprintElevation(p, g.Ascent),
+		)
+	}
+	_ = tw.Flush()
+}
+
+// fprintHistogram prints the bins to w as a horizontal bar chart, formatting bin edges with label.
+func fprintHistogram(w io.Writer, p *conv.Printer, title string, bins []*Bin, label func(float64) string) {
+	const barWidth = 40
+
+	most := 0
+	for _, b := range bins {
+		if b.Count > most {
+			most = b.Count
+		}
+	}
+
+	_, _ = fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprint(tw, conv.SprintHeader(p, title, "ACTIVITIES"))
+	for _, b := range bins {
+		bar := strings.Repeat("#", int(math.Ceil(float64(b.Count*barWidth)/float64(most))))
+		_, _ = p.Fprintf(tw, "%s\t%d\t%s\n", p.Sprintf("%s to %s", label(b.Min), label(b.Max)), b.Count, bar)
+	}
+	_ = tw.Flush()
+}
+
+// fprintCalendar prints the days to w as a calendar per year, with a column per ISO week and a row per weekday.
+// Each day shows its activity count, or "+" for more than nine and "." for none.
+func fprintCalendar(w io.Writer, days []*Day) {
+	if len(days) == 0 {
+		return
+	}
+
+	counts := make(map[string]int, len(days))
+	for _, d := range days {
+		counts[d.Date] = d.Activities
+	}
+
+	first, _ := time.Parse("2006-01-02", days[0].Date)
+	last, _ := time.Parse("2006-01-02", days[len(days)-1].Date)
+	weekdays := []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+	for year := first.Year(); year <= last.Year(); year++ {
+		jan1 := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		// Start on the Monday on or before the first of January
+		start := jan1.AddDate(0, 0, -(int(jan1.Weekday())+6)%7)
+
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintln(w, year)
+		for wd, name := range weekdays {
+			sb := strings.Builder{}
+			sb.WriteString(name)
+			sb.WriteByte(' ')
+			for d := start.AddDate(0, 0, wd); d.Year() <= year; d = d.AddDate(0, 0, 7) {
+				switch n := counts[d.Format("2006-01-02")]; {
+				case d.Year() < year:
+					sb.WriteByte(' ')
+				case n == 0:
+					sb.WriteByte('.')
+				case n > 9:
+					sb.WriteByte('+')
+				default:
+					sb.WriteByte(byte('0' + n))
+				}
+			}
+			_, _ = fmt.Fprintln(w, sb.String())
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+func testActivities() []*parse.Activity {
+	newAct := func(sport string, start time.Time, dist float64, eles ...float64) *parse.Activity {
+		act := &parse.Activity{Sport: sport, Distance: dist, Location: time.UTC}
+		for i, ele := range eles {
+			act.Records = append(act.Records, &parse.Record{
+				Timestamp: start.Add(time.Duration(i) * 10 * time.Minute),
+				Position:  geo.NewPointFromDegrees(1, 2),
+				Elevation: ele,
+			})
+		}
+		return act
+	}
+	return []*parse.Activity{
+		newAct("Running", time.Date(2021, 1, 3, 9, 0, 0, 0, time.UTC), 5000, 10, 20, 15, 30),
+		newAct("Running", time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC), 10000, 10, math.NaN(), 40),
+		newAct("Cycling", time.Date(2021, 1, 4, 18, 0, 0, 0, time.UTC), 30000, 0, 0),
+		newAct("", time.Date(2022, 2, 1, 7, 0, 0, 0, time.UTC), 2000, 0, 0),
+	}
+}
+
+func TestNewBreakdown(t *testing.T) {
+	b := NewBreakdown(testActivities(), 10, conv.Metric)
+
+	testCases := []struct {
+		groups []*Group
+		expect string
+	}{
+		{b.Sports, "running:2:15000:3000:55, cycling:1:30000:600:0, unknown:1:2000:600:0"},
+		{b.Years, "2021:3:45000:3600:55, 2022:1:2000:600:0"},
+		{b.Months, "2021-01:3:45000:3600:55, 2022-02:1:2000:600:0"},
+		{b.Weeks, "2020-W53:1:5000:1800:25, 2021-W01:2:40000:1800:30, 2022-W05:1:2000:600:0"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			actual := ""
+			for j, g := range tc.groups {
+				if j > 0 {
+					actual += ", "
+				}
+				actual += fmt.Sprintf("%s:%d:%g:%g:%g", g.Key, g.Activities, g.Distance, g.Duration, g.Ascent)
+			}
+			if actual != tc.expect {
+				t.Fatal(actual, "!=", tc.expect)
+			}
+		})
+	}
+
+	if len(b.Days) != 3 || b.Days[1].Date != "2021-01-04" || b.Days[1].Activities != 2 {
+		t.Fatal("unexpected days", b.Days)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	testCases := []struct {
+		values []float64
+		n      int
+		expect string
+	}{
+		{[]float64{1, 2, 3, 4}, 4, "[1,2):1 [2,3):1 [3,4):1 [4,5):1"},
+		{[]float64{1, 2, 3, 4}, 2, "[0,5):4"},
+		{[]float64{1200, 4800, 9900}, 5, "[0,2000):1 [2000,4000):0 [4000,6000):1 [6000,8000):0 [8000,10000):1"},
+		{[]float64{7, 7}, 3, "[7,8):2"},
+		{[]float64{7, 7.5}, 3, "[7,8):2"},
+		{[]float64{1000, 3000, 5000}, 2, "[0,5000):2 [5000,10000):1"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			actual := ""
+			for j, b := range histogram(tc.values, tc.n, 1) {
+				if j > 0 {
+					actual += " "
+				}
+				actual += fmt.Sprintf("[%g,%g):%d", b.Min, b.Max, b.Count)
+			}
+			if actual != tc.expect {
+				t.Fatal(actual, "!=", tc.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+// EddingtonUnits are the named units in meters that Eddington numbers are computed in.
+var EddingtonUnits = []struct {
+	Name   string
+	Length float64
+}{
+	{"km", 1000},
+	{"mi", 1609.344},
+}
+
+// Eddington is the largest number E such that E days have a total distance of at least E units.
+type Eddington struct {
+	Unit   string             `json:"unit" yaml:"unit"`
+	Number int                `json:"number" yaml:"number"`
+	Next   []*EddingtonTarget `json:"next" yaml:"next"`
+}
+
+// EddingtonTarget is the number of additional days of at least Number units needed to reach that Eddington number.
+type EddingtonTarget struct {
+	Number int `json:"number" yaml:"number"`
+	Days   int `json:"days_needed" yaml:"days_needed"`
+}
+
+// Streak is a run of consecutive local calendar days or ISO weeks with at least one activity.
+type Streak struct {
+	Length int    `json:"length" yaml:"length"` // The number of days or weeks in the run
+	Days   int    `json:"days" yaml:"days"`     // The number of calendar days from the start of From to the end of To
+	From   string `json:"from,omitempty" yaml:"from,omitempty"`
+	To     string `json:"to,omitempty" yaml:"to,omitempty"`
+}
+
+// Streaks are the current and longest runs of active days and weeks.
+// A current streak is still alive if it includes the current or previous period.
+type Streaks struct {
+	CurrentDaily  Streak `json:"current_daily" yaml:"current_daily"`
+	LongestDaily  Streak `json:"longest_daily" yaml:"longest_daily"`
+	CurrentWeekly Streak `json:"current_weekly" yaml:"current_weekly"`
+	LongestWeekly Streak `json:"longest_weekly" yaml:"longest_weekly"`
+}
+
+// Goals are the Eddington numbers and streaks of a set of activities.
+type Goals struct {
+	Eddington []*Eddington `json:"eddington" yaml:"eddington"`
+	Streaks   Streaks      `json:"streaks" yaml:"streaks"`
+}
+
+// NewGoals computes the Goals of activities, with current streaks relative to the local date of now.
+// Each activity counts towards the local day on which it started, with up to next upcoming Eddington targets.
+func NewGoals(activities []*parse.Activity, now time.Time, next int) *Goals {
+	totals := make(map[time.Time]float64)
+	for _, act := range activities {
+		totals[localDate(act.Local(act.Start()))] += act.Distance
+	}
+	dists := make([]float64, 0, len(totals))
+	days := make([]time.Time, 0, len(totals))
+	for day, dist := range totals {
+		dists = append(dists, dist)
+		days = append(days, day)
+	}
+
+	g := &Goals{Eddington: make([]*Eddington, len(EddingtonUnits))}
+	for i, u := range EddingtonUnits {
+		g.Eddington[i] = eddington(dists, u.Name, u.Length, next)
+	}
+
+	today := localDate(now)
+	var last Streak
+	g.Streaks.LongestDaily, last = findStreaks(days, false)
+	if last.To >= today.AddDate(0, 0, -1).Format("2006-01-02") {
+		g.Streaks.CurrentDaily = last
+	}
+	g.Streaks.LongestWeekly, last = findStreaks(days, true)
+	if last.To >= isoWeek(weekStart(today).AddDate(0, 0, -7)) {
+		g.Streaks.CurrentWeekly = last
+	}
+	return g
+}
+
+// eddington returns the Eddington number of the daily distances dists in the given unit of length meters.
+func eddington(dists []float64, unit string, length float64, next int) *Eddington {
+	sorted := make([]float64, len(dists))
+	copy(sorted, dists)
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+
+	e := &Eddington{Unit: unit}
+	for e.Number < len(sorted) && sorted[e.Number] >= float64(e.Number+1)*length {
+		e.Number++
+	}
+
+	e.Next = make([]*EddingtonTarget, next)
+	for i := range e.Next {
+		n := e.Number + i + 1
+		count := sort.Search(len(sorted), func(j int) bool { return sorted[j] < float64(n)*length })
+		e.Next[i] = &EddingtonTarget{Number: n, Days: n - count}
+	}
+	return e
+}
+
+// localDate returns the calendar date of ts as midnight UTC, so consecutive dates are exactly 24 hours apart.
+func localDate(ts time.Time) time.Time {
+	return time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// weekStart returns the Monday of the ISO week containing date.
+func weekStart(date time.Time) time.Time {
+	return date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+}
+
+// isoWeek formats the ISO week containing date, eg "2023-W05".
+func isoWeek(date time.Time) string {
+	year, week := date.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+// findStreaks returns the longest and the latest runs of consecutive periods containing any of days.
+// Periods are the days themselves, or their ISO weeks if weekly is true.
+// The days must be dates at midnight UTC, see localDate.
+func findStreaks(days []time.Time, weekly bool) (longest, latest Streak) {
+	if len(days) == 0 {
+		return
+	}
+
+	step, format := 1, func(t time.Time) string { return t.Format("2006-01-02") }
+	periods := make([]time.Time, len(days))
+	copy(periods, days)
+	if weekly {
+		step, format = 7, isoWeek
+		for i, day := range periods {
+			periods[i] = weekStart(day)
+		}
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Before(periods[j]) })
+
+	from, n := periods[0], 1
+	for i := 1; i <= len(periods); i++ {
+		if i < len(periods) {
+			if periods[i].Equal(periods[i-1]) {
+				continue
+			} else if periods[i].Equal(periods[i-1].AddDate(0, 0, step)) {
+				n++
+				continue
+			}
+		}
+		latest = Streak{Length: n, Days: n * step, From: format(from), To: format(periods[i-1])}
+		if n > longest.Length {
+			longest = latest
+		}
+		if i < len(periods) {
+			from, n = periods[i], 1
+		}
+	}
+	return
+}
+
+// Fprint prints the goals to w as text using a given printer.
+func (g *Goals) Fprint(w io.Writer, p *conv.Printer) {
+	_, _ = fmt.Fprintln(w)
+	for _, e := range g.Eddington {
+		targets := make([]string, len(e.Next))
+		for j, t := range e.Next {
+			targets[j] = p.Sprintf("%d more days for %d", t.Days, t.Number)
+		}
+		value := p.Sprintf("%d%s", e.Number, e.Unit)
+		if len(targets) > 0 {
+			value += " (" + strings.Join(targets, ", ") + ")"
+		}
+		conv.FprintField(w, p, "eddington", value)
+	}
+	conv.FprintField(w, p, "daily streak", p.Sprintf("current %s, longest %s", sprintStreak(p, g.Streaks.CurrentDaily, "days"), sprintStreak(p, g.Streaks.LongestDaily, "days")))
+	conv.FprintField(w, p, "weekly streak", p.Sprintf("current %s, longest %s", sprintStreak(p, g.Streaks.CurrentWeekly, "weeks"), sprintStreak(p, g.Streaks.LongestWeekly, "weeks")))
+}
+
+// sprintStreak formats the length and range of streak s in the given unit.
+func sprintStreak(p *conv.Printer, s Streak, unit string) string {
+	if s.Length == 0 {
+		return p.Sprintf("%d %s", 0, p.Sprintf(unit))
+	}
+	return p.Sprintf("%d %s (%s to %s)", s.Length, p.Sprintf(unit), s.From, s.To)
+}
@@ -0,0 +1,104 @@
+package stats
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+func TestEddington(t *testing.T) {
+	testCases := []struct {
+		dists  []float64
+		expect string
+	}{
+		{nil, "0 1:1 2:2"},
+		{[]float64{500}, "0 1:1 2:2"},
+		{[]float64{1000}, "1 2:2 3:3"},
+		{[]float64{5000, 4000, 3000, 2000, 1000}, "3 4:2 5:4"},
+		{[]float64{5000, 4000, 3500, 3000, 900}, "3 4:2 5:4"},
+		{[]float64{9000, 8000, 7000, 6000, 5000, 4000}, "5 6:2 7:4"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			e := eddington(tc.dists, "km", 1000, 2)
+			actual := fmt.Sprint(e.Number)
+			for _, n := range e.Next {
+				actual += fmt.Sprintf(" %d:%d", n.Number, n.Days)
+			}
+			if actual != tc.expect {
+				t.Fatal(actual, "!=", tc.expect)
+			}
+		})
+	}
+}
+
+func TestFindStreaks(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2022, 2, d, 0, 0, 0, 0, time.UTC) }
+
+	testCases := []struct {
+		days    []time.Time
+		weekly  bool
+		longest Streak
+		latest  Streak
+	}{
+		{nil, false, Streak{}, Streak{}},
+		{[]time.Time{day(3)}, false, Streak{1, 1, "2022-02-03", "2022-02-03"}, Streak{1, 1, "2022-02-03", "2022-02-03"}},
+		{[]time.Time{day(5), day(3), day(4), day(4), day(8)}, false, Streak{3, 3, "2022-02-03", "2022-02-05"}, Streak{1, 1, "2022-02-08", "2022-02-08"}},
+		{[]time.Time{day(1), day(3), day(4), day(5), day(6)}, false, Streak{4, 4, "2022-02-03", "2022-02-06"}, Streak{4, 4, "2022-02-03", "2022-02-06"}},
+		{[]time.Time{day(1), day(6), day(7), day(20), day(28)}, true, Streak{3, 21, "2022-W05", "2022-W07"}, Streak{1, 7, "2022-W09", "2022-W09"}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			longest, latest := findStreaks(tc.days, tc.weekly)
+			if longest != tc.longest {
+				t.Fatal(longest, "!=", tc.longest)
+			}
+			if latest != tc.latest {
+				t.Fatal(latest, "!=", tc.latest)
+			}
+		})
+	}
+}
+
+func TestNewGoalsCurrentStreaks(t *testing.T) {
+	newAct := func(ts time.Time) *parse.Activity {
+		return &parse.Activity{
+			Distance: 1000,
+			Location: time.UTC,
+			Records:  []*parse.Record{{Timestamp: ts, Position: geo.NewPointFromDegrees(1, 2)}},
+		}
+	}
+	acts := []*parse.Activity{
+		newAct(time.Date(2022, 2, 7, 9, 0, 0, 0, time.UTC)),
+		newAct(time.Date(2022, 2, 8, 9, 0, 0, 0, time.UTC)),
+	}
+
+	testCases := []struct {
+		now    time.Time
+		daily  int
+		weekly int
+	}{
+		{time.Date(2022, 2, 8, 12, 0, 0, 0, time.UTC), 2, 1},
+		{time.Date(2022, 2, 9, 12, 0, 0, 0, time.UTC), 2, 1},
+		{time.Date(2022, 2, 10, 12, 0, 0, 0, time.UTC), 0, 1},
+		{time.Date(2022, 2, 20, 12, 0, 0, 0, time.UTC), 0, 1},
+		{time.Date(2022, 2, 21, 12, 0, 0, 0, time.UTC), 0, 0},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			g := NewGoals(acts, tc.now, 1)
+			if g.Streaks.CurrentDaily.Length != tc.daily {
+				t.Fatal(g.Streaks.CurrentDaily.Length, "!=", tc.daily)
+			}
+			if g.Streaks.CurrentWeekly.Length != tc.weekly {
+				t.Fatal(g.Streaks.CurrentWeekly.Length, "!=", tc.weekly)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+// EffortDistances are the named distances in meters searched for best efforts.
+var EffortDistances = []struct {
+	Name     string
+	Distance float64
+}{
+	{"1k", 1000},
+	{"5k", 5000},
+	{"10k", 10000},
+	{"half marathon", 21097.5},
+	{"marathon", 42195},
+}
+
+// Effort is the fastest time an activity covered a given distance, found anywhere within the activity.
+type Effort struct {
+	Sport    string    `json:"sport" yaml:"sport"`
+	Name     string    `json:"name" yaml:"name"`
+	Distance float64   `json:"distance_m" yaml:"distance_m"`
+	Duration float64   `json:"duration_s" yaml:"duration_s"`
+	Pace     float64   `json:"pace_s_per_km" yaml:"pace_s_per_km"`
+	Offset   float64   `json:"offset_m" yaml:"offset_m"`
+	Start    time.Time `json:"start" yaml:"start"`
+	Source   string    `json:"source" yaml:"source"`
+}
+
+// Highlight is the activity with the most extreme value of some quantity.
+type Highlight struct {
+	Value  float64   `json:"value" yaml:"value"`
+	Start  time.Time `json:"start" yaml:"start"`
+	Source string    `json:"source" yaml:"source"`
+}
+
+// PersonalRecords are the best efforts per sport and the most notable activities.
+type PersonalRecords struct {
+	Efforts         []*Effort  `json:"best_efforts" yaml:"best_efforts"`
+	LongestDistance *Highlight `json:"longest_distance_m" yaml:"longest_distance_m"`
+	LongestDuration *Highlight `json:"longest_duration_s" yaml:"longest_duration_s"`
+	BiggestClimb    *Highlight `json:"biggest_climb_m" yaml:"biggest_climb_m"`
+	LongestStreak   Streak     `json:"longest_streak" yaml:"longest_streak"`
+}
+
+// NewPersonalRecords searches activities for their PersonalRecords.
+func NewPersonalRecords(activities []*parse.Activity) *PersonalRecords {
+	pr := &PersonalRecords{}
+	best := make(map[string]*Effort)
+	days := make([]time.Time, 0, len(activities))
+
+	for _, act := range activities {
+		sport := strings.ToLower(act.Sport)
+		if sport == "" {
+			sport = "unknown"
+		}
+		start := act.Local(act.Start())
+
+		// Find the fastest effort of every distance within this activity
+		for _, ed := range EffortDistances {
+			if e := bestEffort(act, ed.Distance); e != nil {
+				key := sport + "/" + ed.Name
+				if b, ok := best[key]; !ok || e.Duration < b.Duration {
+					e.Sport = sport
+					e.Name = ed.Name
+					best[key] = e
+				}
+			}
+		}
+
+		pr.LongestDistance = maxHighlight(pr.LongestDistance, act.Distance, act)
+		pr.LongestDuration = maxHighlight(pr.LongestDuration, act.Duration().Seconds(), act)
+		pr.BiggestClimb = maxHighlight(pr.BiggestClimb, act.Ascent(), act)
+
+		days = append(days, localDate(start))
+	}
+
+	pr.Efforts = make([]*Effort, 0, len(best))
+	for _, e := range best {
+		pr.Efforts = append(pr.Efforts, e)
+	}
+	sort.Slice(pr.Efforts, func(i, j int) bool {
+		e0, e1 := pr.Efforts[i], pr.Efforts[j]
+		return e0.Sport < e1.Sport || (e0.Sport == e1.Sport && e0.Distance < e1.Distance)
+	})
+
+	pr.LongestStreak, _ = findStreaks(days, false)
+	return pr
+}
+
+// maxHighlight returns a Highlight of activity act if value exceeds that of h, otherwise h.
+func maxHighlight(h *Highlight, value float64, act *parse.Activity) *Highlight {
+	if h != nil && h.Value >= value {
+		return h
+	}
+	return &Highlight{Value: value, Start: act.Local(act.Start()), Source: act.Source}
+}
+
+// bestEffort returns the fastest Effort covering distance dist within activity act, or nil if the activity is too short.
+// A sliding window is moved over the records, interpolating the start so each window covers exactly dist.
+func bestEffort(act *parse.Activity, dist float64) *Effort {
+	recs := act.Records
+	if len(recs) < 2 {
+		return nil
+	}
+
+	// Cumulative distance from the first record
+	cum := make([]float64, len(recs))
+	for i := 1; i < len(recs); i++ {
+		cum[i] = cum[i-1] + recs[i-1].Position.DistanceTo(recs[i].Position)
+	}
+	if cum[len(cum)-1] < dist {
+		return nil
+	}
+
+	var best *Effort
+	i := 0
+	for j := 1; j < len(recs); j++ {
+		if cum[j] < dist {
+			continue
+		}
+		// Advance the start to the last record that still leaves at least dist to the end of the window
+		for cum[j]-cum[i+1] >= dist {
+			i++
+		}
+		// Interpolate the time at which the window covers exactly dist
+		offset := cum[j] - dist
+		start := recs[i].Timestamp
+		if seg := cum[i+1] - cum[i]; seg > 0 {
+			frac := (offset - cum[i]) / seg
+			start = start.Add(time.Duration(frac * float64(recs[i+1].Timestamp.Sub(start))))
+		}
+		dur := recs[j].Timestamp.Sub(start).Seconds()
+		if dur > 0 && (best == nil || dur < best.Duration) {
+			best = &Effort{
+				Distance: dist,
+				Duration: dur,
+				Pace:     dur / dist * 1000,
+				Offset:   offset,
+				Start:    act.Local(start),
+				Source:   act.Source,
+			}
+		}
+	}
+	return best
+}
+
+// Fprint prints the personal records to w as text using a given printer.
+func (pr *PersonalRecords) Fprint(w io.Writer, p *conv.Printer) {
+	_, _ = fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprint(tw, conv.SprintHeader(p, "SPORT", "BEST EFFORT", "TIME", "PACE", "DATE", "SOURCE"))
+	for _, e := range pr.Efforts {
+		_, _ = p.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Sport,
+			e.Name,
+			conv.SprintDuration(p, seconds(e.Duration)),
+			conv.SprintPace(p, seconds(e.Pace)/1000),
+			e.Start.Format("2006-01-02 15:04"),
+			e.Source,
+		)
+	}
+	_ = tw.Flush()
+
+	_, _ = fmt.Fprintln(w)
+	if pr.LongestDistance != nil {
+		conv.FprintField(w, p, "longest distance", conv.SprintDistance(p, pr.LongestDistance.Value)+", "+sprintHighlight(pr.LongestDistance))
+		conv.FprintField(w, p, "longest duration", conv.SprintDuration(p, seconds(pr.LongestDuration.Value))+", "+sprintHighlight(pr.LongestDuration))
+		conv.FprintField(w, p, "biggest climb", conv.SprintElevation(p, pr.BiggestClimb.Value)+", "+sprintHighlight(pr.BiggestClimb))
+	}
+	if pr.LongestStreak.Length > 0 {
+		conv.FprintField(w, p, "longest streak", sprintStreak(p, pr.LongestStreak, "days"))
+	}
+}
+
+// sprintHighlight formats the date and source of highlight h.
+func sprintHighlight(h *Highlight) string {
+	return h.Start.Format("2006-01-02 15:04") + " " + h.Source
+}
@@ -0,0 +1,75 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+func TestBestEffort(t *testing.T) {
+	// 30 equal segments along the equator, the first half taking 60s each and the second half 30s each
+	act := &parse.Activity{Location: time.UTC}
+	ts := time.Date(2022, 2, 13, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= 30; i++ {
+		act.Records = append(act.Records, &parse.Record{Timestamp: ts, Position: geo.NewPointFromDegrees(0, float64(i)/1000)})
+		if i < 15 {
+			ts = ts.Add(time.Minute)
+		} else {
+			ts = ts.Add(30 * time.Second)
+		}
+	}
+	seg := act.Records[0].Position.DistanceTo(act.Records[1].Position)
+
+	testCases := []struct {
+		dist   float64
+		expect float64
+	}{
+		{500, 500 / seg * 30},
+		{1000, 1000 / seg * 30},
+		{2000, 15*30 + (2000/seg-15)*60},
+		{5000, math.NaN()},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			e := bestEffort(act, tc.dist)
+			if math.IsNaN(tc.expect) {
+				if e != nil {
+					t.Fatal(e, "!=", nil)
+				}
+			} else if e == nil {
+				t.Fatal(nil, "!=", tc.expect)
+			} else if math.Abs(e.Duration-tc.expect) > 0.001 {
+				t.Fatal(e.Duration, "!=", tc.expect)
+			}
+		})
+	}
+}
+
+func TestLongestStreak(t *testing.T) {
+	day := func(d int) *parse.Activity {
+		return &parse.Activity{Location: time.UTC, Records: []*parse.Record{{Timestamp: time.Date(2022, 2, d, 12, 0, 0, 0, time.UTC)}}}
+	}
+
+	testCases := []struct {
+		activities []*parse.Activity
+		expect     Streak
+	}{
+		{nil, Streak{}},
+		{[]*parse.Activity{day(3)}, Streak{1, 1, "2022-02-03", "2022-02-03"}},
+		{[]*parse.Activity{day(5), day(3), day(4), day(4), day(8)}, Streak{3, 3, "2022-02-03", "2022-02-05"}},
+		{[]*parse.Activity{day(1), day(3), day(4), day(5), day(6)}, Streak{4, 4, "2022-02-03", "2022-02-06"}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			if actual := NewPersonalRecords(tc.activities).LongestStreak; actual != tc.expect {
+				t.Fatal(actual, "!=", tc.expect)
+			}
+		})
+	}
+}
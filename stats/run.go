@@ -0,0 +1,61 @@
+package stats
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+)
+
+// Options are the options of the stats command.
+type Options struct {
+	Input    []string       // The paths of the input files
+	Output   string         // The path of the output file, or empty for standard output
+	Format   string         // The output format, supports text, json, yaml
+	Bins     int            // The maximum number of bins in each histogram
+	Locale   string         // The BCP 47 language tag used to format text output
+	Units    string         // The system of measurement used in text output, or empty for the locale default
+	Selector parse.Selector // The filters specifying which activities to use
+}
+
+// Run scans, parses and filters the input activities, then writes their statistics, breakdowns, personal records and goals.
+// Parsing progress is drawn on standard error if it is a terminal, and the work stops early once ctx is done.
+func Run(ctx context.Context, opts *Options) error {
+	// If no input was provided, the current directory is the input
+	if len(opts.Input) == 0 {
+		opts.Input = []string{"."}
+	}
+
+	// Draw the parsing progress on a terminal
+	var rep progress.Reporter
+	if progress.IsTerminal(os.Stderr) {
+		rep = progress.NewBar(os.Stderr)
+	}
+
+	files, err := scan.Scan(ctx, opts.Input)
+	if err != nil {
+		return err
+	}
+	p, err := conv.NewPrinter(opts.Locale, opts.Units)
+	if err != nil {
+		return err
+	}
+
+	activities, stats, err := parse.Parse(ctx, files, &opts.Selector, rep)
+	if err != nil {
+		return err
+	}
+
+	r := NewReport(activities, stats, opts.Bins, p.Units, time.Now())
+	write := func(w io.Writer) error { return WriteReport(w, r, opts.Format, p) }
+	if opts.Output == "" {
+		return write(os.Stdout)
+	}
+	return img.SaveFile(opts.Output, write)
+}
@@ -0,0 +1,136 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{"text", "json", "yaml"}
+
+// Summary is the machine-readable representation of parse.Stats.
+// Distances are in meters, durations in seconds and paces in seconds per kilometer.
+type Summary struct {
+	Activities int            `json:"activities" yaml:"activities"`
+	Records    int            `json:"records" yaml:"records"`
+	Sports     map[string]int `json:"sports" yaml:"sports"`
+	After      time.Time      `json:"after" yaml:"after"`
+	Before     time.Time      `json:"before" yaml:"before"`
+	Duration   Aggregate      `json:"duration_s" yaml:"duration_s"`
+	Distance   Aggregate      `json:"distance_m" yaml:"distance_m"`
+	Pace       Aggregate      `json:"pace_s_per_km" yaml:"pace_s_per_km"`
+	BoundedBy  geo.Feature    `json:"bounded_by" yaml:"bounded_by"`
+	StartsNear geo.Feature    `json:"starts_near" yaml:"starts_near"`
+	EndsNear   geo.Feature    `json:"ends_near" yaml:"ends_near"`
+	Extent     geo.Feature    `json:"extent" yaml:"extent"`
+}
+
+// Aggregate is the range, average and total of a quantity over all activities.
+type Aggregate struct {
+	Min     float64  `json:"min" yaml:"min"`
+	Max     float64  `json:"max" yaml:"max"`
+	Average float64  `json:"average" yaml:"average"`
+	Total   *float64 `json:"total,omitempty" yaml:"total,omitempty"`
+}
+
+// NewSummary converts s into its machine-readable representation.
+func NewSummary(s *parse.Stats) *Summary {
+	sumDur, sumDist := s.SumDuration.Seconds(), s.SumDistance
+	return &Summary{
+		Activities: s.CountActivities,
+		Records:    s.CountRecords,
+		Sports:     s.SportCounts,
+		After:      s.After,
+		Before:     s.Before,
+		Duration:   Aggregate{s.MinDuration.Seconds(), s.MaxDuration.Seconds(), s.AvgDuration().Seconds(), &sumDur},
+		Distance:   Aggregate{s.MinDistance, s.MaxDistance, s.AvgDistance(), &sumDist},
+		Pace:       Aggregate{s.MinPace.Seconds() * 1000, s.MaxPace.Seconds() * 1000, s.AvgPace().Seconds() * 1000, nil},
+		BoundedBy:  s.BoundedBy.GeoJSON(),
+		StartsNear: s.StartsNear.GeoJSON(),
+		EndsNear:   s.EndsNear.GeoJSON(),
+		Extent:     s.Extent.GeoJSON(),
+	}
+}
+
+// Write writes the stats s to w in the given format, see Formats.
+// The printer p is used for the human-readable text format.
+func Write(w io.Writer, s *parse.Stats, format string, p *conv.Printer) error {
+	return encode(w, format, func() { s.Fprint(w, p) }, NewSummary(s))
+}
+
+// Report is the full output of the stats command, combining the summary Stats with a Breakdown, PersonalRecords and Goals.
+type Report struct {
+	Stats           *parse.Stats
+	Breakdown       *Breakdown
+	PersonalRecords *PersonalRecords
+	Goals           *Goals
+}
+
+// NewReport builds the report of the activities summarized by st, with at most bins bins in each histogram.
+// Distances are grouped in the given units, and goals are measured up to now.
+func NewReport(activities []*parse.Activity, st *parse.Stats, bins int, units conv.Units, now time.Time) *Report {
+	return &Report{
+		Stats:           st,
+		Breakdown:       NewBreakdown(activities, bins, units),
+		PersonalRecords: NewPersonalRecords(activities),
+		Goals:           NewGoals(activities, now, 3),
+	}
+}
+
+// WriteReport writes the report r to w in the given format, see Formats.
+// The printer p is used for the human-readable text format.
+func WriteReport(w io.Writer, r *Report, format string, p *conv.Printer) error {
+	text := func() {
+		r.Stats.Fprint(w, p)
+		r.Breakdown.Fprint(w, p)
+		r.PersonalRecords.Fprint(w, p)
+		r.Goals.Fprint(w, p)
+	}
+	v := struct {
+		*Summary        `yaml:",inline"`
+		Breakdown       *Breakdown       `json:"breakdown" yaml:"breakdown"`
+		PersonalRecords *PersonalRecords `json:"personal_records" yaml:"personal_records"`
+		Goals           *Goals           `json:"goals" yaml:"goals"`
+	}{NewSummary(r.Stats), r.Breakdown, r.PersonalRecords, r.Goals}
+	return encode(w, format, text, v)
+}
+
+// encode writes v to w in the given format, calling text for the human-readable text format.
+func encode(w io.Writer, format string, text func(), v any) error {
+	switch format {
+	case "", "text":
+		text()
+		return nil
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("format %q not recognized", format)
+	}
+}
+
+// Save writes the stats s in the given format to the file at path, or standard output if path is empty.
+func Save(path string, s *parse.Stats, format string, p *conv.Printer) error {
+	write := func(w io.Writer) error { return Write(w, s, format, p) }
+	if path == "" {
+		return write(os.Stdout)
+	}
+	return img.SaveFile(path, write)
+}
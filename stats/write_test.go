@@ -0,0 +1,75 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+func testStats() *parse.Stats {
+	return &parse.Stats{
+		CountActivities: 2,
+		CountRecords:    100,
+		SportCounts:     map[string]int{"running": 2},
+		After:           time.Date(2022, 2, 13, 0, 0, 0, 0, time.UTC),
+		Before:          time.Date(2022, 2, 14, 0, 0, 0, 0, time.UTC),
+		MinDuration:     20 * time.Minute,
+		MaxDuration:     40 * time.Minute,
+		SumDuration:     time.Hour,
+		MinDistance:     4000,
+		MaxDistance:     8000,
+		SumDistance:     12000,
+		MinPace:         4 * time.Millisecond,
+		MaxPace:         6 * time.Millisecond,
+		BoundedBy:       geo.Circle{Origin: geo.NewPointFromDegrees(1, 2), Radius: 500},
+		Extent:          geo.Box{Min: geo.NewPointFromDegrees(-17, 179), Max: geo.NewPointFromDegrees(-16, -179)},
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Write(buf, testStats(), "json", nil); err != nil {
+		t.Fatal(err)
+	}
+	var actual map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path   []string
+		expect any
+	}{
+		{[]string{"activities"}, 2.0},
+		{[]string{"duration_s", "total"}, 3600.0},
+		{[]string{"duration_s", "average"}, 1800.0},
+		{[]string{"distance_m", "average"}, 6000.0},
+		{[]string{"pace_s_per_km", "min"}, 4.0},
+		{[]string{"bounded_by", "geometry", "type"}, "Point"},
+		{[]string{"bounded_by", "properties", "radius"}, 500.0},
+		{[]string{"extent", "geometry", "type"}, "MultiPolygon"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			var v any = actual
+			for _, key := range tc.path {
+				v = v.(map[string]any)[key]
+			}
+			if v != tc.expect {
+				t.Fatal(v, "!=", tc.expect)
+			}
+		})
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, testStats(), "xml", nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -2,17 +2,22 @@ package main
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/NathanBaulch/rainbow-roads/geo"
 	"github.com/NathanBaulch/rainbow-roads/worms"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
 )
 
 var (
 	// wormsOpts are the options to make the worms animation
 	wormsOpts = &worms.Options{
-		Title:   Title,
-		Version: Version,
+		Title:      Title,
+		Version:    Version,
+		Projection: "mercator",
 	}
 	// wormsCmd represents the "worms" command
 	wormsCmd = &cobra.Command{
@@ -20,27 +25,13 @@ var (
 		Short: "Animate exercise activities",
 		// Pre-checks to ensure value are in bounds
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			if wormsOpts.Frames == 0 {
-				return flagError("frames", wormsOpts.Frames, "must be positive")
-			}
-			if wormsOpts.FPS == 0 {
-				return flagError("fps", wormsOpts.FPS, "must be positive")
-			}
-			if wormsOpts.Width == 0 {
-				return flagError("width", wormsOpts.Width, "must be positive")
-			}
-			if wormsOpts.ColorDepth == 0 {
-				return flagError("color_depth", wormsOpts.ColorDepth, "must be positive")
-			}
-			if wormsOpts.Speed < 1 {
-				return flagError("speed", wormsOpts.Speed, "must be greater than or equal to 1")
-			}
-			return nil
+			return checkWorms(wormsOpts)
 		},
 		// Run the command
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			wormsOpts.Input = args
-			return worms.Run(wormsOpts)
+			wormsOpts.Manifest = newManifest(cmd, args)
+			return cancelled(cmd, worms.Run(cmd.Context(), wormsOpts))
 		},
 	}
 )
@@ -50,22 +41,11 @@ func init() {
 	rootCmd.AddCommand(wormsCmd)
 
 	// General flags (output location and format)
-	general := &pflag.FlagSet{}
-	general.StringVarP(&wormsOpts.Output, "output", "o", "out", "optional path of the generated file")
-	general.StringVarP(&wormsOpts.Format, "format", "f", "gif", "output file format string, supports gif, png, zip")
+	general, rendering := wormsFlagSets(wormsOpts)
+	general.AddFlagSet(configFlagSet())
 	general.VisitAll(func(f *pflag.Flag) { wormsCmd.Flags().Var(f.Value, f.Name, f.Usage) })
 
 	// Rendering flags (fps, width, colors, etc)
-	rendering := &pflag.FlagSet{}
-	rendering.UintVar(&wormsOpts.Frames, "frames", 200, "number of animation frames")
-	rendering.UintVar(&wormsOpts.FPS, "fps", 20, "animation frame rate")
-	rendering.UintVarP(&wormsOpts.Width, "width", "w", 500, "width of the generated image in pixels")
-	_ = wormsOpts.Colors.Parse("#fff,#ff8,#911,#414,#007@.5,#003")
-	rendering.Var((*ColorsFlag)(&wormsOpts.Colors), "colors", "CSS linear-colors inspired color scheme string, eg red,yellow,green,blue,black")
-	rendering.UintVar(&wormsOpts.ColorDepth, "color_depth", 5, "number of bits per color in the image palette")
-	rendering.Float64Var(&wormsOpts.Speed, "speed", 1.25, "how quickly activities should progress")
-	rendering.BoolVar(&wormsOpts.Loop, "loop", false, "start each activity sequentially and animate continuously")
-	rendering.BoolVar(&wormsOpts.NoWatermark, "no_watermark", false, "suppress the embedded project name and version string")
 	rendering.VisitAll(func(f *pflag.Flag) { wormsCmd.Flags().Var(f.Value, f.Name, f.Usage) })
 
 	// Filtering flags
@@ -87,3 +67,133 @@ func init() {
 		return nil
 	})
 }
+
+// wormsFlagSets creates the general and rendering flags of the "worms" command bound to opts, excluding the config flags.
+func wormsFlagSets(opts *worms.Options) (general, rendering *pflag.FlagSet) {
+	// General flags (output location and format)
+	general = &pflag.FlagSet{}
+	general.StringVarP(&opts.Output, "output", "o", "out", "optional path of the generated file")
+	general.StringVarP(&opts.Format, "format", "f", "gif", "output file format string, supports "+strings.Join(worms.Formats, ", "))
+	general.StringVar(&opts.Encoder, "encoder", worms.DefaultEncoder, "command that encodes a Y4M stream on standard input to the mp4 format on standard output")
+	general.AddFlagSet(statsFlagSet(&opts.StatsFormat, &opts.StatsFile))
+	general.AddFlagSet(localeFlagSet(&opts.Locale, &opts.Units))
+
+	// Rendering flags (fps, width, colors, etc)
+	rendering = &pflag.FlagSet{}
+	rendering.UintVar(&opts.Frames, "frames", 200, "number of animation frames")
+	rendering.UintVar(&opts.FPS, "fps", 20, "animation frame rate")
+	rendering.UintVarP(&opts.Width, "width", "w", 500, "width of the generated image in pixels")
+	_ = opts.Colors.Parse(worms.DefaultColors)
+	rendering.Var((*ColorsFlag)(&opts.Colors), "colors", "CSS linear-colors inspired color scheme string, eg red,yellow,green,blue,black")
+	rendering.Var((*SportColorsFlag)(&opts.SportColors), "sport_colors", "CSS linear-colors inspired color scheme string per sport, other sports use colors, eg running=#f80,#fff;cycling=#08f,#fff")
+	rendering.StringVar(&opts.ColorBy, "color_by", "age", "record attribute to color the worms by instead of their age, supports "+strings.Join(worms.ColorBys, ", "))
+	rendering.UintVar(&opts.ColorDepth, "color_depth", 5, "number of bits per color in the image palette, at most 8")
+	rendering.BoolVar(&opts.Truecolor, "truecolor", false, "render full color frames with anti-aliased lines, only quantized to the color depth when saved as gif or zip")
+	rendering.Float64Var(&opts.LineWidth, "line_width", 1.5, "width of the lines in pixels in truecolor mode")
+	rendering.Float64Var(&opts.GlowRadius, "glow_radius", 2, "distance the glow extends beyond the lines in pixels in truecolor mode, or 0 for no glow")
+	rendering.Float64Var(&opts.GlowFalloff, "glow_falloff", 2, "exponent of the decay of the glow with distance in truecolor mode, higher is sharper")
+	rendering.Float64Var(&opts.Speed, "speed", 1.25, "how quickly activities should progress")
+	rendering.StringVar(&opts.Trail, "trail", "persistent", "how old lines fade, supports "+strings.Join(worms.Trails, ", "))
+	rendering.Float64Var(&opts.TrailLength, "trail_length", 2, "length of the tail, or half-life of the decay, in seconds of animation")
+	rendering.Float64Var(&opts.Ghost, "ghost", 0.25, "intensity that old lines fade to in tail and decay modes, from 0 for gone to 1 for undimmed")
+	rendering.BoolVar(&opts.Loop, "loop", false, "start each activity sequentially and animate continuously")
+	rendering.BoolVar(&opts.Timeline, "timeline", false, "start each activity at an offset matching its start date, so history unfolds in calendar order")
+	rendering.Float64Var(&opts.DayRate, "day_rate", 0, "number of days of history animated per second in timeline mode, or 0 to fit the whole history into the animation")
+	rendering.Var((*DurationFlag)(&opts.MaxGap), "max_gap", "longest idle period between activities in timeline mode, longer periods are shortened to it, eg 30d")
+	rendering.BoolVar(&opts.Clock, "clock", false, "place each activity by its local time of day and animate one day from 00:00 to 24:00 with a clock overlay")
+	rendering.StringVar(&opts.TimeZone, "time_zone", "", "IANA time zone of activities whose files don't record one in clock mode, eg Australia/Melbourne, instead of estimating it from their longitude")
+	rendering.Var((*BoxFlag)(&opts.Viewport), "viewport", "explicit region to render instead of fitting all activities, eg -37.9,144.8,-37.7,145 or -37.8,144.9,10kmx5km")
+	rendering.Var((*CameraFlag)(&opts.Camera), "camera", "semicolon separated viewports or center and zoom keyframes that the camera pans and zooms between, eg -37.8,144.9,1x@0;-37.8,144.9,4x@50%;-37.9,144.8,-37.7,145")
+	rendering.Float64Var(&opts.Follow, "follow", 0, "zoom of a camera that follows the densest group of moving worms, eg 3, or 0 for a fixed camera")
+	rendering.Var((*ProjectionFlag)(&opts.Projection), "projection", "map projection, supports "+strings.Join(geo.ProjectionNames(), ", "))
+	rendering.BoolVar(&opts.NoWatermark, "no_watermark", false, "suppress the embedded project name and version string")
+	rendering.AddFlagSet(overlayFlagSet(&opts.Overlays, &opts.Text))
+	return general, rendering
+}
+
+// checkWorms checks that the options of the "worms" command are in bounds.
+func checkWorms(opts *worms.Options) error {
+	if opts.Format != "" && !slices.Contains(worms.Formats, opts.Format) {
+		return flagError("format", opts.Format, "supports "+strings.Join(worms.Formats, ", "))
+	}
+	if opts.Frames == 0 {
+		return flagError("frames", opts.Frames, "must be positive")
+	}
+	if opts.FPS == 0 {
+		return flagError("fps", opts.FPS, "must be positive")
+	}
+	if opts.Width == 0 {
+		return flagError("width", opts.Width, "must be positive")
+	}
+	if opts.ColorDepth == 0 {
+		return flagError("color_depth", opts.ColorDepth, "must be positive")
+	}
+	if opts.ColorDepth > 8 {
+		return flagError("color_depth", opts.ColorDepth, "must be at most 8, the palette of a gif")
+	}
+	if opts.ColorBy != "" && !slices.Contains(worms.ColorBys, opts.ColorBy) {
+		return flagError("color_by", opts.ColorBy, "supports "+strings.Join(worms.ColorBys, ", "))
+	}
+	if opts.ColorBy != "" && opts.ColorBy != "age" && !opts.Truecolor && opts.ColorDepth < 3 {
+		return flagError("color_depth", opts.ColorDepth, "must be at least 3 when coloring by "+opts.ColorBy)
+	}
+	if len(opts.SportColors) > 0 {
+		if opts.ColorBy != "" && opts.ColorBy != "age" {
+			return flagError("sport_colors", (*SportColorsFlag)(&opts.SportColors), "cannot be combined with color_by")
+		}
+		if (1<<opts.ColorDepth-2)/(len(opts.SportColors)+1) < 2 {
+			return flagError("color_depth", opts.ColorDepth, fmt.Sprintf("too small for %d sport colors", len(opts.SportColors)))
+		}
+	}
+	if opts.LineWidth <= 0 {
+		return flagError("line_width", opts.LineWidth, "must be positive")
+	}
+	if opts.GlowRadius < 0 {
+		return flagError("glow_radius", opts.GlowRadius, "must not be negative")
+	}
+	if opts.GlowFalloff <= 0 {
+		return flagError("glow_falloff", opts.GlowFalloff, "must be positive")
+	}
+	if opts.Trail != "" && !slices.Contains(worms.Trails, opts.Trail) {
+		return flagError("trail", opts.Trail, "supports "+strings.Join(worms.Trails, ", "))
+	}
+	if opts.TrailLength <= 0 {
+		return flagError("trail_length", opts.TrailLength, "must be positive")
+	}
+	if opts.Ghost < 0 || opts.Ghost > 1 {
+		return flagError("ghost", opts.Ghost, "must be between 0 and 1")
+	}
+	if opts.Follow < 0 {
+		return flagError("follow", opts.Follow, "must not be negative")
+	}
+	if opts.Follow > 0 && len(opts.Camera) > 0 {
+		return flagError("follow", opts.Follow, "cannot be combined with camera")
+	}
+	if opts.Timeline && opts.Loop {
+		return flagError("timeline", opts.Timeline, "cannot be combined with loop")
+	}
+	if opts.Clock && (opts.Loop || opts.Timeline) {
+		return flagError("clock", opts.Clock, "cannot be combined with loop or timeline")
+	}
+	if opts.TimeZone != "" {
+		if !opts.Clock {
+			return flagError("time_zone", opts.TimeZone, "only applies in clock mode")
+		}
+		if _, err := time.LoadLocation(opts.TimeZone); err != nil {
+			return flagError("time_zone", opts.TimeZone, "not recognized")
+		}
+	}
+	if opts.DayRate < 0 {
+		return flagError("day_rate", opts.DayRate, "must not be negative")
+	}
+	if opts.Speed < 1 {
+		return flagError("speed", opts.Speed, "must be greater than or equal to 1")
+	}
+	if err := checkText(&opts.Text); err != nil {
+		return err
+	}
+	if err := checkLocale(opts.Locale, opts.Units); err != nil {
+		return err
+	}
+	return checkStatsFormat(opts.StatsFormat)
+}
@@ -0,0 +1,198 @@
+package worms
+
+import (
+	"math"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+)
+
+// Keyframe is a viewport the camera shows at a point in the animation.
+type Keyframe struct {
+	At     float64   // The percentage progress through the animation at which the viewport is shown
+	Box    geo.Box   // The viewport, or zero for a viewport centered on Center
+	Center geo.Point // The center of the viewport when Box is zero
+	Zoom   float64   // The magnification of the frame when Box is zero, eg 2 to show half the width
+}
+
+// Camera is a track of keyframes in time order, interpolated across the frames of the animation.
+type Camera []Keyframe
+
+// view maps projected coordinates onto the pixels of a frame.
+type view struct {
+	minX, maxY float64 // The projected coordinates of the top-left corner of the frame
+	scale      float64 // The number of pixels per projected unit
+}
+
+// pixel returns the position in the frame of projected point pt.
+func (v view) pixel(pt subpixel) subpixel {
+	return subpixel{x: (pt.x - v.minX) * v.scale, y: (v.maxY - pt.y) * v.scale}
+}
+
+// lens is the projected center and scale of a view, independent of the size of the frame.
+type lens struct {
+	x, y  float64 // The projected coordinates of the center of the view
+	scale float64 // The number of pixels per projected unit
+}
+
+// view returns the view of a frame of the given size centered on the lens.
+func (l lens) view(width, height float64) view {
+	return view{minX: l.x - width/2/l.scale, maxY: l.y + height/2/l.scale, scale: l.scale}
+}
+
+// cameraViews returns the view of every frame of a width by height animation, given the view that fits the whole map.
+// Frames between keyframes of the camera pan linearly and zoom geometrically with eased motion,
+// while frames that follow the activities track the densest group of active worms.
+// It returns nil when the camera is fixed, so every frame uses the fitted view.
+func (r *Renderer) cameraViews(proj geo.Projection, lon float64, points [][]subpixel, fitted view, width, height float64) []view {
+	o := &r.o
+	base := lens{x: fitted.minX + width/2/fitted.scale, y: fitted.maxY - height/2/fitted.scale, scale: fitted.scale}
+
+	// Find the lens of every frame
+	lenses := make([]lens, o.Frames)
+	switch {
+	case len(o.Camera) > 0:
+		// Fit each keyframe into the frame
+		keys := make([]lens, len(o.Camera))
+		for k, kf := range o.Camera {
+			if kf.Box.IsZero() {
+				x, y := proj.Project(kf.Center.Unwrap(lon))
+				keys[k] = lens{x: x, y: y, scale: base.scale * kf.Zoom}
+				continue
+			}
+			minX, minY := math.Inf(1), math.Inf(1)
+			maxX, maxY := math.Inf(-1), math.Inf(-1)
+			for _, pt := range kf.Box.Boundary(16) {
+				x, y := proj.Project(pt.Unwrap(lon))
+				minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+				minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+			}
+			keys[k] = lens{x: (minX + maxX) / 2, y: (minY + maxY) / 2, scale: math.Min(width/(maxX-minX), height/(maxY-minY))}
+		}
+
+		// Interpolate between the keyframes either side of each frame, holding the first and last keyframes
+		for f := range lenses {
+			fpc := float64(f+1) / float64(o.Frames)
+			k := 0
+			for k < len(o.Camera) && o.Camera[k].At <= fpc {
+				k++
+			}
+			switch {
+			case k == 0:
+				lenses[f] = keys[0]
+			case k == len(o.Camera):
+				lenses[f] = keys[k-1]
+			default:
+				t := (fpc - o.Camera[k-1].At) / (o.Camera[k].At - o.Camera[k-1].At)
+				lenses[f] = interpolateLens(keys[k-1], keys[k], t*t*(3-2*t))
+			}
+		}
+	case o.Follow > 0:
+		lenses = r.followLenses(points, base, width/2/(base.scale*o.Follow))
+		for f := range lenses {
+			lenses[f].scale = base.scale * o.Follow
+		}
+	default:
+		return nil
+	}
+
+	views := make([]view, len(lenses))
+	for f, l := range lenses {
+		views[f] = l.view(width, height)
+	}
+	return views
+}
+
+// interpolateLens returns the lens at fraction t of the way from l0 to l1,
+// panning linearly and zooming geometrically so that zooming feels steady.
+func interpolateLens(l0, l1 lens, t float64) lens {
+	return lens{
+		x:     l0.x + t*(l1.x-l0.x),
+		y:     l0.y + t*(l1.y-l0.y),
+		scale: l0.scale * math.Pow(l1.scale/l0.scale, t),
+	}
+}
+
+// followLenses returns the lens of every frame centered on the densest group of worm heads that are still moving,
+// where a group is the heads within radius projected units of one of them.
+// The centers are averaged over a second of animation centered on each frame so the camera glides rather than jumps,
+// and frames without any moving heads hold the nearest center, or the center of base.
+func (r *Renderer) followLenses(points [][]subpixel, base lens, radius float64) []lens {
+	o := &r.o
+	targets := make([]lens, o.Frames)
+	found := make([]bool, o.Frames)
+	heads := make([]subpixel, 0, len(r.activities))
+	for f := range targets {
+		// Find the head of every activity still in progress, the reached record closest to the frame
+		fpc := float64(f+1) / float64(o.Frames)
+		heads = heads[:0]
+		for i, act := range r.activities {
+			head, headPC := -1, math.Inf(1)
+			for j, rec := range act.Records {
+				if pc, ok := r.segmentProgress(fpc, rec); ok && pc < headPC {
+					head, headPC = j, pc
+				}
+			}
+			if head >= 0 && head < len(act.Records)-1 {
+				heads = append(heads, points[i][head])
+			}
+		}
+
+		// Center on the mean of the heads around the head with the most neighbors
+		best := 0
+		for _, h := range heads {
+			var group []subpixel
+			for _, n := range heads {
+				if math.Hypot(n.x-h.x, n.y-h.y) <= radius {
+					group = append(group, n)
+				}
+			}
+			if len(group) > best {
+				best = len(group)
+				targets[f] = lens{}
+				for _, n := range group {
+					targets[f].x += n.x / float64(len(group))
+					targets[f].y += n.y / float64(len(group))
+				}
+			}
+		}
+		found[f] = best > 0
+	}
+
+	// Hold the nearest center through frames without moving heads
+	last := -1
+	for f := range targets {
+		if found[f] {
+			if last < 0 {
+				for g := 0; g < f; g++ {
+					targets[g] = targets[f]
+				}
+			}
+			last = f
+		} else if last >= 0 {
+			targets[f] = targets[last]
+		}
+	}
+	if last < 0 {
+		for f := range targets {
+			targets[f] = base
+		}
+	}
+
+	// Average the centers over a second of animation centered on each frame
+	half := int(o.FPS / 2)
+	lenses := make([]lens, len(targets))
+	for f := range lenses {
+		lo, hi := f-half, f+half
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(targets)-1 {
+			hi = len(targets) - 1
+		}
+		for g := lo; g <= hi; g++ {
+			lenses[f].x += targets[g].x / float64(hi-lo+1)
+			lenses[f].y += targets[g].y / float64(hi-lo+1)
+		}
+	}
+	return lenses
+}
@@ -0,0 +1,134 @@
+package worms
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+)
+
+func TestInterpolateLens(t *testing.T) {
+	testCases := []struct {
+		t      float64
+		expect lens
+	}{
+		{0, lens{x: 0, y: 10, scale: 1}},
+		{0.5, lens{x: 5, y: 5, scale: 2}},
+		{1, lens{x: 10, y: 0, scale: 4}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			actual := interpolateLens(lens{x: 0, y: 10, scale: 1}, lens{x: 10, y: 0, scale: 4}, testCase.t)
+			if math.Abs(actual.x-testCase.expect.x) > 1e-9 || math.Abs(actual.y-testCase.expect.y) > 1e-9 || math.Abs(actual.scale-testCase.expect.scale) > 1e-9 {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+func TestCameraViews(t *testing.T) {
+	proj, err := geo.NewProjection("equirectangular", geo.Point{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, y := proj.Project(geo.NewPointFromDegrees(1, 1))
+	fitted := view{minX: -5, maxY: 5, scale: 10}
+
+	testCases := []struct {
+		camera Camera
+		frame  int
+		expect lens
+	}{
+		{nil, 0, lens{}},
+		{Camera{{At: 0.5, Zoom: 2}}, 0, lens{x: 0, y: 0, scale: 20}},
+		{Camera{{At: 0, Zoom: 1}, {At: 1, Center: geo.NewPointFromDegrees(1, 1), Zoom: 4}}, 1, lens{x: x / 2, y: y / 2, scale: 20}},
+		{Camera{{At: 0, Zoom: 1}, {At: 0.5, Center: geo.NewPointFromDegrees(1, 1), Zoom: 4}}, 3, lens{x: x, y: y, scale: 40}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			r := &Renderer{o: Options{Frames: 4, Camera: testCase.camera}}
+			views := r.cameraViews(proj, 0, nil, fitted, 100, 100)
+			if testCase.camera == nil {
+				if views != nil {
+					t.Fatal(views, "!=", nil)
+				}
+				return
+			}
+			expect := testCase.expect.view(100, 100)
+			actual := views[testCase.frame]
+			if math.Abs(actual.minX-expect.minX) > 1e-9 || math.Abs(actual.maxY-expect.maxY) > 1e-9 || math.Abs(actual.scale-expect.scale) > 1e-9 {
+				t.Fatal(actual, "!=", expect)
+			}
+		})
+	}
+}
+
+func TestRendererCamera(t *testing.T) {
+	// Count the lit pixels of the last frame, which change as the camera moves and shrink as it zooms out
+	dir := writeActivity(t)
+	lit := func(camera Camera, follow float64, truecolor bool) int {
+		opts := &Options{Input: []string{dir}, Width: 100, Frames: 10, FPS: 10, ColorDepth: 5, Speed: 1, Projection: "mercator", NoWatermark: true, Truecolor: truecolor, Camera: camera, Follow: follow}
+		ims, err := New(opts).Render(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		im := ims[len(ims)-1]
+		n := 0
+		for y := im.Bounds().Min.Y; y < im.Bounds().Max.Y; y++ {
+			for x := im.Bounds().Min.X; x < im.Bounds().Max.X; x++ {
+				if r, g, b, _ := im.At(x, y).RGBA(); r+g+b > 0 {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	center := geo.NewPointFromDegrees(-37.815, 144.91)
+	testCases := []struct {
+		camera Camera
+		follow float64
+		expect func(fixed, moved int) bool
+	}{
+		{Camera{{At: 0, Center: center, Zoom: 1}, {At: 1, Center: center, Zoom: 0.5}}, 0, func(fixed, moved int) bool { return moved < fixed }},
+		{Camera{{Box: geo.NewBoxAround(geo.NewPointFromDegrees(-37.81, 144.91), 1000, 1000)}}, 0, func(fixed, moved int) bool { return moved > 0 && moved != fixed }},
+		{nil, 4, func(fixed, moved int) bool { return moved != fixed }},
+	}
+
+	for i, testCase := range testCases {
+		for _, truecolor := range []bool{false, true} {
+			t.Run(fmt.Sprintf("test case %d truecolor %t", i, truecolor), func(t *testing.T) {
+				fixed, moved := lit(nil, 0, truecolor), lit(testCase.camera, testCase.follow, truecolor)
+				if !testCase.expect(fixed, moved) {
+					t.Fatal(fixed, moved)
+				}
+			})
+		}
+	}
+}
+
+func TestOffscreen(t *testing.T) {
+	rect := image.Rect(0, 0, 10, 10)
+	testCases := []struct {
+		x0, y0, x1, y1 int
+		expect         bool
+	}{
+		{1, 1, 5, 5, false},
+		{-5, 5, 15, 5, false},
+		{-5, 1, -1, 9, true},
+		{1, 10, 9, 12, true},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			if actual := offscreen(testCase.x0, testCase.y0, testCase.x1, testCase.y1, rect); actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
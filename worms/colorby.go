@@ -0,0 +1,336 @@
+package worms
+
+import (
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+// ColorBys lists the supported record attributes that worms can be colored by.
+// Coloring by age uses the color gradient of the options, while every other attribute has its own colors.
+var ColorBys = []string{"age", "sport", "year", "speed", "pace", "heart_rate", "elevation", "grade", "activity"}
+
+// categoricalColors is the palette of the attributes with distinct categories, repeated when there are more categories than colors.
+const categoricalColors = "#4e79a7,#f28e2b,#e15759,#76b7b2,#59a14f,#edc948,#b07aa1,#ff9da7,#9c755f,#bab0ac"
+
+// attributeGradients maps each continuous attribute to the gradient of its values, from lowest to highest.
+var attributeGradients = map[string]string{
+	"speed":      "#2c7bb6,#abd9e9,#ffffbf,#fdae61,#d7191c",
+	"pace":       "#d7191c,#fdae61,#ffffbf,#abd9e9,#2c7bb6",
+	"heart_rate": "#fee5d9,#fcae91,#fb6a4a,#de2d26,#a50f15",
+	"elevation":  "#1a9850,#91cf60,#d9ef8b,#fee08b,#fc8d59,#d73027",
+	"grade":      "#2166ac,#67a9cf,#f7f7f7,#ef8a62,#b2182b",
+}
+
+// unknownColor is the color of records whose attribute is unknown, such as heart rate in a file without it.
+var unknownColor = color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+
+// headPercent is the age below which lines colored by attribute are blended towards white, so the heads of the worms stand out.
+const headPercent = 0.02
+
+// smoothWindow is the period speeds and grades are averaged over, to even out GPS noise.
+const smoothWindow = 30 * time.Second
+
+// outlierPercentile is the share of values at each end of a continuous attribute that is clamped to the colors at the ends of its gradient.
+const outlierPercentile = 0.05
+
+// colorScheme maps the attribute values of every record to colors.
+type colorScheme struct {
+	by         string            // The attribute, see ColorBys
+	palette    []color.RGBA      // The colors of the categories of a categorical attribute, or nil for a continuous attribute
+	gradient   img.ColorGradient // The gradient of a continuous attribute, from its lowest to its highest value
+	categories []string          // The label of each category of a categorical attribute
+	lo, hi     float64           // The range of the values of a continuous attribute
+	values     [][]float64       // The category index or gradient position of every record of every activity, or NaN if unknown
+}
+
+// newColorScheme calculates the values of attribute by for every record of the activities,
+// given the distance covered by each record, or returns nil when coloring by age.
+func newColorScheme(by string, activities []*parse.Activity, distances [][]float64) *colorScheme {
+	s := &colorScheme{by: by, values: make([][]float64, len(activities))}
+	switch by {
+	case "sport", "year", "activity":
+		// Number the distinct categories in sorted order
+		keys := make([]string, len(activities))
+		for i, act := range activities {
+			switch by {
+			case "sport":
+				keys[i] = act.Sport
+			case "year":
+				keys[i] = strconv.Itoa(act.Local(act.Start()).Year())
+			case "activity":
+				keys[i] = act.Local(act.Start()).Format("2006-01-02 15:04:05")
+			}
+		}
+		index := make(map[string]int)
+		for _, key := range keys {
+			if key != "" {
+				index[key] = 0
+			}
+		}
+		for key := range index {
+			s.categories = append(s.categories, key)
+		}
+		sort.Strings(s.categories)
+		for i, key := range s.categories {
+			index[key] = i
+			if by == "activity" {
+				s.categories[i] = key[:len("2006-01-02")]
+			}
+		}
+
+		// Every record of an activity shares its category
+		for i, act := range activities {
+			v := math.NaN()
+			if j, ok := index[keys[i]]; ok {
+				v = float64(j)
+			}
+			s.values[i] = make([]float64, len(act.Records))
+			for j := range s.values[i] {
+				s.values[i][j] = v
+			}
+		}
+		s.palette = parsePalette(categoricalColors)
+	case "speed", "pace", "heart_rate", "elevation", "grade":
+		// Measure the raw value of every record
+		var finite []float64
+		for i, act := range activities {
+			switch by {
+			case "speed", "pace":
+				secs := make([]float64, len(act.Records))
+				for j, rec := range act.Records {
+					secs[j] = rec.Timestamp.Sub(act.Start()).Seconds()
+				}
+				s.values[i] = smoothedRates(act, distances[i], secs)
+				if by == "pace" {
+					for j, v := range s.values[i] {
+						s.values[i][j] = 1 / v
+					}
+				}
+			case "grade":
+				eles := make([]float64, len(act.Records))
+				for j, rec := range act.Records {
+					eles[j] = rec.Elevation
+				}
+				s.values[i] = smoothedRates(act, eles, distances[i])
+			default:
+				s.values[i] = make([]float64, len(act.Records))
+				for j, rec := range act.Records {
+					if by == "heart_rate" {
+						s.values[i][j] = rec.HeartRate
+					} else {
+						s.values[i][j] = rec.Elevation
+					}
+				}
+			}
+			for _, v := range s.values[i] {
+				if !math.IsNaN(v) && !math.IsInf(v, 0) {
+					finite = append(finite, v)
+				}
+			}
+		}
+
+		// Find the range of values, ignoring outliers and centering grades on flat ground
+		if len(finite) > 0 {
+			sort.Float64s(finite)
+			k := int(outlierPercentile * float64(len(finite)-1))
+			s.lo, s.hi = finite[k], finite[len(finite)-1-k]
+			if by == "grade" {
+				s.hi = math.Max(math.Abs(s.lo), math.Abs(s.hi))
+				s.lo = -s.hi
+			}
+		}
+
+		// Convert the values to positions in the gradient
+		for _, vs := range s.values {
+			for j, v := range vs {
+				if math.IsNaN(v) {
+					continue
+				}
+				p := 0.5
+				if s.hi > s.lo {
+					p = math.Max(0, math.Min(1, (v-s.lo)/(s.hi-s.lo)))
+				}
+				vs[j] = p
+			}
+		}
+		_ = s.gradient.Parse(attributeGradients[by])
+	default:
+		return nil
+	}
+	return s
+}
+
+// smoothedRates returns the rate of change of ys with respect to xs at every record of the activity,
+// averaged over the records within half the smoothing window either side, or NaN where xs does not change.
+func smoothedRates(act *parse.Activity, ys, xs []float64) []float64 {
+	rates := make([]float64, len(act.Records))
+	lo, hi := 0, 0
+	for j, rec := range act.Records {
+		// Slide the window along to the records around this one
+		for act.Records[lo].Timestamp.Before(rec.Timestamp.Add(-smoothWindow / 2)) {
+			lo++
+		}
+		if hi < j {
+			hi = j
+		}
+		for hi+1 < len(act.Records) && !act.Records[hi+1].Timestamp.After(rec.Timestamp.Add(smoothWindow/2)) {
+			hi++
+		}
+
+		// Widen a window of one record to its neighbor
+		l, h := lo, hi
+		if l == h {
+			if h+1 < len(act.Records) {
+				h++
+			} else if l > 0 {
+				l--
+			}
+		}
+
+		rates[j] = math.NaN()
+		if dx := xs[h] - xs[l]; dx > 0 {
+			rates[j] = (ys[h] - ys[l]) / dx
+		}
+	}
+	return rates
+}
+
+// parsePalette parses a comma separated list of colors, skipping any that are not recognized.
+func parsePalette(str string) []color.RGBA {
+	var pal []color.RGBA
+	for _, part := range strings.Split(str, ",") {
+		if c, err := img.ParseColor(part); err == nil {
+			pal = append(pal, c)
+		}
+	}
+	return pal
+}
+
+// baseColors returns at most n colors of the scheme at full intensity for a paletted image,
+// the first n categories of a categorical attribute or n evenly spaced steps along the gradient of a continuous one.
+func (s *colorScheme) baseColors(n int) []color.RGBA {
+	if n < 1 {
+		n = 1
+	}
+	if s.palette != nil {
+		if n > len(s.palette) {
+			n = len(s.palette)
+		}
+		return s.palette[:n]
+	}
+	base := make([]color.RGBA, n)
+	for i := range base {
+		p := 0.5
+		if n > 1 {
+			p = float64(i) / float64(n-1)
+		}
+		base[i] = color.RGBAModel.Convert(s.gradient.GetColorAt(p)).(color.RGBA)
+	}
+	return base
+}
+
+// baseIndex returns the index of the color of value v among n base colors, or n for an unknown value.
+func (s *colorScheme) baseIndex(v float64, n int) int {
+	switch {
+	case math.IsNaN(v):
+		return n
+	case s.palette != nil:
+		return int(v) % n
+	default:
+		return int(math.Round(v * float64(n-1)))
+	}
+}
+
+// recordColors returns the exact color of every record of every activity.
+func (s *colorScheme) recordColors() [][]color.RGBA {
+	colors := make([][]color.RGBA, len(s.values))
+	for i, vs := range s.values {
+		colors[i] = make([]color.RGBA, len(vs))
+		for j, v := range vs {
+			switch {
+			case math.IsNaN(v):
+				colors[i][j] = unknownColor
+			case s.palette != nil:
+				colors[i][j] = s.palette[int(v)%len(s.palette)]
+			default:
+				colors[i][j] = color.RGBAModel.Convert(s.gradient.GetColorAt(v)).(color.RGBA)
+			}
+		}
+	}
+	return colors
+}
+
+// legend returns the labels and colors of a legend of the scheme, from the highest to the lowest value of a continuous attribute.
+func (s *colorScheme) legend(p *conv.Printer) ([]string, []color.Color) {
+	var labels []string
+	var swatches []color.Color
+	if s.palette != nil {
+		// List each category while there are colors left to tell them apart
+		for i, category := range s.categories {
+			if i == len(s.palette) {
+				labels = append(labels, p.Sprintf("+%d more", len(s.categories)-i))
+				swatches = append(swatches, nil)
+				break
+			}
+			labels = append(labels, category)
+			swatches = append(swatches, s.palette[i])
+		}
+		return labels, swatches
+	}
+
+	// Label evenly spaced steps along the gradient with their values
+	for i := legendSteps - 1; i >= 0; i-- {
+		pc := float64(i) / float64(legendSteps-1)
+		labels = append(labels, s.sprintValue(p, s.lo+pc*(s.hi-s.lo)))
+		swatches = append(swatches, s.gradient.GetColorAt(pc))
+	}
+	return labels, swatches
+}
+
+// sprintValue formats value v of the continuous attribute of the scheme using the given printer.
+func (s *colorScheme) sprintValue(p *conv.Printer, v float64) string {
+	switch s.by {
+	case "speed":
+		return conv.SprintSpeed(p, v)
+	case "pace":
+		return conv.SprintPace(p, time.Duration(v*float64(time.Second)))
+	case "heart_rate":
+		return p.Sprintf("%.0f bpm", v)
+	case "elevation":
+		return conv.SprintElevation(p, v)
+	default:
+		return p.Sprintf("%.0f%%", v*100)
+	}
+}
+
+// shadedPalette creates a palette of at most n colors for frames colored by the attribute of the scheme:
+// white for the heads of the worms, the base colors followed by the unknown color at each shade, then black and transparent.
+// Dimmer shades are dropped until there is room for every category, or at least two gradient steps,
+// and the number of colors in each shade is returned along with the palette.
+func (s *colorScheme) shadedPalette(n int, shades []float64) (color.Palette, int) {
+	want := 3
+	if s.palette != nil {
+		want = len(s.palette) + 1
+	}
+	for len(shades) > 1 && (n-3)/len(shades) < want {
+		shades = shades[:len(shades)-1]
+	}
+	base := append(append([]color.RGBA(nil), s.baseColors((n-3)/len(shades)-1)...), unknownColor)
+
+	pal := make(color.Palette, 0, len(base)*len(shades)+3)
+	pal = append(pal, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+	for _, k := range shades {
+		for _, c := range base {
+			pal = append(pal, shade(c, k))
+		}
+	}
+	return append(pal, color.Black, color.Transparent), len(base)
+}
@@ -0,0 +1,98 @@
+package worms
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+func TestSmoothedRates(t *testing.T) {
+	// Records ten seconds apart, with a pause between the third and fourth
+	t0 := time.Date(2022, 2, 13, 6, 0, 0, 0, time.UTC)
+	act := &parse.Activity{}
+	for _, s := range []int{0, 10, 20, 60, 70} {
+		act.Records = append(act.Records, &parse.Record{Timestamp: t0.Add(time.Duration(s) * time.Second)})
+	}
+
+	nan := math.NaN()
+	testCases := []struct {
+		ys, xs []float64
+		expect []float64
+	}{
+		{[]float64{0, 10, 20, 20, 30}, []float64{0, 10, 20, 60, 70}, []float64{1, 1, 1, 1, 1}},
+		{[]float64{0, 10, 40, 40, 40}, []float64{0, 10, 20, 60, 70}, []float64{1, 2, 3, 0, 0}},
+		{[]float64{0, 1, 2, 2, nan}, []float64{0, 10, 20, 20, 30}, []float64{0.1, 0.1, 0.1, nan, nan}},
+		{[]float64{0, 1, 2, 3, 4}, []float64{0, 10, 20, 20, 20}, []float64{0.1, 0.1, 0.1, nan, nan}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			actual := smoothedRates(act, testCase.ys, testCase.xs)
+			for j := range actual {
+				if a, e := actual[j], testCase.expect[j]; a != e && !(math.IsNaN(a) && math.IsNaN(e)) {
+					t.Fatal(actual, "!=", testCase.expect)
+				}
+			}
+		})
+	}
+}
+
+func TestColorScheme(t *testing.T) {
+	// Two activities a year apart, the second without elevations
+	t0 := time.Date(2021, 2, 13, 6, 0, 0, 0, time.UTC)
+	activities := []*parse.Activity{
+		{Sport: "running", Records: []*parse.Record{{Timestamp: t0, Elevation: 10}, {Timestamp: t0.Add(time.Minute), Elevation: 30}}},
+		{Sport: "cycling", Records: []*parse.Record{{Timestamp: t0.AddDate(1, 0, 0), Elevation: math.NaN()}}},
+	}
+	distances := [][]float64{{0, 100}, {0}}
+
+	nan := math.NaN()
+	testCases := []struct {
+		by         string
+		categories []string
+		expect     [][]float64
+	}{
+		{"sport", []string{"cycling", "running"}, [][]float64{{1, 1}, {0}}},
+		{"year", []string{"2021", "2022"}, [][]float64{{0, 0}, {1}}},
+		{"activity", []string{"2021-02-13", "2022-02-13"}, [][]float64{{0, 0}, {1}}},
+		{"elevation", nil, [][]float64{{0, 1}, {nan}}},
+		{"grade", nil, [][]float64{{1, 1}, {nan}}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			s := newColorScheme(testCase.by, activities, distances)
+			if fmt.Sprint(s.categories) != fmt.Sprint(testCase.categories) {
+				t.Fatal(s.categories, "!=", testCase.categories)
+			}
+			if fmt.Sprint(s.values) != fmt.Sprint(testCase.expect) {
+				t.Fatal(s.values, "!=", testCase.expect)
+			}
+		})
+	}
+
+	if s := newColorScheme("age", activities, distances); s != nil {
+		t.Fatal(s, "!=", nil)
+	}
+}
+
+func TestRendererColorBy(t *testing.T) {
+	dir := writeActivity(t)
+	for i, by := range ColorBys {
+		for _, truecolor := range []bool{false, true} {
+			t.Run(fmt.Sprintf("test case %d %v", i, truecolor), func(t *testing.T) {
+				opts := &Options{Input: []string{dir}, Width: 50, Frames: 3, FPS: 10, ColorDepth: 3, Speed: 1, Projection: "mercator", ColorBy: by, Truecolor: truecolor}
+				r := New(opts)
+				if _, err := r.Render(context.Background()); err != nil {
+					t.Fatal(err)
+				} else if frames := r.palettedFrames(); len(frames) != int(opts.Frames) {
+					t.Fatal(len(frames), "!=", opts.Frames)
+				}
+			})
+		}
+	}
+}
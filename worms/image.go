@@ -1,12 +1,11 @@
 package worms
 
 import (
-	"bufio"
-	"encoding/binary"
-	"hash/crc32"
 	"image"
 	"image/color"
-	"io"
+	"math"
+
+	"github.com/NathanBaulch/rainbow-roads/img"
 )
 
 // grays is a slice of 256 grayscale colors.
@@ -30,7 +29,11 @@ func drawFill(im *image.Paletted, ci uint8) {
 }
 
 // glowPlotter is a custom plotter for rendering glow effects on an image.
-type glowPlotter struct{ *image.Paletted }
+// The palette interleaves the levels of stride gradients, so color index ci is level ci/stride of gradient ci%stride.
+type glowPlotter struct {
+	*image.Paletted
+	stride int // The number of gradients interleaved in the palette
+}
 
 // Set sets the color at the specified position (x, y) on the image using a color.Color.
 func (p *glowPlotter) Set(x, y int, c color.Color) {
@@ -40,17 +43,22 @@ func (p *glowPlotter) Set(x, y int, c color.Color) {
 // SetColorIndex sets the color index at the specified position (x, y) on the image.
 func (p *glowPlotter) SetColorIndex(x, y int, ci uint8) {
 	// Adjust the neighboring pixels to create a glow effect
+	// The glow stays within the gradient of the color index, at dimmer levels
 	if p.setPixIfLower(x, y, ci) {
 		const sqrt2 = 1.414213562
-		if i := float64(ci) * sqrt2; i < float64(len(p.Palette)-2) {
-			ci = uint8(i)
+		levels := (len(p.Palette) - 2) / p.stride
+		level, g := int(ci)/p.stride, int(ci)%p.stride
+		if l := float64(level) * sqrt2; l < float64(levels) {
+			level = int(l)
+			ci = uint8(level*p.stride + g)
 			p.setPixIfLower(x-1, y, ci)
 			p.setPixIfLower(x, y-1, ci)
 			p.setPixIfLower(x+1, y, ci)
 			p.setPixIfLower(x, y+1, ci)
 		}
-		if i := float64(ci) * sqrt2; i < float64(len(p.Palette)-2) {
-			ci = uint8(i)
+		if l := float64(level) * sqrt2; l < float64(levels) {
+			level = int(l)
+			ci = uint8(level*p.stride + g)
 			p.setPixIfLower(x-1, y-1, ci)
 			p.setPixIfLower(x-1, y+1, ci)
 			p.setPixIfLower(x+1, y-1, ci)
@@ -74,6 +82,55 @@ func (p *glowPlotter) setPixIfLower(x, y int, ci uint8) bool {
 	return false
 }
 
+// attrPlotter is a custom plotter for rendering lines colored by attribute on an image with a palette made by shadedPalette,
+// where each color index is followed by the same color in a dimmer shade steps indexes later.
+type attrPlotter struct {
+	glowPlotter
+	steps uint8 // The number of colors in each shade of the palette
+}
+
+// Set sets the color at the specified position (x, y) on the image using a color.Color.
+func (p *attrPlotter) Set(x, y int, c color.Color) {
+	p.SetColorIndex(x, y, c.(color.Gray).Y)
+}
+
+// SetColorIndex sets the color index at the specified position (x, y) on the image,
+// with a glow of dimmer shades of the same color around it.
+// The white heads of the worms have no glow, since white has no shades.
+func (p *attrPlotter) SetColorIndex(x, y int, ci uint8) {
+	if p.setPixIfLower(x, y, ci) && ci > 0 {
+		if i := int(ci) + int(p.steps); i < len(p.Palette)-2 {
+			ci = uint8(i)
+			p.setPixIfLower(x-1, y, ci)
+			p.setPixIfLower(x, y-1, ci)
+			p.setPixIfLower(x+1, y, ci)
+			p.setPixIfLower(x, y+1, ci)
+		}
+		if i := int(ci) + int(p.steps); i < len(p.Palette)-2 {
+			ci = uint8(i)
+			p.setPixIfLower(x-1, y-1, ci)
+			p.setPixIfLower(x-1, y+1, ci)
+			p.setPixIfLower(x+1, y-1, ci)
+			p.setPixIfLower(x+1, y+1, ci)
+		}
+	}
+}
+
+// shade returns opaque color c with each channel scaled by k.
+func shade(c color.RGBA, k float64) color.RGBA {
+	return color.RGBA{R: uint8(float64(c.R)*k + 0.5), G: uint8(float64(c.G)*k + 0.5), B: uint8(float64(c.B)*k + 0.5), A: 0xff}
+}
+
+// whiten returns opaque color c blended towards white by k.
+func whiten(c color.RGBA, k float64) color.RGBA {
+	return color.RGBA{
+		R: uint8(float64(c.R) + float64(0xff-c.R)*k + 0.5),
+		G: uint8(float64(c.G) + float64(0xff-c.G)*k + 0.5),
+		B: uint8(float64(c.B) + float64(0xff-c.B)*k + 0.5),
+		A: 0xff,
+	}
+}
+
 // optimizeFrames optimizes the frames in the given slice of images.
 // It attempts to reduce redundancy between consecutive frames by
 // compressing repeated regions into transparent pixels or by cropping
@@ -143,123 +200,146 @@ func optimizeFrames(ims []*image.Paletted) {
 	}
 }
 
-// gifWriter is a custom writer for writing GIF files with additional comments.
-type gifWriter struct {
-	*bufio.Writer        // Underlying writer
-	Comment       string // Comment to be added to the GIF file
-	done          bool   // Flag indicating whether the writing process is complete
+// glowStrength is the intensity of a truecolor glow where it meets the line.
+const glowStrength = 0.5
+
+// rgbaFill fills an image with a specified opaque color.
+func rgbaFill(im *image.RGBA, c color.RGBA) {
+	if len(im.Pix) > 0 {
+		copy(im.Pix, []uint8{c.R, c.G, c.B, 0xff})
+		for i := 4; i < len(im.Pix); i *= 2 {
+			copy(im.Pix[i:], im.Pix[:i])
+		}
+	}
+}
+
+// aaPlotter draws anti-aliased lines with sub-pixel end points and a glow onto an RGBA image.
+// Every channel keeps its brightest value, so overlapping lines look the same whatever order they are drawn in.
+type aaPlotter struct {
+	*image.RGBA
+	halfWidth   float64 // Half the width of the lines in pixels
+	glowRadius  float64 // The distance the glow extends beyond the edge of the lines in pixels
+	glowFalloff float64 // The exponent of the decay of the glow with distance
 }
 
-// Write writes the contents of the byte slice to the writer.
-// It intercepts the application extension to insert the comment before writing.
-func (w *gifWriter) Write(p []byte) (nn int, err error) {
-	n := 0
-	if !w.done {
-		// Intercept application extension and insert comment
-		if len(p) == 3 && p[0] == 0x21 && p[1] == 0xff && p[2] == 0x0b {
-			// Write the comment extension
-			if n, err = w.writeExtension([]byte(w.Comment), 0xfe); err != nil {
-				return
-			} else {
-				nn += n
+// DrawLine draws a line in color c from (x0, y0) to (x1, y1), where pixel (x, y) covers the unit square from (x, y).
+func (p *aaPlotter) DrawLine(x0, y0, x1, y1 float64, c color.RGBA) {
+	// Only visit the pixels the line or its glow can reach
+	reach := p.halfWidth + p.glowRadius + 1
+	rect := image.Rect(
+		int(math.Floor(math.Min(x0, x1)-reach)), int(math.Floor(math.Min(y0, y1)-reach)),
+		int(math.Ceil(math.Max(x0, x1)+reach)), int(math.Ceil(math.Max(y0, y1)+reach)),
+	).Intersect(p.Rect)
+
+	dx, dy := x1-x0, y1-y0
+	l2 := dx*dx + dy*dy
+	outer := reach - 1
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			// Find the distance from the pixel center to the nearest point on the line, skipping pixels out of reach
+			cx, cy := float64(x)+0.5-x0, float64(y)+0.5-y0
+			t := 0.0
+			if l2 > 0 {
+				if t = (cx*dx + cy*dy) / l2; t < 0 {
+					t = 0
+				} else if t > 1 {
+					t = 1
+				}
+			}
+			ex, ey := cx-t*dx, cy-t*dy
+			d2 := ex*ex + ey*ey
+			if d2 >= (outer+0.5)*(outer+0.5) {
+				continue
+			}
+			d := math.Sqrt(d2)
+
+			// Cover the pixel by how much of it falls within the line, or by the glow beyond it
+			a := p.halfWidth + 0.5 - d
+			if a > 1 {
+				a = 1
+			}
+			if p.glowRadius > 0 && d > p.halfWidth {
+				if g := 1 - (d-p.halfWidth)/p.glowRadius; g > 0 {
+					if g = glowStrength * math.Pow(g, p.glowFalloff); g > a {
+						a = g
+					}
+				}
+			}
+			if a > 0 {
+				p.lighten(x, y, c, a)
 			}
-			w.done = true
 		}
 	}
-	// Write the content of the byte slice
-	if n, err = w.Writer.Write(p); err != nil {
-		return
-	} else {
-		nn += n
-	}
-	return
 }
 
-// writeExtension writes the comment extension into the GIF file.
-func (w *gifWriter) writeExtension(b []byte, e byte) (nn int, err error) {
-	n := 0
-	// Write the extension header
-	if n, err = w.Writer.Write([]byte{0x21, e, byte(len(b))}); err != nil {
-		return
-	} else {
-		nn += n
-	}
-	// Write the comment data
-	if n, err = w.Writer.Write(b); err != nil {
-		return
-	} else {
-		nn += n
-	}
-	// Write the extension terminator
-	if err = w.Writer.WriteByte(0); err != nil {
-		return
-	} else {
-		nn++
+// lighten raises each channel of the pixel at (x, y) to that of color c at coverage a, if brighter.
+func (p *aaPlotter) lighten(x, y int, c color.RGBA, a float64) {
+	i := p.PixOffset(x, y)
+	for j, v := range [3]uint8{c.R, c.G, c.B} {
+		if v := uint8(float64(v)*a + 0.5); v > p.Pix[i+j] {
+			p.Pix[i+j] = v
+		}
 	}
-	return
 }
 
-// pngWriter is a custom writer for writing PNG files with additional text metadata.
-type pngWriter struct {
-	io.Writer        // Underlying writer
-	Text      string // Text metadata to be added to the PNG file
-	done      bool   // Flag indicating whether the writing process is complete
-}
+// quantizePalette creates a palette of at most n colors for the truecolor frames drawn with the gradients, shared evenly between them.
+// Like the palette of paletted frames, it ends with black and transparent,
+// but the gradients are repeated at several intensities to approximate anti-aliased and glowing pixels.
+func quantizePalette(gradients []img.ColorGradient, n int) color.Palette {
+	// Trade gradient steps for intensity levels as the palette grows
+	levels := int(math.Max(1, math.Sqrt(float64(n-2)/4)))
+	steps := (n - 2) / levels / len(gradients)
+	if steps < 1 {
+		levels, steps = 0, 0
+	}
 
-// Write writes the contents of the byte slice to the writer.
-func (w *pngWriter) Write(p []byte) (nn int, err error) {
-	n := 0
-	if !w.done {
-		// Intercept the first data chunk and insert text metadata
-		if len(p) >= 8 && string(p[4:8]) == "IDAT" {
-			// Write the text metadata chunk
-			if n, err = w.writeChunk([]byte(w.Text), "tEXt"); err != nil {
-				return
-			} else {
-				nn += n
+	pal := make(color.Palette, 0, steps*levels*len(gradients)+2)
+	for l := 0; l < levels; l++ {
+		k := float64(levels-l) / float64(levels)
+		for _, colors := range gradients {
+			for s := 0; s < steps; s++ {
+				p := 1.0
+				if steps > 1 {
+					p = float64(s) / float64(steps-1)
+				}
+				c := color.RGBAModel.Convert(colors.GetColorAt(p)).(color.RGBA)
+				pal = append(pal, color.RGBA{R: uint8(float64(c.R) * k), G: uint8(float64(c.G) * k), B: uint8(float64(c.B) * k), A: 0xff})
 			}
-			w.done = true
 		}
 	}
-	// Write the content of the byte slice
-	if n, err = w.Writer.Write(p); err != nil {
-		return
-	} else {
-		nn += n
-	}
-	return
+	return append(pal, color.Black, color.Transparent)
 }
 
-// writeChunk writes the metadata chunk into the PNG file.
-func (w *pngWriter) writeChunk(b []byte, name string) (nn int, err error) {
-	header := make([]byte, 8)
-	binary.BigEndian.PutUint32(header, uint32(len(b)))
-	copy(header[4:], name)
-	// Calculate CRC checksum for the chunk
-	crc := crc32.NewIEEE()
-	_, _ = crc.Write(header[4:8])
-	_, _ = crc.Write(b)
-	footer := make([]byte, 4)
-	binary.BigEndian.PutUint32(footer, crc.Sum32())
-
-	// Write the chunk header
-	n := 0
-	if n, err = w.Writer.Write(header); err != nil {
-		return
-	} else {
-		nn += n
+// quantizeShades returns the evenly spaced intensities, from brightest to dimmest,
+// at which the colors of a palette of n colors are repeated to approximate anti-aliased and glowing pixels.
+func quantizeShades(n int) []float64 {
+	levels := int(math.Max(1, math.Sqrt(float64(n-3)/4)))
+	shades := make([]float64, levels)
+	for l := range shades {
+		shades[l] = float64(levels-l) / float64(levels)
 	}
-	// Write the chunk metadata
-	if n, err = w.Writer.Write(b); err != nil {
-		return
-	} else {
-		nn += n
-	}
-	// Write the chunk footer
-	if n, err = w.Writer.Write(footer); err != nil {
-		return
-	} else {
-		nn += n
+	return shades
+}
+
+// quantize converts truecolor frames to paletted frames using the opaque colors of pal,
+// finding the nearest palette color of each distinct frame color only once.
+func quantize(ims []*image.RGBA, pal color.Palette) []*image.Paletted {
+	opaque := pal[:len(pal)-1]
+	indexes := make(map[uint32]uint8)
+	paletted := make([]*image.Paletted, len(ims))
+	for i, im := range ims {
+		pm := image.NewPaletted(im.Rect, pal)
+		for j := 0; j < len(pm.Pix); j++ {
+			c := color.RGBA{R: im.Pix[j*4], G: im.Pix[j*4+1], B: im.Pix[j*4+2], A: 0xff}
+			k := uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+			ci, ok := indexes[k]
+			if !ok {
+				ci = uint8(opaque.Index(c))
+				indexes[k] = ci
+			}
+			pm.Pix[j] = ci
+		}
+		paletted[i] = pm
 	}
-	return
+	return paletted
 }
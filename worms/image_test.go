@@ -1,11 +1,13 @@
 package worms
 
 import (
-	"bufio"
 	"bytes"
+	"fmt"
 	"image"
 	"image/color"
 	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/img"
 )
 
 func TestImageOptimizeFrames(t *testing.T) {
@@ -48,31 +50,50 @@ func TestImageOptimizeFrames(t *testing.T) {
 	}
 }
 
-func TestImageGifWriter(t *testing.T) {
-	b := &bytes.Buffer{}
-	w := &gifWriter{Writer: bufio.NewWriter(b), Comment: "foo"}
-	if n, err := w.Write([]byte{0x21, 0xff, 0x0b}); err != nil {
-		t.Fatal(err)
-	} else if n != 10 {
-		t.Fatal("number of bytes written:", n, "!=", 10)
-	}
-	if err := w.Flush(); err != nil {
-		t.Fatal(err)
+func TestImageAAPlotterDrawLine(t *testing.T) {
+	testCases := []struct {
+		x0, y0, x1, y1 float64
+		width, glow    float64
+		expect         []uint8
+	}{
+		{0, 1.5, 3, 1.5, 1, 0, []uint8{0, 0, 0, 200, 200, 200, 0, 0, 0}},
+		{0, 1.5, 3, 1.5, 2, 0, []uint8{100, 100, 100, 200, 200, 200, 100, 100, 100}},
+		{0, 1, 3, 1, 1, 0, []uint8{100, 100, 100, 100, 100, 100, 0, 0, 0}},
+		{1.5, 1.5, 1.5, 1.5, 1, 0, []uint8{0, 0, 0, 0, 200, 0, 0, 0, 0}},
+		{0, 1.5, 3, 1.5, 1, 2, []uint8{75, 75, 75, 200, 200, 200, 75, 75, 75}},
 	}
-	if !bytes.Contains(b.Bytes(), []byte("foo")) {
-		t.Fatal("metadata text not found")
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			im := image.NewRGBA(image.Rect(0, 0, 3, 3))
+			p := &aaPlotter{RGBA: im, halfWidth: testCase.width / 2, glowRadius: testCase.glow, glowFalloff: 1}
+			p.DrawLine(testCase.x0, testCase.y0, testCase.x1, testCase.y1, color.RGBA{R: 200, A: 0xff})
+			actual := make([]uint8, 0, 9)
+			for j := 0; j < len(im.Pix); j += 4 {
+				actual = append(actual, im.Pix[j])
+			}
+			if !bytes.Equal(actual, testCase.expect) {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
 	}
 }
 
-func TestImagePngWriter(t *testing.T) {
-	b := &bytes.Buffer{}
-	w := &pngWriter{Writer: b, Text: "foo"}
-	if n, err := w.Write([]byte("    IDAT")); err != nil {
-		t.Fatal(err)
-	} else if n != 23 {
-		t.Fatal("number of bytes written:", n, "!=", 23)
+func TestImageQuantize(t *testing.T) {
+	var colors img.ColorGradient
+	_ = colors.Parse("#f00,#00f")
+	pal := quantizePalette([]img.ColorGradient{colors}, 32)
+	if len(pal) > 32 || pal[len(pal)-2] != color.Black || pal[len(pal)-1] != color.Transparent {
+		t.Fatal(len(pal), pal[len(pal)-2], pal[len(pal)-1])
 	}
-	if !bytes.Contains(b.Bytes(), []byte("foo")) {
-		t.Fatal("metadata text not found")
+
+	im := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	copy(im.Pix, []uint8{0, 0, 0, 0xff, 0xff, 0, 0, 0xff, 0x80, 0, 0, 0xff})
+	pm := quantize([]*image.RGBA{im}, pal)[0]
+	expects := []color.Color{color.Black, color.RGBA{R: 0xff, A: 0xff}, color.RGBA{R: 0x7f, A: 0xff}}
+	for i, expect := range expects {
+		if actual := pal[pm.Pix[i]]; actual != expect {
+			t.Fatal(i, actual, "!=", expect)
+		}
 	}
 }
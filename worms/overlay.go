@@ -0,0 +1,131 @@
+package worms
+
+import (
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/img"
+)
+
+// legendSteps is the number of gradient colors shown in a legend.
+const legendSteps = 5
+
+// overlayBlocks returns the text of every overlay of the options in the frame at percentage fpc.
+func (r *Renderer) overlayBlocks(fpc float64) []img.TextBlock {
+	blocks := make([]img.TextBlock, len(r.o.Overlays))
+	for i, ov := range r.o.Overlays {
+		b := &blocks[i]
+		b.Position = ov.Placement()
+		switch ov.Kind {
+		case "title":
+			b.Lines = []string{ov.Text}
+		case "date":
+			b.Lines = []string{r.dateLabel(fpc)}
+		case "totals":
+			count, dist := r.totalsAt(fpc)
+			b.Lines = []string{r.printer.Sprintf("%d %s", count, r.printer.Sprintf("activities")), conv.SprintDistance(r.printer, dist)}
+		case "legend":
+			b.Lines, b.Swatches = r.legend()
+		}
+	}
+	return blocks
+}
+
+// dateLabel returns the simulated time of the frame at percentage fpc:
+// the time of day in clock mode, the date of the latest record drawn in timeline mode,
+// and the time elapsed since the activities started otherwise.
+func (r *Renderer) dateLabel(fpc float64) string {
+	switch {
+	case r.o.Clock:
+		return clockLabel(fpc)
+	case r.o.Timeline:
+		var latest time.Time
+		for _, act := range r.activities {
+			for _, rec := range act.Records {
+				if rec.Percent > fpc {
+					break
+				}
+				if ts := act.Local(rec.Timestamp); ts.After(latest) {
+					latest = ts
+				}
+			}
+		}
+		if latest.IsZero() {
+			return ""
+		}
+		return latest.Format("2006-01-02")
+	default:
+		return conv.SprintDuration(r.printer, r.ageAt(fpc))
+	}
+}
+
+// totalsAt returns the number of activities started and the distance covered in meters in the frame at percentage fpc.
+func (r *Renderer) totalsAt(fpc float64) (int, float64) {
+	count, dist := 0, 0.0
+	for i, act := range r.activities {
+		// Find the last record reached
+		last := -1
+		for j, rec := range act.Records {
+			if rec.Percent > fpc {
+				break
+			}
+			last = j
+		}
+		if last >= 0 {
+			count++
+			dist += r.distances[i][last]
+		}
+	}
+	return count, dist
+}
+
+// legend returns the labels and colors of a legend of the attribute colors, of the head color of each sport with its own colors,
+// or of the gradient from the newest to the oldest lines when coloring by age.
+func (r *Renderer) legend() ([]string, []color.Color) {
+	if r.scheme != nil {
+		return r.scheme.legend(r.printer)
+	}
+	if r.sports.partitioned() {
+		labels := make([]string, len(r.sports.names))
+		swatches := make([]color.Color, len(r.sports.names))
+		for g, sport := range r.sports.names {
+			if labels[g] = sport; sport == "" {
+				labels[g] = r.printer.Sprintf("other sports")
+			}
+			swatches[g] = r.sports.gradients[g].GetColorAt(0)
+		}
+		return labels, swatches
+	}
+
+	labels := make([]string, legendSteps)
+	swatches := make([]color.Color, legendSteps)
+	for i := range labels {
+		p := float64(i) / float64(legendSteps-1)
+		swatches[i] = r.trailColor(r.o.Colors, p)
+		switch i {
+		case 0:
+			labels[i] = r.printer.Sprintf("now")
+		case legendSteps - 1:
+			labels[i] = r.printer.Sprintf("earlier")
+		default:
+			// Lines are colored by the square root of how far they have faded
+			if pc := r.unfade(p * p); r.o.Timeline && r.dayPercent > 0 {
+				labels[i] = r.printer.Sprintf("%d days ago", int(math.Round(pc/r.dayPercent)))
+			} else {
+				labels[i] = r.printer.Sprintf("%s ago", conv.SprintDuration(r.printer, r.ageAt(pc).Round(time.Minute)))
+			}
+		}
+	}
+	return labels, swatches
+}
+
+// ageAt returns the simulated time spanned by percentage pc of the animation:
+// a whole day in clock mode, or the longest activity slowed by the speed otherwise.
+func (r *Renderer) ageAt(pc float64) time.Duration {
+	if r.o.Clock {
+		return time.Duration(pc * float64(24*time.Hour))
+	}
+	return time.Duration(pc * r.o.Speed * float64(r.maxDur))
+}
@@ -0,0 +1,791 @@
+package worms
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/manifest"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/StephaneBunel/bresenham"
+	"github.com/kettek/apng"
+	"golang.org/x/exp/slices"
+	"golang.org/x/image/font"
+)
+
+// DefaultColors is the default color gradient string.
+const DefaultColors = "#fff,#ff8,#911,#414,#007@.5,#003"
+
+// DefaultEncoder is the default command that encodes a Y4M stream to mp4, padding odd dimensions as required by H.264.
+const DefaultEncoder = "ffmpeg -hide_banner -loglevel error -f yuv4mpegpipe -i - -vf pad=ceil(iw/2)*2:ceil(ih/2)*2 -c:v libx264 -pix_fmt yuv420p -movflags frag_keyframe+empty_moov -f mp4 -"
+
+// Formats lists the supported output formats.
+var Formats = []string{"gif", "png", "zip", "avi", "y4m", "mp4"}
+
+// Renderer renders the worms animation of a set of activities.
+// All the state of a render is kept in the Renderer, so multiple renders can run concurrently.
+type Renderer struct {
+	o          Options           // The options to use when rendering the animation
+	fullTitle  string            // The text for the watermark in the bottom-right corner
+	files      []*scan.File      // All the input files
+	activities []*parse.Activity // The filtered input activities
+	maxDur     time.Duration     // The duration of the longest included activity
+	extent     geo.Box           // A box enclosing all included activities
+	images     []image.Image     // A slice of all the images to animate, paletted or truecolor
+	printer    *conv.Printer     // The printer for the locale and units of the overlays
+	face       font.Face         // The font face of the overlays, or nil if there are none
+	distances  [][]float64       // The distance in meters covered by each record of each activity
+	dayPercent float64           // The percentage of the animation each day takes in timeline mode
+	scheme     *colorScheme      // The attribute colors of the records, or nil when coloring by age
+	sports     *sportGradients   // The gradients of the sports of the activities when coloring by age
+	trailSpan  float64           // The length of a tail, or the half-life of a decay, as a percentage of the animation
+	views      []view            // The view of each frame when the camera moves, or nil when every frame shows the whole map
+}
+
+// New creates a Renderer with a copy of the options.
+func New(opts *Options) *Renderer {
+	r := &Renderer{o: *opts}
+
+	// Construct the full title
+	r.fullTitle = "NathanBaulch/" + r.o.Title
+	if r.o.Version != "" {
+		r.fullTitle += " " + r.o.Version
+	}
+
+	// If no input was provided, the current directory is the input
+	if len(r.o.Input) == 0 {
+		r.o.Input = []string{"."}
+	}
+
+	// If no format was specified, save as gif
+	if r.o.Format == "" {
+		r.o.Format = "gif"
+	}
+
+	// If no encoder was specified, use ffmpeg
+	if r.o.Encoder == "" {
+		r.o.Encoder = DefaultEncoder
+	}
+
+	// If no progress reporter was specified, ignore progress
+	if r.o.Progress == nil {
+		r.o.Progress = progress.Nop
+	}
+
+	// If no line width or glow falloff was specified, draw one and a half pixel wide lines with a quadratic glow
+	if r.o.LineWidth == 0 {
+		r.o.LineWidth = 1.5
+	}
+	if r.o.GlowFalloff == 0 {
+		r.o.GlowFalloff = 2
+	}
+
+	// If no trail was specified, keep every line, otherwise fade over two seconds
+	if r.o.Trail == "" {
+		r.o.Trail = "persistent"
+	}
+	if r.o.TrailLength == 0 {
+		r.o.TrailLength = 2
+	}
+
+	// If no attribute was specified, color by age
+	if r.o.ColorBy == "" {
+		r.o.ColorBy = "age"
+	}
+
+	// If no colors were specified, use the default gradient
+	if len(r.o.Colors) == 0 {
+		_ = r.o.Colors.Parse(DefaultColors)
+	}
+
+	// If no locale was specified, format overlays in English
+	if r.o.Locale == "" {
+		r.o.Locale = "en"
+	}
+
+	// If no text size or color was specified, draw 16 pixel white overlays
+	if r.o.Text.Size == 0 {
+		r.o.Text.Size = 16
+	}
+	if r.o.Text.Color.A == 0 {
+		r.o.Text.Color = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	}
+
+	// Show the clock in clock mode, unless the date is already shown
+	if r.o.Clock && !slices.ContainsFunc(r.o.Overlays, func(ov img.Overlay) bool { return ov.Kind == "date" }) {
+		r.o.Overlays = append(r.o.Overlays[:len(r.o.Overlays):len(r.o.Overlays)], img.Overlay{Kind: "date"})
+	}
+
+	return r
+}
+
+// Render scans and parses the input activities and renders them into animation frames,
+// which are *image.RGBA in truecolor mode and *image.Paletted otherwise.
+// Rendering stops early with the context error once ctx is done.
+func (r *Renderer) Render(ctx context.Context) ([]image.Image, error) {
+	// Run each step of the rendering pipeline sequentially
+	for _, step := range []func(context.Context) error{r.scanStep, r.parseStep, r.renderStep} {
+		if err := step(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return r.images, nil
+}
+
+// scanStep scans the input paths for activity files.
+func (r *Renderer) scanStep(ctx context.Context) error {
+	if f, err := scan.Scan(ctx, r.o.Input); err != nil {
+		return err
+	} else {
+		r.files = f
+		if r.o.OnFiles != nil {
+			r.o.OnFiles(len(r.files))
+		}
+		return nil
+	}
+}
+
+// parseStep parses the files with the selector filters and keeps the filtered activities.
+func (r *Renderer) parseStep(ctx context.Context) error {
+	if a, st, err := r.o.Cache.Parse(ctx, r.files, &r.o.Selector, r.o.Progress); err != nil {
+		return err
+	} else {
+		r.activities = a
+		r.extent = st.Extent
+		r.maxDur = st.MaxDuration
+		if r.o.Manifest != nil {
+			if err := r.o.Manifest.Resolve(r.files, &r.o.Selector, st); err != nil {
+				return err
+			}
+		}
+		if r.o.OnStats != nil {
+			return r.o.OnStats(st)
+		}
+		return nil
+	}
+}
+
+// renderStep renders the activity data onto frames for animation.
+// It calculates the positions and percentages of activities and generates
+// frames based on the provided configuration.
+func (r *Renderer) renderStep(ctx context.Context) error {
+	o, activities := &r.o, r.activities
+
+	// Load the font and printer of the overlays
+	if len(o.Overlays) > 0 {
+		var err error
+		if r.printer, err = conv.NewPrinter(o.Locale, o.Units); err != nil {
+			return err
+		}
+		if r.face, err = o.Text.NewFace(); err != nil {
+			return err
+		}
+	}
+
+	// Sort activities if looping is enabled to ensure chronological order
+	if o.Loop {
+		sort.Slice(activities, func(i, j int) bool {
+			return activities[i].Records[0].Timestamp.Before(activities[j].Records[0].Timestamp)
+		})
+	}
+
+	// Fit the explicit viewport if provided, otherwise all the records
+	fit, err := geo.NewFit(o.Projection, o.Viewport, r.extent, o.Width, func(enclose func(pt geo.Point)) {
+		for _, act := range activities {
+			for _, rec := range act.Records {
+				enclose(rec.Position)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	proj, height := fit.Projection, fit.Height
+
+	// Refuse frames that take more memory than allowed, at 4 bytes per truecolor pixel
+	depth := 1
+	if o.Truecolor {
+		depth = 4
+	}
+	if err := img.CheckSize(int(o.Width), height, int(o.Frames), depth, o.MaxBytes); err != nil {
+		return err
+	}
+	// Create time scale based off of specified speed and the longest duration
+	tScale := 1 / (o.Speed * float64(r.maxDur))
+	r.trailSpan = o.TrailLength * float64(o.FPS) / float64(o.Frames)
+
+	// Start activities at their calendar offsets in timeline mode, at the pace of the timeline
+	var offsets []float64
+	if o.Timeline {
+		offsets, r.dayPercent, tScale = timelineOffsets(activities, o, r.maxDur)
+	}
+
+	// Load the time zone of activities without one in clock mode
+	var zone *time.Location
+	if o.Clock && o.TimeZone != "" {
+		if zone, err = time.LoadLocation(o.TimeZone); err != nil {
+			return err
+		}
+	}
+
+	// Project the record positions and scale their percentages by the time scale, keeping the distances
+	points := make([][]subpixel, len(activities))
+	r.distances = make([][]float64, len(activities))
+	for i, act := range activities {
+		ts0 := act.Records[0].Timestamp
+		tOffset := 0.0
+		if o.Loop {
+			tOffset = float64(i) / float64(len(activities))
+		} else if offsets != nil {
+			tOffset = offsets[i]
+		}
+		points[i] = make([]subpixel, len(act.Records))
+		for j, rec := range act.Records {
+			x, y := proj.Project(rec.Position.Unwrap(fit.Lon))
+			points[i][j] = subpixel{x: x, y: y}
+			if o.Clock {
+				rec.Percent = clockPercent(act, rec.Timestamp, zone)
+			} else {
+				rec.Percent = tOffset + float64(rec.Timestamp.Sub(ts0))*tScale
+			}
+		}
+		r.distances[i] = cumulativeDistances(act)
+	}
+
+	// Warn when the timeline runs past the last frame at the given day rate, rather than silently cutting it short
+	if o.Timeline && o.DayRate > 0 {
+		overflow, end := 0, 1.0
+		for _, act := range activities {
+			if pc := act.Records[len(act.Records)-1].Percent; pc > 1 {
+				overflow++
+				end = math.Max(end, pc)
+			}
+		}
+		if overflow > 0 && o.OnWarn != nil {
+			o.OnWarn(fmt.Sprintf("%d activities finish after the last frame, the timeline needs %d frames at this day rate", overflow, int(math.Ceil(end*float64(o.Frames)))))
+		}
+	}
+
+	// Frame the whole map, then move the camera across it if keyframed or following the activities
+	fitted := view{minX: fit.MinX, maxY: fit.MaxY, scale: fit.Scale}
+	r.views = r.cameraViews(proj, fit.Lon, points, fitted, float64(o.Width), float64(height))
+
+	// Measure the attribute the records are colored by, or find the gradient of each sport when coloring by age
+	r.scheme = newColorScheme(o.ColorBy, activities, r.distances)
+	r.sports = newSportGradients(activities, o.Colors, o.SportColors)
+
+	// Draw the frames in the color mode of the options
+	rect := image.Rect(0, 0, int(o.Width), height)
+	var images []image.Image
+	if o.Truecolor {
+		images, err = r.drawTruecolor(ctx, rect, points, fitted)
+	} else {
+		images, err = r.drawPaletted(ctx, rect, points, fitted)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Draw the overlays over every frame, one at a time since font faces are not safe for concurrent use
+	if r.face != nil {
+		for f, im := range images {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			img.DrawTextBlocks(im.(draw.Image), r.face, o.Text.Color, r.overlayBlocks(float64(f+1)/float64(o.Frames)))
+		}
+	}
+
+	r.images = images
+	return nil
+}
+
+// cumulativeDistances returns the distance in meters covered by each record of the activity,
+// scaled to the distance of the activity where it is known.
+func cumulativeDistances(act *parse.Activity) []float64 {
+	dists := make([]float64, len(act.Records))
+	for j := 1; j < len(act.Records); j++ {
+		dists[j] = dists[j-1] + act.Records[j-1].Position.DistanceTo(act.Records[j].Position)
+	}
+	if total := dists[len(dists)-1]; total > 0 && act.Distance > 0 {
+		for j := range dists {
+			dists[j] *= act.Distance / total
+		}
+	}
+	return dists
+}
+
+// subpixel is a position in an image in fractional pixels.
+type subpixel struct{ x, y float64 }
+
+// drawPaletted draws the activities onto paletted frames with aliased lines and a one pixel glow,
+// projecting the points of each activity record into each frame through its view.
+func (r *Renderer) drawPaletted(ctx context.Context, rect image.Rectangle, points [][]subpixel, fitted view) ([]image.Image, error) {
+	o := &r.o
+
+	// Create the color palette, with the attribute colors in the shades of a one pixel glow when coloring by attribute
+	var pal color.Palette
+	steps := 0
+	if r.scheme != nil {
+		pal, steps = r.scheme.shadedPalette(1<<o.ColorDepth, []float64{1, 1 / math.Sqrt2, 0.5})
+	} else {
+		// Interleave the levels of the gradient of each sport, so newer lines are drawn over older ones whatever their sport
+		steps = len(r.sports.gradients)
+		levels := (1<<o.ColorDepth - 2) / steps
+		if levels < 1 {
+			levels = 1
+		}
+		pal = make([]color.Color, levels*steps+2)
+		for l := 0; l < levels; l++ {
+			p := 0.0
+			if levels > 1 {
+				p = float64(l) / float64(levels-1)
+			}
+			for g, colors := range r.sports.gradients {
+				pal[l*steps+g] = r.trailColor(colors, p)
+			}
+		}
+		pal[len(pal)-2] = color.Black
+		pal[len(pal)-1] = color.Transparent
+	}
+
+	// Initialize all the frames with a background color and optional watermark
+	images := make([]*image.Paletted, o.Frames)
+	for i := range images {
+		im := image.NewPaletted(rect, pal)
+		if i == 0 {
+			drawFill(im, uint8(len(pal)-2))
+			if !o.NoWatermark {
+				img.DrawWatermark(im, r.fullTitle, pal[len(pal)/2])
+			}
+		} else {
+			copy(im.Pix, images[0].Pix)
+		}
+		images[i] = im
+	}
+
+	// Draw every frame concurrently
+	err := r.eachFrame(ctx, func(f uint, fpc float64) {
+		var p bresenham.Plotter = &glowPlotter{Paletted: images[f], stride: steps}
+		if r.scheme != nil {
+			p = &attrPlotter{glowPlotter: glowPlotter{Paletted: images[f]}, steps: uint8(steps)}
+		}
+		v := r.frameView(f, fitted)
+		for i, act := range r.activities {
+			var x0, y0 int
+			for j, rec := range act.Records {
+				// Calculate the percentage progress of the record, stopping at records yet to start
+				pc, ok := r.segmentProgress(fpc, rec)
+				if !ok {
+					break
+				}
+
+				// Render the line segment if it's different from the previous one, not entirely off-screen and not faded out
+				pt := v.pixel(points[i][j])
+				x1, y1 := int(pt.x), int(pt.y)
+				a, k := r.fade(pc)
+				if j > 0 && (x1 != x0 || y1 != y0) && !offscreen(x0, y0, x1, y1, images[f].Rect) && k > 0 {
+					// Determine the color index based on the attribute in the nearest shade to the intensity,
+					// or the faded progress when coloring by age
+					var ci uint8
+					if r.scheme != nil {
+						if pc >= headPercent {
+							level := int(math.Min(math.Round(2*math.Log2(1/k)), float64((len(pal)-3)/steps-1)))
+							ci = uint8(1 + level*steps + r.scheme.baseIndex(r.scheme.values[i][j], steps-1))
+						}
+					} else {
+						levels := (len(pal) - 2) / steps
+						level := int(math.Min(math.Sqrt(a)*float64(levels), float64(levels-1)))
+						ci = uint8(level*steps + r.sports.index[i])
+					}
+
+					// Draw the line segment
+					bresenham.DrawLine(p, x0, y0, x1, y1, grays[ci])
+				}
+
+				// Update the previous pixel
+				x0, y0 = x1, y1
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]image.Image, len(images))
+	for i, im := range images {
+		frames[i] = im
+	}
+	return frames, nil
+}
+
+// drawTruecolor draws the activities onto RGBA frames with anti-aliased lines between the sub-pixel points
+// of each activity record, projected into each frame through its view, using the line width and glow of the options.
+func (r *Renderer) drawTruecolor(ctx context.Context, rect image.Rectangle, points [][]subpixel, fitted view) ([]image.Image, error) {
+	o := &r.o
+
+	// Look up the colors of the gradient of each sport in a table
+	gradients := make([][]color.RGBA, len(r.sports.gradients))
+	for g, colors := range r.sports.gradients {
+		gradients[g] = make([]color.RGBA, 1024)
+		for i := range gradients[g] {
+			gradients[g][i] = r.trailColor(colors, float64(i)/float64(len(gradients[g])-1))
+		}
+	}
+
+	// Look up the exact colors of the records when coloring by attribute
+	var colors [][]color.RGBA
+	if r.scheme != nil {
+		colors = r.scheme.recordColors()
+	}
+
+	// Initialize all the frames with a background color and optional watermark
+	images := make([]*image.RGBA, o.Frames)
+	for i := range images {
+		im := image.NewRGBA(rect)
+		if i == 0 {
+			rgbaFill(im, color.RGBA{A: 0xff})
+			if !o.NoWatermark {
+				img.DrawWatermark(im, r.fullTitle, gradients[0][len(gradients[0])/2])
+			}
+		} else {
+			copy(im.Pix, images[0].Pix)
+		}
+		images[i] = im
+	}
+
+	// Draw every frame concurrently
+	err := r.eachFrame(ctx, func(f uint, fpc float64) {
+		ap := &aaPlotter{RGBA: images[f], halfWidth: o.LineWidth / 2, glowRadius: o.GlowRadius, glowFalloff: o.GlowFalloff}
+		v := r.frameView(f, fitted)
+		for i, act := range r.activities {
+			var p0 subpixel
+			for j, rec := range act.Records {
+				// Calculate the percentage progress of the record, stopping at records yet to start
+				pc, ok := r.segmentProgress(fpc, rec)
+				if !ok {
+					break
+				}
+
+				// Render the line segment if it's different from the previous one and not faded out
+				p1 := v.pixel(points[i][j])
+				a, k := r.fade(pc)
+				if j > 0 && p1 != p0 && k > 0 {
+					// Determine the color based on the attribute with a white head dimmed by the intensity,
+					// or the gradient position based on the faded progress
+					var c color.RGBA
+					if colors != nil {
+						c = colors[i][j]
+						if pc < headPercent {
+							c = whiten(c, 1-pc/headPercent)
+						}
+						if k < 1 {
+							c = shade(c, k)
+						}
+					} else {
+						p := math.Sqrt(a)
+						gradient := gradients[r.sports.index[i]]
+						c = gradient[int(p*float64(len(gradient)-1))]
+					}
+
+					// Draw the line segment
+					ap.DrawLine(p0.x, p0.y, p1.x, p1.y, c)
+				}
+
+				// Update the previous point
+				p0 = p1
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]image.Image, len(images))
+	for i, im := range images {
+		frames[i] = im
+	}
+	return frames, nil
+}
+
+// eachFrame calls draw concurrently for every frame with its index and percentage progress through the animation.
+// Frames are skipped once ctx is done, in which case the context error is returned.
+func (r *Renderer) eachFrame(ctx context.Context, draw func(f uint, fpc float64)) error {
+	o := &r.o
+
+	// Create a WaitGroup to wait for all goroutines to finish
+	wg := &sync.WaitGroup{}
+	wg.Add(int(o.Frames))
+	o.Progress.Start("rendering", int64(o.Frames))
+	// Process all frames in synchronously
+	for f := uint(0); f < o.Frames; f++ {
+		f := f
+		go func() {
+			// Signal the WaitGroup that this goroutine is done
+			defer wg.Done()
+			// Skip the frame if rendering was cancelled
+			if ctx.Err() != nil {
+				return
+			}
+			// Draw the frame at the percentage progress of the current frame in the animation
+			draw(f, float64(f+1)/float64(o.Frames))
+			o.Progress.Add(1)
+		}()
+	}
+	// Wait for all goroutines to finish
+	wg.Wait()
+	o.Progress.Finish()
+	return ctx.Err()
+}
+
+// segmentProgress returns the percentage progress of record rec in the frame at percentage fpc,
+// or false if the record has not been reached yet and looping is disabled.
+func (r *Renderer) segmentProgress(fpc float64, rec *parse.Record) (float64, bool) {
+	pc := fpc - rec.Percent
+
+	// Adjust percentage if it's negative and looping is enabled
+	if pc < 0 {
+		if !r.o.Loop {
+			return 0, false
+		}
+		pc++
+	}
+	return pc, true
+}
+
+// frameView returns the view of frame f, which is the fitted view unless the camera moves.
+func (r *Renderer) frameView(f uint, fitted view) view {
+	if r.views == nil {
+		return fitted
+	}
+	return r.views[f]
+}
+
+// offscreen returns true if the line segment between pixels (x0, y0) and (x1, y1) falls entirely to one side of rect.
+func offscreen(x0, y0, x1, y1 int, rect image.Rectangle) bool {
+	return (x0 < rect.Min.X && x1 < rect.Min.X) || (x0 >= rect.Max.X && x1 >= rect.Max.X) ||
+		(y0 < rect.Min.Y && y1 < rect.Min.Y) || (y0 >= rect.Max.Y && y1 >= rect.Max.Y)
+}
+
+// Save encodes the rendered frames to w in the format of the options.
+// Encoding stops early with the context error once ctx is done.
+func (r *Renderer) Save(ctx context.Context, w io.Writer) error {
+	if r.images == nil {
+		return errors.New("nothing rendered")
+	}
+
+	// Report the encoded bytes and check for cancellation on every write
+	r.o.Progress.Start("encoding", 0)
+	defer r.o.Progress.Finish()
+	w = progress.NewWriter(ctx, w, r.o.Progress)
+
+	// Depending on the save format, save appropriately
+	switch r.o.Format {
+	case "gif":
+		return r.saveGIF(w)
+	case "png":
+		return r.savePNG(w)
+	case "zip":
+		return r.saveZIP(w)
+	case "avi":
+		return img.EncodeAVI(w, r.images, r.o.FPS, r.fullTitle)
+	case "y4m":
+		return img.EncodeY4M(w, r.images, r.o.FPS)
+	case "mp4":
+		return r.saveEncoded(ctx, w)
+	default:
+		return fmt.Errorf("format %q not supported", r.o.Format)
+	}
+}
+
+// palettedFrames returns paletted copies of the rendered frames, leaving the frames returned by Render intact.
+// Truecolor frames are quantized to a palette with the color depth of the options.
+func (r *Renderer) palettedFrames() []*image.Paletted {
+	if r.o.Truecolor {
+		ims := make([]*image.RGBA, len(r.images))
+		for i, im := range r.images {
+			ims[i] = im.(*image.RGBA)
+		}
+		pal := quantizePalette(r.sports.gradients, 1<<r.o.ColorDepth)
+		if r.scheme != nil {
+			pal, _ = r.scheme.shadedPalette(1<<r.o.ColorDepth, quantizeShades(1<<r.o.ColorDepth))
+		}
+		return quantize(ims, pal)
+	}
+
+	images := make([]*image.Paletted, len(r.images))
+	for i, im := range r.images {
+		pm := im.(*image.Paletted)
+		images[i] = &image.Paletted{Pix: append([]uint8(nil), pm.Pix...), Stride: pm.Stride, Rect: pm.Rect, Palette: pm.Palette}
+	}
+	return images
+}
+
+// optimizedFrames returns optimized paletted copies of the rendered frames, leaving the frames returned by Render intact.
+func (r *Renderer) optimizedFrames() []*image.Paletted {
+	images := r.palettedFrames()
+	optimizeFrames(images)
+	return images
+}
+
+// saveEncoded pipes the worms as a Y4M stream to the encoder command and copies its output to w.
+// The encoder is killed once ctx is done.
+func (r *Renderer) saveEncoded(ctx context.Context, w io.Writer) error {
+	args := strings.Fields(r.o.Encoder)
+	if len(args) == 0 {
+		return errors.New("no encoder command")
+	}
+
+	// Start the encoder, keeping its error output for the error message
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = w
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("encoder: %w", err)
+	}
+
+	// Feed it every frame, then wait for it to finish writing
+	err = img.EncodeY4M(stdin, r.images, r.o.FPS)
+	if cerr := stdin.Close(); err == nil {
+		err = cerr
+	}
+	if werr := cmd.Wait(); werr != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("encoder: %w: %s", werr, msg)
+		}
+		return fmt.Errorf("encoder: %w", werr)
+	}
+	return err
+}
+
+// saveGIF save the worms to w as a gif.
+func (r *Renderer) saveGIF(w io.Writer) error {
+	// Optimize frames to reduce file size
+	images := r.optimizedFrames()
+
+	// Initialize gif
+	g := &gif.GIF{
+		Image:    images,
+		Delay:    make([]int, len(images)),
+		Disposal: make([]byte, len(images)),
+		Config: image.Config{
+			ColorModel: images[0].Palette,
+			Width:      images[0].Rect.Max.X,
+			Height:     images[0].Rect.Max.Y,
+		},
+	}
+
+	// Convert "frames per second" to "100s of seconds per frame"
+	d := int(math.Round(100 / float64(r.o.FPS)))
+
+	// Set delay and disposal method of each frame
+	for i := range images {
+		g.Disposal[i] = gif.DisposalNone
+		g.Delay[i] = d
+	}
+
+	// Embed the title and manifest as comments
+	comments := []string{r.fullTitle}
+	if r.o.Manifest != nil {
+		if m, err := r.o.Manifest.Marshal(); err != nil {
+			return err
+		} else {
+			comments = append(comments, m)
+		}
+	}
+
+	// Save all the frames of the gif to the file
+	return gif.EncodeAll(&img.GIFWriter{Writer: bufio.NewWriter(w), Comments: comments}, g)
+}
+
+// savePNG save the worms to w as a png.
+func (r *Renderer) savePNG(w io.Writer) error {
+	// Optimize paletted frames to reduce file size, while truecolor frames keep every color
+	images := r.images
+	if !r.o.Truecolor {
+		images = nil
+		for _, im := range r.optimizedFrames() {
+			images = append(images, im)
+		}
+	}
+
+	// Initialize png
+	a := apng.APNG{Frames: make([]apng.Frame, len(images))}
+
+	// Set each frame
+	for i, im := range images {
+		a.Frames[i].Image = im
+		a.Frames[i].XOffset = im.Bounds().Min.X
+		a.Frames[i].YOffset = im.Bounds().Min.Y
+		a.Frames[i].BlendOp = apng.BLEND_OP_OVER
+		a.Frames[i].DelayNumerator = 1
+		a.Frames[i].DelayDenominator = uint16(r.o.FPS)
+	}
+
+	// Embed the title and compressed manifest as text
+	texts := []img.PNGText{{Keyword: "Software", Text: r.fullTitle}}
+	if r.o.Manifest != nil {
+		if m, err := r.o.Manifest.Marshal(); err != nil {
+			return err
+		} else {
+			texts = append(texts, img.PNGText{Keyword: manifest.Keyword, Text: m, Compressed: true})
+		}
+	}
+
+	// Save the apng to the file
+	return apng.Encode(&img.PNGWriter{Writer: w, Texts: texts}, a)
+}
+
+// saveZIP save the worms to w as a zip of gifs.
+func (r *Renderer) saveZIP(w io.Writer) error {
+	z := zip.NewWriter(w)
+
+	// Add every image to the zip as a gif
+	for i, im := range r.palettedFrames() {
+		if w, err := z.Create(fmt.Sprintf("%d.gif", i)); err != nil {
+			return err
+		} else if err = gif.Encode(w, im, nil); err != nil {
+			return err
+		}
+	}
+
+	// Add the manifest alongside the frames
+	if r.o.Manifest != nil {
+		if m, err := r.o.Manifest.Marshal(); err != nil {
+			return err
+		} else if w, err := z.Create(manifest.ZIPName); err != nil {
+			return err
+		} else if _, err = io.WriteString(w, m); err != nil {
+			return err
+		}
+	}
+
+	return z.Close()
+}
@@ -0,0 +1,246 @@
+package worms
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/img"
+)
+
+// TestMain runs the test binary as a stand-in video encoder when WORMS_TEST_ENCODER is set,
+// reporting the Y4M stream read from standard input on standard output.
+func TestMain(m *testing.M) {
+	switch os.Getenv("WORMS_TEST_ENCODER") {
+	case "":
+		os.Exit(m.Run())
+	case "fail":
+		fmt.Fprintln(os.Stderr, "encoder failed")
+		os.Exit(1)
+	default:
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			os.Exit(2)
+		}
+		header, _, _ := strings.Cut(string(b), "\n")
+		fmt.Printf("%s %d frames", header, strings.Count(string(b), "FRAME\n"))
+		os.Exit(0)
+	}
+}
+
+// writeActivity writes a short GPX activity to a new temporary directory and returns the directory.
+func writeActivity(t *testing.T) string {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.gpx"), []byte(`
+		<gpx>
+		  <trk>
+		    <type>running</type>
+		    <trkseg>
+		      <trkpt lat="-37.80" lon="144.90"><time>2022-02-13T00:00:00Z</time></trkpt>
+		      <trkpt lat="-37.81" lon="144.91"><time>2022-02-13T00:01:00Z</time></trkpt>
+		      <trkpt lat="-37.82" lon="144.90"><time>2022-02-13T00:02:00Z</time></trkpt>
+		      <trkpt lat="-37.83" lon="144.92"><time>2022-02-13T00:03:00Z</time></trkpt>
+		    </trkseg>
+		  </trk>
+		</gpx>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRendererConcurrent(t *testing.T) {
+	dir := writeActivity(t)
+
+	testCases := []struct {
+		width     uint
+		frames    uint
+		truecolor bool
+	}{
+		{50, 3, false},
+		{80, 5, true},
+		{120, 2, false},
+	}
+
+	wg := &sync.WaitGroup{}
+	errs := make([]error, len(testCases))
+	for i, testCase := range testCases {
+		wg.Add(1)
+		go func(i int, width, frames uint, truecolor bool) {
+			defer wg.Done()
+			opts := &Options{Input: []string{dir}, Width: width, Frames: frames, FPS: 10, ColorDepth: 3, Speed: 1, Projection: "mercator", Truecolor: truecolor, GlowRadius: 1}
+			var files int
+			opts.OnFiles = func(n int) { files = n }
+
+			r := New(opts)
+			if ims, err := r.Render(context.Background()); err != nil {
+				errs[i] = err
+			} else if files != 1 {
+				errs[i] = fmt.Errorf("files: %d != %d", files, 1)
+			} else if len(ims) != int(frames) || ims[0].Bounds().Dx() != int(width) {
+				errs[i] = fmt.Errorf("frames: %d of %v != %d of width %d", len(ims), ims[0].Bounds(), frames, width)
+			} else {
+				b := &bytes.Buffer{}
+				if err := r.Save(context.Background(), b); err != nil {
+					errs[i] = err
+				} else if g, err := gif.DecodeAll(bytes.NewReader(b.Bytes())); err != nil {
+					errs[i] = err
+				} else if len(g.Image) != int(frames) {
+					errs[i] = fmt.Errorf("saved frames: %d != %d", len(g.Image), frames)
+				} else if comments, err := img.ReadGIFComments(b); err != nil || len(comments) != 1 {
+					errs[i] = fmt.Errorf("comments: %v %v", comments, err)
+				}
+			}
+		}(i, testCase.width, testCase.frames, testCase.truecolor)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRendererCancelled(t *testing.T) {
+	opts := &Options{Input: []string{writeActivity(t)}, Width: 50, Frames: 3, FPS: 10, ColorDepth: 3, Speed: 1, Projection: "mercator"}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := New(opts)
+	if _, err := r.Render(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if err := r.Save(ctx, &bytes.Buffer{}); !errors.Is(err, context.Canceled) {
+		t.Fatal(err, "!=", context.Canceled)
+	}
+	if _, err := New(opts).Render(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatal(err, "!=", context.Canceled)
+	}
+}
+
+func TestRendererEncoder(t *testing.T) {
+	opts := &Options{Input: []string{writeActivity(t)}, Width: 50, Frames: 3, FPS: 15, ColorDepth: 3, Speed: 1, Projection: "mercator", Format: "mp4", Encoder: os.Args[0]}
+	r := New(opts)
+	if _, err := r.Render(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		mode   string
+		expect string
+		err    string
+	}{
+		{"ok", "YUV4MPEG2 W50 H94 F15:1 Ip A1:1 C420jpeg XCOLORRANGE=FULL 3 frames", ""},
+		{"fail", "", "encoder: exit status 1: encoder failed"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			t.Setenv("WORMS_TEST_ENCODER", testCase.mode)
+			b := &bytes.Buffer{}
+			err := r.Save(context.Background(), b)
+			if actual := fmt.Sprint(err); testCase.err != "" && actual != testCase.err {
+				t.Fatal(actual, "!=", testCase.err)
+			} else if testCase.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if actual := b.String(); actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+func TestRendererTruecolor(t *testing.T) {
+	opts := &Options{Input: []string{writeActivity(t)}, Width: 50, Frames: 3, FPS: 10, ColorDepth: 3, Speed: 1, Projection: "mercator", Format: "png", Truecolor: true, LineWidth: 2, GlowRadius: 2}
+	r := New(opts)
+	ims, err := r.Render(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last frame is drawn with more colors than the palette of the color depth
+	colors := map[color.RGBA]bool{}
+	last := ims[len(ims)-1].(*image.RGBA)
+	for i := 0; i < len(last.Pix); i += 4 {
+		colors[color.RGBA{R: last.Pix[i], G: last.Pix[i+1], B: last.Pix[i+2], A: last.Pix[i+3]}] = true
+	}
+	if len(colors) <= 1<<opts.ColorDepth {
+		t.Fatal(len(colors), "<=", 1<<opts.ColorDepth)
+	}
+
+	// The saved animation keeps every color
+	b := &bytes.Buffer{}
+	if err := r.Save(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	if im, err := png.Decode(b); err != nil {
+		t.Fatal(err)
+	} else if _, ok := im.(*image.RGBA); !ok {
+		t.Fatal(im.ColorModel(), "!=", color.RGBAModel)
+	}
+}
+
+func TestRendererClock(t *testing.T) {
+	opts := &Options{Input: []string{writeActivity(t)}, Width: 100, Frames: 2, FPS: 10, ColorDepth: 3, Speed: 1, Projection: "mercator", Clock: true, NoWatermark: true}
+	ims, err := New(opts).Render(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The clock in the top left corner differs between 12:00 and 24:00
+	corner := image.Rect(0, 0, 45, 20)
+	same := true
+	for y := corner.Min.Y; y < corner.Max.Y; y++ {
+		for x := corner.Min.X; x < corner.Max.X; x++ {
+			if ims[0].At(x, y) != ims[1].At(x, y) {
+				same = false
+			}
+		}
+	}
+	if same {
+		t.Fatal("clock not drawn")
+	}
+}
+
+func TestRendererOverlays(t *testing.T) {
+	opts := &Options{Input: []string{writeActivity(t)}, Width: 200, Frames: 2, FPS: 10, ColorDepth: 3, Speed: 1, Projection: "mercator", NoWatermark: true}
+	plain, err := New(opts).Render(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Overlays = []img.Overlay{{Kind: "totals"}, {Kind: "legend", Position: "top_right"}}
+	ims, err := New(opts).Render(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each overlay changes its corner of the last frame
+	b := ims[1].Bounds()
+	for _, corner := range []image.Rectangle{image.Rect(0, b.Max.Y-40, 60, b.Max.Y), image.Rect(b.Max.X-80, 0, b.Max.X, 80)} {
+		same := true
+		for y := corner.Min.Y; y < corner.Max.Y; y++ {
+			for x := corner.Min.X; x < corner.Max.X; x++ {
+				if ims[1].At(x, y) != plain[1].At(x, y) {
+					same = false
+				}
+			}
+		}
+		if same {
+			t.Fatal("overlay not drawn in", corner)
+		}
+	}
+}
@@ -1,76 +1,90 @@
 package worms
 
 import (
-	"archive/zip"
-	"bufio"
+	"context"
 	"errors"
 	"fmt"
-	"image"
-	"image/color"
-	"image/gif"
 	"io"
 	"io/fs"
-	"log"
-	"math"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/NathanBaulch/rainbow-roads/conv"
 	"github.com/NathanBaulch/rainbow-roads/geo"
 	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/manifest"
 	"github.com/NathanBaulch/rainbow-roads/parse"
-	"github.com/NathanBaulch/rainbow-roads/scan"
-	"github.com/StephaneBunel/bresenham"
-	"github.com/kettek/apng"
-	"golang.org/x/text/language"
-	"golang.org/x/text/message"
-)
-
-var (
-	o          *Options                               // The options to use when painting the image
-	fullTitle  string                                 // The text for the watermark in the bottom-right corner
-	en         = message.NewPrinter(language.English) // The printer to ouput text to the command line
-	files      []*scan.File                           // All the input files
-	activities []*parse.Activity                      // The filtered input activities
-	maxDur     time.Duration                          // The duration of the longest included activity
-	extent     geo.Box                                // A box enclosing all included activities
-	images     []*image.Paletted                      // A slice of all the images to animate
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/stats"
 )
 
+// Options are the options of the worms animation.
 type Options struct {
-	Title       string            // The title of this program
-	Version     string            // The version of this program
-	Input       []string          // The paths of the input files
-	Output      string            // The path of the output file
-	Width       uint              // The width of the output image in pixels
-	Frames      uint              // The number of animation frames
-	FPS         uint              // The framerate the animation
-	Format      string            // The output file format string, supports gif, png, zip
-	Colors      img.ColorGradient // The color gradient
-	ColorDepth  uint              // The number of bits per color in the image palette
-	Speed       float64           // How quickly activities progress
-	Loop        bool              // If true activities start sequentially and loop continuously; otherwise, all activities start at the same time
-	NoWatermark bool              // Whether the watermark is drawn
-	Selector    parse.Selector    // The filters specifying which activities to use
+	Title       string                      // The title of this program
+	Version     string                      // The version of this program
+	Input       []string                    // The paths of the input files
+	Output      string                      // The path of the output file
+	Width       uint                        // The width of the output image in pixels
+	Frames      uint                        // The number of animation frames
+	FPS         uint                        // The framerate the animation
+	Format      string                      // The output file format string, supports gif, png, zip, avi, y4m, mp4
+	Encoder     string                      // The command that encodes a Y4M stream on standard input to mp4 on standard output
+	Colors      img.ColorGradient           // The color gradient
+	SportColors SportColors                 // The color gradient of each sport when coloring by age, keyed case-insensitively, other sports use Colors
+	ColorDepth  uint                        // The number of bits per color in the image palette, which truecolor frames are only quantized to when saved as gif or zip
+	ColorBy     string                      // The record attribute the worms are colored by, see ColorBys, defaults to age
+	Truecolor   bool                        // Whether to render full color frames with anti-aliased lines; otherwise, paletted frames with aliased lines
+	LineWidth   float64                     // The width of the lines in pixels in truecolor mode, defaults to 1.5
+	GlowRadius  float64                     // The distance the glow extends beyond the lines in pixels in truecolor mode, or 0 for no glow
+	GlowFalloff float64                     // The exponent of the decay of the glow with distance in truecolor mode, defaults to 2
+	Speed       float64                     // How quickly activities progress
+	Projection  string                      // The name of the map projection, see geo.ProjectionNames
+	Viewport    geo.Box                     // The explicit region to render; otherwise, the extent of all activities
+	Camera      Camera                      // The keyframed viewports the camera pans and zooms between, or nil for a fixed camera
+	Follow      float64                     // The zoom of a camera that follows the densest group of moving worms, or 0 for a fixed camera
+	Trail       string                      // How old lines fade, see Trails, defaults to persistent
+	TrailLength float64                     // The length of a tail, or the half-life of a decay, in seconds of animation, defaults to 2
+	Ghost       float64                     // The intensity old lines fade to in the tail and decay modes, from 0 for gone to 1 for undimmed
+	Loop        bool                        // If true activities start sequentially and loop continuously; otherwise, all activities start at the same time
+	Timeline    bool                        // If true activities start in calendar order at offsets matching their start dates
+	DayRate     float64                     // The number of days of history animated per second in timeline mode, or 0 to fit the whole history into the animation
+	MaxGap      time.Duration               // The longest idle period between activity starts in timeline mode, longer periods are shortened to it, or 0 to keep every period
+	Clock       bool                        // If true activities are placed by their local time of day, animating one day from 00:00 to 24:00 with a date overlay showing the time
+	TimeZone    string                      // The IANA time zone of activities whose files don't record one in clock mode, eg Australia/Melbourne, or empty to estimate it from their longitude
+	Overlays    []img.Overlay               // The overlays drawn over every frame
+	Text        img.TextStyle               // The font, size and color of the overlays, defaults to 16 pixel white Go Regular
+	NoWatermark bool                        // Whether the watermark is drawn
+	Selector    parse.Selector              // The filters specifying which activities to use
+	StatsFormat string                      // The format of the printed stats, supports text, json, yaml
+	StatsFile   string                      // The path of the file to write stats to, or empty for standard output
+	Locale      string                      // The BCP 47 language tag used to format text output
+	Units       string                      // The system of measurement used in text output, or empty for the locale default
+	Manifest    *manifest.Manifest          // The render manifest to embed in the output, or nil for none
+	Cache       *parse.Cache                // The cache of previously parsed files, or nil to parse every file
+	MaxBytes    uint64                      // The most memory the frames may take in bytes, or 0 for no limit
+	OnFiles     func(n int)                 // Called with the number of input files found, if not nil
+	OnStats     func(st *parse.Stats) error // Called with the stats of the included activities, if not nil
+	OnWarn      func(msg string)            // Called with a warning about the render that doesn't stop it, if not nil
+	Progress    progress.Reporter           // Receives the progress of each stage, or nil to ignore progress
 }
 
-// Run executes all the steps needed to genetate the worms animation.
-func Run(opts *Options) error {
-	// Copy the options
-	o = opts
+// Run renders the worms animation and saves it to the output file, printing progress messages and stats.
+// Once ctx is done the render stops and any partially written output file is removed.
+func Run(ctx context.Context, opts *Options) error {
+	o := *opts
 
-	// Construct the full title
-	fullTitle = "NathanBaulch/" + o.Title
-	if o.Version != "" {
-		fullTitle += " " + o.Version
+	// Create the printer for the locale and units
+	printer, err := conv.NewPrinter(o.Locale, o.Units)
+	if err != nil {
+		return err
 	}
 
-	// If no input was provided, the current directory is the input
-	if len(o.Input) == 0 {
-		o.Input = []string{"."}
+	// Keep standard output clean when it is used for machine-readable stats
+	var msgs io.Writer = os.Stdout
+	if o.StatsFile == "" && o.StatsFormat != "" && o.StatsFormat != "text" {
+		msgs = os.Stderr
 	}
 
 	// Check if the output is valid
@@ -104,257 +118,23 @@ func Run(opts *Options) error {
 		o.Output += "." + o.Format
 	}
 
-	// Run each stop of the rendering pipeline sequentially
-	for _, step := range []func() error{scanStep, parseStep, renderStep, saveStep} {
-		if err := step(); err != nil {
-			return err
-		}
+	// Report progress and stats on the command line
+	o.OnFiles = func(n int) {
+		conv.FprintField(msgs, printer, "files", printer.Sprintf("%d", n))
 	}
-
-	return nil
-}
-
-// scanStep scans the input directory (o.Input) and puts the files in the "files" global variable.
-func scanStep() error {
-	if f, err := scan.Scan(o.Input); err != nil {
-		return err
-	} else {
-		files = f
-		en.Println("files:        ", len(files))
-		return nil
+	o.OnStats = func(st *parse.Stats) error {
+		return stats.Save(o.StatsFile, st, o.StatsFormat, printer)
 	}
-}
-
-// parseStep parses the files with the selector filters and puts the filtered activities in the global variable.
-func parseStep() error {
-	if a, stats, err := parse.Parse(files, &o.Selector); err != nil {
-		return err
-	} else {
-		activities = a
-		extent = stats.Extent
-		maxDur = stats.MaxDuration
-		stats.Print(en)
-		return nil
+	o.OnWarn = func(msg string) {
+		fmt.Fprintln(msgs, "WARN:", msg)
 	}
-}
-
-// renderStep renders the activity data onto frames for animation.
-// It calculates the positions and percentages of activities and generates
-// frames based on the provided configuration.
-func renderStep() error {
-	// Sort activities if looping is enabled to ensure chronological order
-	if o.Loop {
-		sort.Slice(activities, func(i, j int) bool {
-			return activities[i].Records[0].Timestamp.Before(activities[j].Records[0].Timestamp)
-		})
+	if progress.IsTerminal(os.Stderr) {
+		o.Progress = progress.NewBar(os.Stderr)
 	}
 
-	// Calculate map extent and scaling factors
-	minX, minY := extent.Min.MercatorProjection()
-	maxX, maxY := extent.Max.MercatorProjection()
-	dX, dY := maxX-minX, maxY-minY
-	scale := float64(o.Width) / dX
-	height := uint(dY * scale)
-	// Add margins
-	scale *= 0.9
-	minX -= 0.05 * dX
-	maxY += 0.05 * dY
-	// Create time scale based off of specified speed and the longest duration
-	tScale := 1 / (o.Speed * float64(maxDur))
-
-	// Scale the record positions and percentages by the scale factors
-	for i, act := range activities {
-		ts0 := act.Records[0].Timestamp
-		tOffset := 0.0
-		if o.Loop {
-			tOffset = float64(i) / float64(len(activities))
-		}
-		for _, r := range act.Records {
-			x, y := r.Position.MercatorProjection()
-			r.X = int((x - minX) * scale)
-			r.Y = int((maxY - y) * scale)
-			r.Percent = tOffset + float64(r.Timestamp.Sub(ts0))*tScale
-		}
-	}
-
-	// Create the color palette
-	pal := color.Palette(make([]color.Color, 1<<o.ColorDepth))
-	for i := 0; i < len(pal)-2; i++ {
-		pal[i] = o.Colors.GetColorAt(float64(i) / float64(len(pal)-3))
-	}
-	pal[len(pal)-2] = color.Black
-	pal[len(pal)-1] = color.Transparent
-
-	// Initialize all the frames with a background color and optional watermark
-	images = make([]*image.Paletted, o.Frames)
-	for i := range images {
-		im := image.NewPaletted(image.Rect(0, 0, int(o.Width), int(height)), pal)
-		if i == 0 {
-			drawFill(im, uint8(len(pal)-2))
-			if !o.NoWatermark {
-				img.DrawWatermark(im, fullTitle, pal[len(pal)/2])
-			}
-		} else {
-			copy(im.Pix, images[0].Pix)
-		}
-		images[i] = im
-	}
-
-	// Create a WaitGroup to wait for all goroutines to finish
-	wg := &sync.WaitGroup{}
-	wg.Add(int(o.Frames))
-	// Process all frames in synchronously
-	for f := uint(0); f < o.Frames; f++ {
-		f := f
-		go func() {
-			// Calculate the percentage progress of the current frame in the animation
-			fpc := float64(f+1) / float64(o.Frames)
-			gp := &glowPlotter{images[f]}
-			for _, act := range activities {
-				var rPrev *parse.Record
-				for _, r := range act.Records {
-					// Calculate the percentage progress of the record
-					pc := fpc - r.Percent
-
-					// Adjust percentage if it's negative and looping is disabled
-					if pc < 0 {
-						if !o.Loop {
-							break
-						}
-						pc++
-					}
-
-					// Render the line segment if it's different from the previous one
-					if rPrev != nil && (r.X != rPrev.X || r.Y != rPrev.Y) {
-						// Determine the color index based on the progress
-						ci := uint8(len(pal) - 3)
-						if pc >= 0 && pc < 1 {
-							ci = uint8(math.Sqrt(pc) * float64(len(pal)-2))
-						}
-
-						// Draw the line segment
-						bresenham.DrawLine(gp, rPrev.X, rPrev.Y, r.X, r.Y, grays[ci])
-					}
-
-					// Update the previous record
-					rPrev = r
-				}
-			}
-			// Signal the WaitGroup that this goroutine is done
-			wg.Done()
-		}()
-	}
-	// Wait for all goroutines to finish
-	wg.Wait()
-
-	return nil
-}
-
-// saveStep saves the worms to the specified output directory and file name as the specified file type.
-func saveStep() error {
-	// Create the save directory if it doesn't exist
-	if dir := filepath.Dir(o.Output); dir != "." {
-		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-			return err
-		}
-	}
-
-	// Create an empty file
-	out, err := os.Create(o.Output)
-	if err != nil {
+	r := New(&o)
+	if _, err := r.Render(ctx); err != nil {
 		return err
 	}
-
-	// At the very end, ensure the file is closed
-	defer func() {
-		if err := out.Close(); err != nil {
-			log.Fatal(err)
-		}
-	}()
-
-	// Depending on the save format, save appropriately
-	switch o.Format {
-	case "gif":
-		return saveGIF(out)
-	case "png":
-		return savePNG(out)
-	case "zip":
-		return saveZIP(out)
-	default:
-		return nil
-	}
-}
-
-// saveGIF save the worms to w as a gif.
-func saveGIF(w io.Writer) error {
-	// Optimize frames to reduce file size
-	optimizeFrames(images)
-
-	// Initialize gif
-	g := &gif.GIF{
-		Image:    images,
-		Delay:    make([]int, len(images)),
-		Disposal: make([]byte, len(images)),
-		Config: image.Config{
-			ColorModel: images[0].Palette,
-			Width:      images[0].Rect.Max.X,
-			Height:     images[0].Rect.Max.Y,
-		},
-	}
-
-	// Convert "frames per second" to "100s of seconds per frame"
-	d := int(math.Round(100 / float64(o.FPS)))
-
-	// Set delay and disposal method of each frame
-	for i := range images {
-		g.Disposal[i] = gif.DisposalNone
-		g.Delay[i] = d
-	}
-
-	// Save all the frames of the gif to the file
-	return gif.EncodeAll(&gifWriter{Writer: bufio.NewWriter(w), Comment: fullTitle}, g)
-}
-
-// saveGIF save the worms to w as a png.
-func savePNG(w io.Writer) error {
-	// Optimize frames to reduce file size
-	optimizeFrames(images)
-
-	// Initialize png
-	a := apng.APNG{Frames: make([]apng.Frame, len(images))}
-
-	// Set each frame
-	for i, im := range images {
-		a.Frames[i].Image = im
-		a.Frames[i].XOffset = im.Rect.Min.X
-		a.Frames[i].YOffset = im.Rect.Min.Y
-		a.Frames[i].BlendOp = apng.BLEND_OP_OVER
-		a.Frames[i].DelayNumerator = 1
-		a.Frames[i].DelayDenominator = uint16(o.FPS)
-	}
-
-	// Save the apng to the file
-	return apng.Encode(&pngWriter{Writer: w, Text: fullTitle}, a)
-}
-
-// saveGIF save the worms to w as a zip of gifs.
-func saveZIP(w io.Writer) error {
-	z := zip.NewWriter(w)
-
-	// At the very end, ensure the file is closed
-	defer func() {
-		if err := z.Close(); err != nil {
-			log.Fatal(err)
-		}
-	}()
-
-	// Add every image to the zip as a gif
-	for i, im := range images {
-		if w, err := z.Create(fmt.Sprintf("%d.gif", i)); err != nil {
-			return err
-		} else if err = gif.Encode(w, im, nil); err != nil {
-			return err
-		}
-	}
-	return nil
+	return img.SaveFile(o.Output, func(w io.Writer) error { return r.Save(ctx, w) })
 }
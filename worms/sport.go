@@ -0,0 +1,72 @@
+package worms
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+// SportColors maps sport names to their color gradients.
+type SportColors map[string]img.ColorGradient
+
+// sportGradients partitions the colors of an animation colored by age across the sports of its activities.
+type sportGradients struct {
+	names     []string            // The sport of each gradient, or empty for the gradient of every other sport
+	gradients []img.ColorGradient // The gradients in use, those of sports with their own colors first in name order
+	index     []int               // The index of the gradient of each activity
+}
+
+// newSportGradients assigns each activity the gradient of its sport in sportColors, keyed case-insensitively,
+// or the colors gradient if its sport has no colors of its own.
+// Only the gradients of sports that occur are kept, so a palette partitioned across them wastes no colors.
+func newSportGradients(activities []*parse.Activity, colors img.ColorGradient, sportColors SportColors) *sportGradients {
+	// Find the sports with their own colors that occur
+	lower := make(map[string]img.ColorGradient, len(sportColors))
+	for sport, g := range sportColors {
+		lower[strings.ToLower(sport)] = g
+	}
+	used := make(map[string]bool)
+	other := false
+	for _, act := range activities {
+		if _, ok := lower[strings.ToLower(act.Sport)]; ok {
+			used[strings.ToLower(act.Sport)] = true
+		} else {
+			other = true
+		}
+	}
+
+	// List their gradients in name order, followed by the gradient of every other sport
+	sg := &sportGradients{index: make([]int, len(activities))}
+	for sport := range used {
+		sg.names = append(sg.names, sport)
+	}
+	sort.Strings(sg.names)
+	for _, sport := range sg.names {
+		sg.gradients = append(sg.gradients, lower[sport])
+	}
+	if other || len(sg.names) == 0 {
+		sg.names = append(sg.names, "")
+		sg.gradients = append(sg.gradients, colors)
+	}
+
+	// Point each activity at the gradient of its sport
+	position := make(map[string]int, len(sg.names))
+	for g, sport := range sg.names {
+		position[sport] = g
+	}
+	for i, act := range activities {
+		sport := strings.ToLower(act.Sport)
+		if !used[sport] {
+			sport = ""
+		}
+		sg.index[i] = position[sport]
+	}
+	return sg
+}
+
+// partitioned returns true if the activities are colored by more than one gradient or a gradient other than the default.
+func (sg *sportGradients) partitioned() bool {
+	return len(sg.names) > 1 || sg.names[0] != ""
+}
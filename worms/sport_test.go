@@ -0,0 +1,47 @@
+package worms
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+func TestSportGradients(t *testing.T) {
+	var colors, orange, blue img.ColorGradient
+	_ = colors.Parse("#fff,#000")
+	_ = orange.Parse("#f80,#fff")
+	_ = blue.Parse("#08f,#fff")
+
+	testCases := []struct {
+		sports      []string
+		sportColors SportColors
+		names       []string
+		index       []int
+	}{
+		{[]string{"running", "cycling"}, nil, []string{""}, []int{0, 0}},
+		{[]string{"running", "cycling"}, SportColors{"running": orange}, []string{"running", ""}, []int{0, 1}},
+		{[]string{"Running", "cycling"}, SportColors{"running": orange, "cycling": blue}, []string{"cycling", "running"}, []int{1, 0}},
+		{[]string{"running", "running"}, SportColors{"running": orange, "cycling": blue}, []string{"running"}, []int{0, 0}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			activities := make([]*parse.Activity, len(testCase.sports))
+			for j, sport := range testCase.sports {
+				activities[j] = &parse.Activity{Sport: sport}
+			}
+			sg := newSportGradients(activities, colors, testCase.sportColors)
+			if fmt.Sprint(sg.names) != fmt.Sprint(testCase.names) {
+				t.Fatal(sg.names, "!=", testCase.names)
+			}
+			if fmt.Sprint(sg.index) != fmt.Sprint(testCase.index) {
+				t.Fatal(sg.index, "!=", testCase.index)
+			}
+			if len(sg.gradients) != len(sg.names) {
+				t.Fatal(len(sg.gradients), "!=", len(sg.names))
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package worms
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+// timelineOffsets returns the percentage of the animation at which each activity starts in timeline mode,
+// following the calendar order and spacing of their start dates with idle gaps longer than MaxGap shortened,
+// the percentage of the animation that each day of the shortened timeline takes,
+// and the factor that converts activity durations to percentages of the animation.
+// The longest activity, maxDur, plays out over a day of the timeline slowed by the speed,
+// or over a second of animation if days go by faster, so worms still visibly grow.
+func timelineOffsets(activities []*parse.Activity, o *Options, maxDur time.Duration) ([]float64, float64, float64) {
+	offsets := make([]float64, len(activities))
+	if len(activities) == 0 {
+		return offsets, 0, 0
+	}
+
+	// Order the activities by start date
+	order := make([]int, len(activities))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return activities[order[i]].Start().Before(activities[order[j]].Start())
+	})
+
+	// Accumulate the calendar time elapsed before each activity starts, shortening long gaps
+	elapsed := make([]time.Duration, len(activities))
+	var total time.Duration
+	for k := 1; k < len(order); k++ {
+		gap := activities[order[k]].Start().Sub(activities[order[k-1]].Start())
+		if o.MaxGap > 0 && gap > o.MaxGap {
+			gap = o.MaxGap
+		}
+		total += gap
+		elapsed[order[k]] = total
+	}
+
+	// Convert elapsed days to seconds of animation, then to percentages of all the frames,
+	// or fit the whole history so the last activity finishes on the last frame
+	second := float64(o.FPS) / float64(o.Frames)
+	var dayPercent, unit float64
+	if o.DayRate > 0 {
+		dayPercent = float64(o.FPS) / float64(o.Frames) / o.DayRate
+		unit = math.Max(dayPercent, second)
+	} else if days := total.Hours() / 24; days > 0 {
+		last := activities[order[len(order)-1]]
+		lastUnits := float64(last.End().Sub(last.Start())) / (o.Speed * float64(maxDur))
+		if dayPercent = 1 / (days + lastUnits); dayPercent < second {
+			dayPercent = math.Max(0, 1-lastUnits*second) / days
+			unit = second
+		} else {
+			unit = dayPercent
+		}
+	} else {
+		// Every activity starts together, so they take the whole animation as usual
+		unit = 1
+	}
+	for i, e := range elapsed {
+		offsets[i] = e.Hours() / 24 * dayPercent
+	}
+	return offsets, dayPercent, unit / (o.Speed * float64(maxDur))
+}
+
+// clockPercent returns the local time of day of timestamp ts as a percentage of the day the activity started on,
+// so records after midnight continue beyond 100%. Wall clock time is used, so daylight saving changes are ignored.
+// Activities without a recorded time zone are placed in zone, or in the zone estimated from their longitude if zone is nil.
+func clockPercent(act *parse.Activity, ts time.Time, zone *time.Location) float64 {
+	local := act.Local
+	if act.Location == nil && zone != nil {
+		local = func(ts time.Time) time.Time { return ts.In(zone) }
+	}
+	start, now := local(act.Start()), local(ts)
+	days := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).
+		Sub(time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)).Hours() / 24
+	clock := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute +
+		time.Duration(now.Second())*time.Second + time.Duration(now.Nanosecond())
+	return days + float64(clock)/float64(24*time.Hour)
+}
+
+// clockLabel returns the simulated time of day of the frame at percentage fpc, from 00:00 to 24:00.
+func clockLabel(fpc float64) string {
+	minutes := int(math.Round(fpc * 24 * 60))
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
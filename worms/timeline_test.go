@@ -0,0 +1,152 @@
+package worms
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+func TestTimelineOffsets(t *testing.T) {
+	// Three one hour activities, the second a day after the first and the third nine days after that
+	t0 := time.Date(2022, 2, 13, 6, 0, 0, 0, time.UTC)
+	starts := []time.Duration{10 * 24 * time.Hour, 0, 24 * time.Hour}
+	activities := make([]*parse.Activity, len(starts))
+	for i, start := range starts {
+		activities[i] = &parse.Activity{Records: []*parse.Record{{Timestamp: t0.Add(start)}, {Timestamp: t0.Add(start + time.Hour)}}}
+	}
+
+	testCases := []struct {
+		dayRate    float64
+		maxGap     time.Duration
+		expect     []float64
+		expectHour float64
+	}{
+		{0, 0, []float64{0.95, 0, 0.095}, 0.05},
+		{0, 4 * 24 * time.Hour, []float64{10.0 / 11, 0, 2.0 / 11}, 1.0 / 11},
+		{1, 0, []float64{1, 0, 0.1}, 0.05},
+		{2, 48 * time.Hour, []float64{0.15, 0, 0.05}, 0.05},
+		{0.1, 0, []float64{10, 0, 1}, 0.5},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			o := &Options{Frames: 100, FPS: 10, Speed: 2, DayRate: testCase.dayRate, MaxGap: testCase.maxGap}
+			actual, _, tScale := timelineOffsets(activities, o, time.Hour)
+			for j := range actual {
+				if math.Abs(actual[j]-testCase.expect[j]) > 1e-9 {
+					t.Fatal(actual, "!=", testCase.expect)
+				}
+			}
+			if hour := tScale * float64(time.Hour); math.Abs(hour-testCase.expectHour) > 1e-9 {
+				t.Fatal(hour, "!=", testCase.expectHour)
+			}
+		})
+	}
+
+	// Activities on the same date take the whole animation, as they do outside timeline mode
+	o := &Options{Frames: 100, FPS: 10, Speed: 2}
+	if _, _, tScale := timelineOffsets(activities[1:2], o, time.Hour); math.Abs(tScale*float64(time.Hour)-0.5) > 1e-9 {
+		t.Fatal(tScale*float64(time.Hour), "!=", 0.5)
+	}
+}
+
+func TestClockPercent(t *testing.T) {
+	zone := time.FixedZone("", 10*3600)
+	melbourne, err := time.LoadLocation("Australia/Melbourne")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := &parse.Activity{Location: zone, Records: []*parse.Record{{Timestamp: time.Date(2022, 2, 13, 6, 0, 0, 0, zone)}}}
+	estimated := &parse.Activity{Records: []*parse.Record{{Timestamp: time.Date(2022, 2, 12, 20, 0, 0, 0, time.UTC), Position: geo.NewPointFromDegrees(-37.8, 144.9)}}}
+
+	testCases := []struct {
+		act    *parse.Activity
+		zone   *time.Location
+		ts     time.Time
+		expect float64
+	}{
+		{recorded, nil, time.Date(2022, 2, 13, 6, 0, 0, 0, zone), 0.25},
+		{recorded, nil, time.Date(2022, 2, 13, 18, 0, 0, 0, zone), 0.75},
+		{recorded, nil, time.Date(2022, 2, 13, 2, 0, 0, 0, time.UTC), 0.5},
+		{recorded, nil, time.Date(2022, 2, 14, 3, 0, 0, 0, zone), 1.125},
+		{recorded, melbourne, time.Date(2022, 2, 13, 6, 0, 0, 0, zone), 0.25},
+		{estimated, nil, time.Date(2022, 2, 12, 20, 0, 0, 0, time.UTC), 0.25},
+		{estimated, melbourne, time.Date(2022, 2, 12, 20, 0, 0, 0, time.UTC), 7.0 / 24},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			if actual := clockPercent(testCase.act, testCase.ts, testCase.zone); math.Abs(actual-testCase.expect) > 1e-9 {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+func TestClockLabel(t *testing.T) {
+	testCases := []struct {
+		fpc    float64
+		expect string
+	}{
+		{0, "00:00"},
+		{0.25, "06:00"},
+		{0.5 + 1.0/48, "12:30"},
+		{1, "24:00"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			if actual := clockLabel(testCase.fpc); actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
+
+func TestRendererTimelineWarn(t *testing.T) {
+	// Two one minute activities a day apart
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.gpx", i)), []byte(fmt.Sprintf(`
+			<gpx>
+			  <trk>
+			    <trkseg>
+			      <trkpt lat="-37.80" lon="144.90"><time>2022-02-1%[1]dT00:00:00Z</time></trkpt>
+			      <trkpt lat="-37.81" lon="144.91"><time>2022-02-1%[1]dT00:01:00Z</time></trkpt>
+			    </trkseg>
+			  </trk>
+			</gpx>`, i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		dayRate float64
+		expect  string
+	}{
+		{0, ""},
+		{10, ""},
+		{0.1, "1 activities finish after the last frame, the timeline needs 200 frames at this day rate"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			var actual string
+			opts := &Options{Input: []string{dir}, Width: 50, Frames: 100, FPS: 10, ColorDepth: 5, Speed: 1, Projection: "mercator", Timeline: true, DayRate: testCase.dayRate, NoWatermark: true}
+			opts.OnWarn = func(msg string) { actual = msg }
+			if _, err := New(opts).Render(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if actual != testCase.expect {
+				t.Fatal(actual, "!=", testCase.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package worms
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/NathanBaulch/rainbow-roads/img"
+)
+
+// Trails lists the supported trail modes.
+var Trails = []string{"persistent", "tail", "decay"}
+
+// fade returns how far a segment with percentage progress pc has faded along its trail, from 0 at the head to 1,
+// and the intensity it is drawn at, which falls towards the ghost level in the tail and decay modes.
+// Segments stay at the end of the gradient in persistent mode, leave the tail once it has passed them,
+// or fade by half every trail length in decay mode.
+func (r *Renderer) fade(pc float64) (float64, float64) {
+	var a float64
+	switch r.o.Trail {
+	case "tail":
+		a = math.Min(pc/r.trailSpan, 1)
+	case "decay":
+		a = 1 - math.Exp2(-pc/r.trailSpan)
+	default:
+		return math.Min(pc, 1), 1
+	}
+	return a, 1 - (1-r.o.Ghost)*a
+}
+
+// unfade returns the percentage progress at which a segment has faded a of the way along its trail, the inverse of fade.
+func (r *Renderer) unfade(a float64) float64 {
+	switch r.o.Trail {
+	case "tail":
+		return a * r.trailSpan
+	case "decay":
+		return -r.trailSpan * math.Log2(1-a)
+	default:
+		return a
+	}
+}
+
+// trailColor returns the color of the gradient at position p, dimmed by the intensity of a segment that has faded to that position.
+func (r *Renderer) trailColor(colors img.ColorGradient, p float64) color.RGBA {
+	c := color.RGBAModel.Convert(colors.GetColorAt(p)).(color.RGBA)
+	if r.o.Trail == "tail" || r.o.Trail == "decay" {
+		c = shade(c, 1-(1-r.o.Ghost)*p*p)
+	}
+	return c
+}
@@ -0,0 +1,112 @@
+package worms
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/img"
+)
+
+func TestFade(t *testing.T) {
+	testCases := []struct {
+		trail   string
+		ghost   float64
+		pc      float64
+		expectA float64
+		expectK float64
+	}{
+		{"persistent", 0, 0.5, 0.5, 1},
+		{"persistent", 0, 2, 1, 1},
+		{"tail", 0, 0.05, 0.5, 0.5},
+		{"tail", 0, 0.2, 1, 0},
+		{"tail", 0.5, 0.2, 1, 0.5},
+		{"decay", 0, 0.1, 0.5, 0.5},
+		{"decay", 0.5, 0.2, 0.75, 0.625},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			r := &Renderer{o: Options{Trail: testCase.trail, Ghost: testCase.ghost}, trailSpan: 0.1}
+			a, k := r.fade(testCase.pc)
+			if math.Abs(a-testCase.expectA) > 1e-9 || math.Abs(k-testCase.expectK) > 1e-9 {
+				t.Fatal(a, k, "!=", testCase.expectA, testCase.expectK)
+			}
+			if a < 1 {
+				if pc := r.unfade(a); math.Abs(pc-testCase.pc) > 1e-9 {
+					t.Fatal(pc, "!=", testCase.pc)
+				}
+			}
+		})
+	}
+}
+
+func TestRendererTrail(t *testing.T) {
+	// Count the lit pixels of the last frame, which the tail has left behind
+	dir := writeActivity(t)
+	lit := func(trail string, truecolor bool) int {
+		opts := &Options{Input: []string{dir}, Width: 100, Frames: 10, FPS: 10, ColorDepth: 5, Speed: 1, Projection: "mercator", NoWatermark: true, Truecolor: truecolor, Trail: trail, TrailLength: 0.2}
+		ims, err := New(opts).Render(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		im := ims[len(ims)-1]
+		n := 0
+		for y := im.Bounds().Min.Y; y < im.Bounds().Max.Y; y++ {
+			for x := im.Bounds().Min.X; x < im.Bounds().Max.X; x++ {
+				if r, g, b, _ := im.At(x, y).RGBA(); r|g|b != 0 {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	for _, truecolor := range []bool{false, true} {
+		if persistent, tail := lit("persistent", truecolor), lit("tail", truecolor); tail >= persistent {
+			t.Fatal(tail, ">=", persistent)
+		}
+	}
+}
+
+func TestTrailLegend(t *testing.T) {
+	printer, err := conv.NewPrinter("en", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var colors img.ColorGradient
+	if err := colors.Parse(DefaultColors); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		trail  string
+		expect []string
+	}{
+		{"persistent", []string{"now", "38m0s ago", "2h30m0s ago", "5h38m0s ago", "earlier"}},
+		{"tail", []string{"now", "4m0s ago", "15m0s ago", "34m0s ago", "earlier"}},
+		{"decay", []string{"now", "6m0s ago", "25m0s ago", "1h12m0s ago", "earlier"}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			r := &Renderer{o: Options{Trail: testCase.trail, Speed: 1, Colors: colors}, trailSpan: 0.1, maxDur: 10 * time.Hour, printer: printer}
+			r.sports = newSportGradients(nil, colors, nil)
+			labels, swatches := r.legend()
+			if strings.Join(labels, ",") != strings.Join(testCase.expect, ",") {
+				t.Fatal(labels, "!=", testCase.expect)
+			}
+
+			// The swatches match the colors the lines are drawn in
+			for j, swatch := range swatches {
+				if expect := r.trailColor(colors, float64(j)/float64(legendSteps-1)); swatch != expect {
+					t.Fatal(swatch, "!=", expect)
+				}
+			}
+		})
+	}
+}